@@ -0,0 +1,216 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/signature"
+	"slbot/internal/webhook"
+)
+
+// Headers an inbound message must be signed with. The signature is the
+// HMAC-SHA256 of the timestamp, a newline and the body.
+const (
+	HeaderTimestamp = "X-SLBot-Timestamp"
+	HeaderSignature = "X-SLBot-Signature"
+)
+
+// Origin marks relayed chat so the other side doesn't send it back
+const Origin = "secondlife"
+
+// echoWindow is how long a message said for the bridge is remembered, so
+// hearing it again in world isn't relayed back out
+const echoWindow = 2 * time.Minute
+
+// Inbound is a message from the external chat system
+type Inbound struct {
+	Origin string `json:"origin"` // Messages with the bridge's own origin are dropped
+	Source string `json:"source"` // Name of the external system, e.g. Discord
+	Name   string `json:"name"`   // Who wrote the message
+	Text   string `json:"text"`
+	To     string `json:"to"` // Avatar UUID to IM instead of saying it in local chat
+}
+
+// Bridge relays Second Life chat to an external chat system through a
+// webhook and checks messages coming back the other way
+type Bridge struct {
+	config   config.BridgeConfig
+	relay    map[string]bool
+	channels map[int]bool
+	ignore   map[string]bool
+	outbound *webhook.Dispatcher
+	guard    *signature.Guard
+	recent   map[string]time.Time // Text said for the bridge -> when
+	mutex    sync.Mutex
+}
+
+// NewBridge creates a bridge from the configuration
+func NewBridge(cfg *config.Config) *Bridge {
+	b := &Bridge{
+		config:   cfg.Bridge,
+		relay:    make(map[string]bool),
+		channels: make(map[int]bool),
+		ignore:   make(map[string]bool),
+		guard:    signature.NewGuard(time.Duration(cfg.Bridge.MaxSkew) * time.Second),
+		recent:   make(map[string]time.Time),
+	}
+
+	for _, kind := range splitList(cfg.Bridge.Relay) {
+		// Corrade calls instant messages "message"
+		if kind == "im" {
+			kind = "message"
+		}
+		b.relay[kind] = true
+	}
+	for _, value := range splitList(cfg.Bridge.Channels) {
+		if channel, err := strconv.Atoi(value); err == nil {
+			b.channels[channel] = true
+		}
+	}
+	for _, uuid := range splitList(cfg.Bridge.Ignore) {
+		b.ignore[uuid] = true
+	}
+
+	// Outbound chat goes through a webhook of its own for retries and the dead-letter log
+	hooks := cfg.Webhooks
+	hooks.Hooks = nil
+	if cfg.Bridge.Enabled {
+		hooks.Hooks = []config.Webhook{{
+			Name:   "bridge",
+			Events: webhook.EventChat + "," + webhook.EventIM,
+			Secret: cfg.Bridge.Secret,
+			URL:    cfg.Bridge.URL,
+		}}
+	}
+	b.outbound = webhook.New(hooks, cfg.Bot.Name)
+
+	return b
+}
+
+// splitList splits a comma separated setting into lower-case values
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IsEnabled reports whether the bridge is turned on
+func (b *Bridge) IsEnabled() bool {
+	return b.config.Enabled
+}
+
+// Start delivers relayed chat until the context is cancelled
+func (b *Bridge) Start(ctx context.Context) {
+	b.outbound.Start(ctx)
+}
+
+// Relay posts a chat message to the external system if its type and
+// channel are selected, and reports whether it was sent. Messages the bot
+// said for the bridge and senders on the ignore list are never relayed.
+func (b *Bridge) Relay(kind string, channel int, name, uuid, text, region string) bool {
+	if !b.config.Enabled || !b.relay[kind] || b.ignore[strings.ToLower(uuid)] {
+		return false
+	}
+	if kind == "local" && len(b.channels) > 0 && !b.channels[channel] {
+		return false
+	}
+	if b.isEcho(text) {
+		return false
+	}
+
+	event := webhook.EventChat
+	if kind == "message" {
+		event = webhook.EventIM
+	}
+	b.outbound.Emit(event, map[string]interface{}{
+		"origin":  Origin,
+		"type":    event,
+		"channel": channel,
+		"name":    name,
+		"uuid":    uuid,
+		"text":    text,
+		"region":  region,
+		"formatted": strings.NewReplacer(
+			"{name}", name,
+			"{text}", text,
+			"{type}", event,
+		).Replace(b.config.OutboundFormat),
+	})
+	return true
+}
+
+// Authenticate checks an inbound message's signature and timestamp
+func (b *Bridge) Authenticate(timestamp, sig string, body []byte) error {
+	if !b.config.Enabled {
+		return fmt.Errorf("the chat bridge is disabled")
+	}
+	sig = strings.TrimPrefix(sig, "sha256=")
+	if !signature.Check(b.config.Secret, timestamp+"\n"+string(body), sig) {
+		return fmt.Errorf("invalid signature")
+	}
+	return b.guard.Check(timestamp, sig, time.Now())
+}
+
+// Format checks an inbound message and returns the text to say for it,
+// with the sender's name prefixed
+func (b *Bridge) Format(message Inbound) (string, error) {
+	if strings.EqualFold(message.Origin, Origin) {
+		return "", fmt.Errorf("message came from Second Life")
+	}
+	text := strings.TrimSpace(message.Text)
+	if text == "" {
+		return "", fmt.Errorf("the message is empty")
+	}
+	name := strings.TrimSpace(message.Name)
+	if name == "" {
+		name = "Someone"
+	}
+	source := strings.TrimSpace(message.Source)
+	if source == "" {
+		source = "Bridge"
+	}
+
+	return strings.NewReplacer(
+		"{source}", source,
+		"{name}", name,
+		"{text}", text,
+	).Replace(b.config.InboundFormat), nil
+}
+
+// Saying remembers the exact text about to be said for an inbound message,
+// so hearing it again isn't relayed back out
+func (b *Bridge) Saying(text string) {
+	b.mutex.Lock()
+	b.recent[text] = time.Now()
+	b.mutex.Unlock()
+}
+
+// isEcho reports whether text is something recently said for the bridge,
+// such as another bot in the region repeating it
+func (b *Bridge) isEcho(text string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := time.Now()
+	for said, at := range b.recent {
+		if now.Sub(at) > echoWindow {
+			delete(b.recent, said)
+		}
+	}
+	_, echoed := b.recent[text]
+	return echoed
+}
+
+// Stats returns the outbound delivery counts
+func (b *Bridge) Stats() []webhook.HookStats {
+	return b.outbound.Stats()
+}
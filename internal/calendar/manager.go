@@ -0,0 +1,303 @@
+package calendar
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+)
+
+// Event is something happening at the venue
+type Event struct {
+	ID          string            `json:"id"`
+	Title       string            `json:"title"`
+	Start       time.Time         `json:"start"`
+	CreatedBy   string            `json:"createdBy"`
+	Subscribers map[string]string `json:"subscribers"`                   // UUID -> name
+	Reminded    map[string]bool   `json:"remindedSubscribers,omitempty"` // UUIDs of subscribers who have been sent their IM
+	Announced   bool              `json:"announced"`                     // Announced in local chat
+}
+
+// Listing is the public view of an event, without who asked to be reminded
+type Listing struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Start       time.Time `json:"start"`
+	Subscribers int       `json:"subscribers"` // How many asked to be reminded
+}
+
+// Listing returns the public view of an event
+func (e *Event) Listing() Listing {
+	return Listing{ID: e.ID, Title: e.Title, Start: e.Start, Subscribers: len(e.Subscribers)}
+}
+
+// state is what is saved across restarts
+type state struct {
+	NextID int               `json:"nextId"`
+	Events map[string]*Event `json:"events"`
+}
+
+// Manager keeps the event calendar and works out when to remind people
+type Manager struct {
+	config config.CalendarConfig
+	state  state
+	mutex  sync.RWMutex
+}
+
+// NewManager creates a calendar and loads the saved events
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config: cfg.Calendar,
+		state:  state{NextID: 1, Events: make(map[string]*Event)},
+	}
+
+	if err := persistant.LoadState(cfg.Calendar.Storage, &manager.state); err != nil {
+		log.Printf("No events loaded from %s: %v", cfg.Calendar.Storage, err)
+	}
+	if manager.state.Events == nil {
+		manager.state.Events = make(map[string]*Event)
+	}
+
+	return manager
+}
+
+// Add puts an event on the calendar
+func (m *Manager) Add(title string, start time.Time, createdBy string) (*Event, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("the event needs a name")
+	}
+	if !start.After(time.Now()) {
+		return nil, fmt.Errorf("the event must start in the future")
+	}
+
+	m.mutex.Lock()
+	event := &Event{
+		ID:          strconv.Itoa(m.state.NextID),
+		Title:       title,
+		Start:       start,
+		CreatedBy:   createdBy,
+		Subscribers: make(map[string]string),
+	}
+	m.state.NextID++
+	m.state.Events[event.ID] = event
+	added := *event
+	m.mutex.Unlock()
+
+	m.save()
+	return &added, nil
+}
+
+// Cancel removes an event, found by ID or title, and returns it so its
+// subscribers can be told
+func (m *Manager) Cancel(query string) (*Event, error) {
+	m.mutex.Lock()
+	event := m.find(query)
+	if event == nil {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("there's no upcoming event called %s", query)
+	}
+	delete(m.state.Events, event.ID)
+	cancelled := copyEvent(event)
+	m.mutex.Unlock()
+
+	m.save()
+	return cancelled, nil
+}
+
+// Subscribe asks for a reminder about an event, found by ID or title
+func (m *Manager) Subscribe(query, uuid, name string) (*Event, error) {
+	if uuid == "" {
+		return nil, fmt.Errorf("I can only remind avatars")
+	}
+
+	m.mutex.Lock()
+	event := m.find(query)
+	if event == nil {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("there's no upcoming event called %s", query)
+	}
+	event.Subscribers[strings.ToLower(uuid)] = name
+	subscribed := copyEvent(event)
+	m.mutex.Unlock()
+
+	m.save()
+	return subscribed, nil
+}
+
+// Unsubscribe stops the reminder about an event
+func (m *Manager) Unsubscribe(query, uuid string) (*Event, error) {
+	m.mutex.Lock()
+	event := m.find(query)
+	if event == nil {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("there's no upcoming event called %s", query)
+	}
+	if _, subscribed := event.Subscribers[strings.ToLower(uuid)]; !subscribed {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("you weren't going to be reminded about %s", event.Title)
+	}
+	delete(event.Subscribers, strings.ToLower(uuid))
+	delete(event.Reminded, strings.ToLower(uuid))
+	unsubscribed := copyEvent(event)
+	m.mutex.Unlock()
+
+	m.save()
+	return unsubscribed, nil
+}
+
+// Upcoming returns the events that haven't started, soonest first
+func (m *Manager) Upcoming(now time.Time) []*Event {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	events := make([]*Event, 0, len(m.state.Events))
+	for _, event := range m.state.Events {
+		if event.Start.After(now) {
+			events = append(events, copyEvent(event))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	return events
+}
+
+// Due returns the events whose subscribers should be reminded, with only
+// the subscribers still waiting for their IM, and those to announce in
+// local chat, and drops events that ended long ago. Nothing is marked done
+// until MarkReminded or MarkAnnounced says it was delivered.
+func (m *Manager) Due(now time.Time) (reminders, announcements []*Event) {
+	remindBefore := time.Duration(m.config.ReminderMinutes) * time.Minute
+	announceBefore := time.Duration(m.config.AnnounceMinutes) * time.Minute
+	keep := time.Duration(m.config.KeepHours) * time.Hour
+
+	m.mutex.Lock()
+	changed := false
+	for id, event := range m.state.Events {
+		switch {
+		case now.Sub(event.Start) > keep:
+			delete(m.state.Events, id)
+			changed = true
+			continue
+		case !event.Start.After(now):
+			// Started while the bot wasn't watching; too late to remind
+			continue
+		}
+
+		untilStart := event.Start.Sub(now)
+		if untilStart <= remindBefore {
+			waiting := copyEvent(event)
+			for uuid := range event.Reminded {
+				delete(waiting.Subscribers, uuid)
+			}
+			if len(waiting.Subscribers) > 0 {
+				reminders = append(reminders, waiting)
+			}
+		}
+		if !event.Announced && untilStart <= announceBefore {
+			announcements = append(announcements, copyEvent(event))
+		}
+	}
+	m.mutex.Unlock()
+
+	if changed {
+		m.save()
+	}
+	return reminders, announcements
+}
+
+// MarkReminded records that a subscriber has been sent their reminder
+func (m *Manager) MarkReminded(id, uuid string) {
+	m.mutex.Lock()
+	event, exists := m.state.Events[id]
+	if !exists {
+		m.mutex.Unlock()
+		return
+	}
+	if event.Reminded == nil {
+		event.Reminded = make(map[string]bool)
+	}
+	event.Reminded[strings.ToLower(uuid)] = true
+	m.mutex.Unlock()
+
+	m.save()
+}
+
+// MarkAnnounced records that an event has been announced in local chat
+func (m *Manager) MarkAnnounced(id string) {
+	m.mutex.Lock()
+	event, exists := m.state.Events[id]
+	if !exists {
+		m.mutex.Unlock()
+		return
+	}
+	event.Announced = true
+	m.mutex.Unlock()
+
+	m.save()
+}
+
+// find looks up an upcoming event by ID, then by title, then by part of
+// its title, preferring the soonest. The caller must hold the lock.
+func (m *Manager) find(query string) *Event {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	if event, exists := m.state.Events[query]; exists {
+		return event
+	}
+
+	now := time.Now()
+	var exact, partial *Event
+	for _, event := range m.state.Events {
+		if !event.Start.After(now) {
+			continue
+		}
+		title := strings.ToLower(event.Title)
+		switch {
+		case title == query:
+			if exact == nil || event.Start.Before(exact.Start) {
+				exact = event
+			}
+		case strings.Contains(title, query):
+			if partial == nil || event.Start.Before(partial.Start) {
+				partial = event
+			}
+		}
+	}
+	if exact != nil {
+		return exact
+	}
+	return partial
+}
+
+// copyEvent copies an event so callers can't change the calendar
+func copyEvent(event *Event) *Event {
+	copied := *event
+	copied.Subscribers = make(map[string]string, len(event.Subscribers))
+	for uuid, name := range event.Subscribers {
+		copied.Subscribers[uuid] = name
+	}
+	copied.Reminded = make(map[string]bool, len(event.Reminded))
+	for uuid := range event.Reminded {
+		copied.Reminded[uuid] = true
+	}
+	return &copied
+}
+
+// save writes the calendar to disk. A failed save is only logged: the
+// calendar in memory is still right and the next change saves it again.
+func (m *Manager) save() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if err := persistant.SaveState(m.config.Storage, m.state); err != nil {
+		log.Printf("Failed to save events: %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var dayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+var clockPattern = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*(am|pm)?$`)
+
+// ParseWhen splits text such as "Jazz Night fri 8pm" into a title and the
+// start time it names, in now's location. The time is required; it may be
+// preceded by a weekday, today, tonight, tomorrow, a date (2026-10-23 or
+// 10/23) and the words on or at. Without a day the next time the clock
+// reads that time is used.
+func ParseWhen(text string, now time.Time) (string, time.Time, error) {
+	words := strings.Fields(text)
+
+	// The time, possibly written as two words like "8 pm"
+	n := len(words)
+	if n >= 2 {
+		if suffix := strings.ToLower(words[n-1]); suffix == "am" || suffix == "pm" {
+			words = append(words[:n-2], words[n-2]+suffix)
+		}
+	}
+	if len(words) == 0 {
+		return "", time.Time{}, fmt.Errorf("say when, e.g. fri 8pm")
+	}
+	hour, minute, err := parseClock(words[len(words)-1])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	words = words[:len(words)-1]
+	words = trimWord(words, "at")
+
+	// The day, if there is one
+	day, weekday, dayGiven := now, false, false
+	if len(words) > 0 {
+		if day, weekday, dayGiven = parseDay(words[len(words)-1], now); dayGiven {
+			words = trimWord(words[:len(words)-1], "on")
+		} else {
+			day = now
+		}
+	}
+
+	title := strings.TrimSpace(strings.Join(words, " "))
+	if title == "" {
+		return "", time.Time{}, fmt.Errorf("the event needs a name")
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, now.Location())
+	if !start.After(now) {
+		// A weekday or bare time that has already passed means the next one
+		switch {
+		case weekday:
+			start = start.AddDate(0, 0, 7)
+		case !dayGiven:
+			start = start.AddDate(0, 0, 1)
+		default:
+			return "", time.Time{}, fmt.Errorf("%s is in the past", start.Format("Mon Jan 2 3:04 PM"))
+		}
+	}
+	if start.Hour() != hour || start.Minute() != minute {
+		// The clocks went forward past that time; time.Date gives the hour
+		// before, so use the hour after, e.g. 3:30 for 2:30
+		start = start.Add(time.Hour)
+	}
+	return title, start, nil
+}
+
+// trimWord drops a trailing connecting word like "at"
+func trimWord(words []string, word string) []string {
+	if len(words) > 0 && strings.EqualFold(words[len(words)-1], word) {
+		return words[:len(words)-1]
+	}
+	return words
+}
+
+// parseClock reads 8pm, 8:30pm, 20:00, noon or midnight
+func parseClock(text string) (int, int, error) {
+	text = strings.ToLower(text)
+	switch text {
+	case "noon":
+		return 12, 0, nil
+	case "midnight":
+		return 0, 0, nil
+	}
+
+	match := clockPattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0, 0, fmt.Errorf("'%s' isn't a time like 8pm or 20:00", text)
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute := 0
+	if match[2] != "" {
+		minute, _ = strconv.Atoi(match[2])
+	}
+	switch {
+	case match[3] == "" && match[2] == "":
+		return 0, 0, fmt.Errorf("'%s' isn't a time like 8pm or 20:00", text)
+	case match[3] != "" && (hour < 1 || hour > 12):
+		return 0, 0, fmt.Errorf("'%s' isn't a valid time", text)
+	case hour > 23 || minute > 59:
+		return 0, 0, fmt.Errorf("'%s' isn't a valid time", text)
+	}
+	if hour == 12 {
+		hour = 0
+	}
+	if match[3] == "pm" || match[3] == "" && match[1] == "12" {
+		hour += 12
+	}
+	return hour, minute, nil
+}
+
+// parseDay reads a weekday, today, tonight, tomorrow or a date, returning
+// the day it names on or after now and whether it was a weekday
+func parseDay(text string, now time.Time) (time.Time, bool, bool) {
+	text = strings.ToLower(text)
+	switch text {
+	case "today", "tonight":
+		return now, false, true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), false, true
+	}
+
+	for i, name := range dayNames {
+		// Any abbreviation of at least three letters, e.g. fri or thurs
+		if len(text) >= 3 && strings.HasPrefix(name, text) {
+			ahead := (i - int(now.Weekday()) + 7) % 7
+			return now.AddDate(0, 0, ahead), true, true
+		}
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", text, now.Location()); err == nil {
+		return date, false, true
+	}
+	if date, err := time.ParseInLocation("1/2", text, now.Location()); err == nil {
+		date = time.Date(now.Year(), date.Month(), date.Day(), 0, 0, 0, 0, now.Location())
+		if date.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+			date = date.AddDate(1, 0, 0)
+		}
+		return date, false, true
+	}
+	return time.Time{}, false, false
+}
@@ -0,0 +1,139 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+// slLocation loads SL time's zone, skipping the test without time zone data
+func slLocation(t *testing.T) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return location
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		text         string
+		hour, minute int
+		wantErr      bool
+	}{
+		{text: "8pm", hour: 20},
+		{text: "8am", hour: 8},
+		{text: "8:30PM", hour: 20, minute: 30},
+		{text: "12am", hour: 0},
+		{text: "12pm", hour: 12},
+		{text: "12:30am", hour: 0, minute: 30},
+		{text: "12:30pm", hour: 12, minute: 30},
+		{text: "12:30", hour: 12, minute: 30},
+		{text: "0:15", hour: 0, minute: 15},
+		{text: "20:00", hour: 20},
+		{text: "noon", hour: 12},
+		{text: "Midnight", hour: 0},
+		{text: "8", wantErr: true},
+		{text: "12", wantErr: true},
+		{text: "0am", wantErr: true},
+		{text: "13pm", wantErr: true},
+		{text: "24:00", wantErr: true},
+		{text: "8:60", wantErr: true},
+		{text: "8:5pm", wantErr: true},
+		{text: "soon", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			hour, minute, err := parseClock(test.text)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parseClock(%q) = %d:%02d, want an error", test.text, hour, minute)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseClock(%q) returned an error: %v", test.text, err)
+			}
+			if hour != test.hour || minute != test.minute {
+				t.Errorf("parseClock(%q) = %d:%02d, want %d:%02d", test.text, hour, minute, test.hour, test.minute)
+			}
+		})
+	}
+}
+
+func TestParseWhen(t *testing.T) {
+	sl := slLocation(t)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, sl)
+	}
+	wednesday := at(2026, 10, 14, 15, 0)
+
+	tests := []struct {
+		name    string
+		text    string
+		now     time.Time
+		title   string
+		start   time.Time
+		wantErr bool
+	}{
+		{"weekday", "Jazz Night fri 8pm", wednesday, "Jazz Night", at(2026, 10, 16, 20, 0), false},
+		{"connecting words and a spaced suffix", "Jazz Night on Friday at 8 PM", wednesday, "Jazz Night", at(2026, 10, 16, 20, 0), false},
+		{"later today", "Quiz 7:30pm", wednesday, "Quiz", at(2026, 10, 14, 19, 30), false},
+		{"bare time already passed", "Quiz 9am", wednesday, "Quiz", at(2026, 10, 15, 9, 0), false},
+		{"noon tomorrow", "Dance tomorrow noon", wednesday, "Dance", at(2026, 10, 15, 12, 0), false},
+		{"today's weekday later on", "Party wed 8pm", wednesday, "Party", at(2026, 10, 14, 20, 0), false},
+		{"today's weekday already passed", "Brunch wed 10am", wednesday, "Brunch", at(2026, 10, 21, 10, 0), false},
+		{"weekday into next month", "Jam mon 8pm", at(2026, 10, 30, 12, 0), "Jam", at(2026, 11, 2, 20, 0), false},
+		{"12am is midnight", "Late Show sat 12am", wednesday, "Late Show", at(2026, 10, 17, 0, 0), false},
+		{"12pm is noon", "Lunch thu 12pm", wednesday, "Lunch", at(2026, 10, 15, 12, 0), false},
+		{"iso date", "Gala 2026-10-23 20:00", wednesday, "Gala", at(2026, 10, 23, 20, 0), false},
+		{"month and day", "Gala on 10/23 at 8pm", wednesday, "Gala", at(2026, 10, 23, 20, 0), false},
+		{"month and day today", "Gala 10/14 8pm", wednesday, "Gala", at(2026, 10, 14, 20, 0), false},
+		{"month and day rolls over the year", "Gala 10/13 8pm", wednesday, "Gala", at(2027, 10, 13, 20, 0), false},
+		{"new year", "Countdown 1/1 midnight", at(2026, 12, 30, 21, 0), "Countdown", at(2027, 1, 1, 0, 0), false},
+		{"weekday keeps its clock reading when DST starts", "Brunch sat 8pm", at(2026, 3, 7, 21, 0), "Brunch", at(2026, 3, 14, 20, 0), false},
+		{"weekday keeps its clock reading when DST ends", "Brunch sat 8pm", at(2026, 10, 31, 21, 0), "Brunch", at(2026, 11, 7, 20, 0), false},
+		{"repeated hour when DST ends", "Owls sun 1:30am", at(2026, 10, 31, 21, 0), "Owls", at(2026, 11, 1, 1, 30), false},
+		{"explicit day in the past", "Quiz today 9am", wednesday, "", time.Time{}, true},
+		{"explicit date in the past", "Gala 2026-10-01 8pm", wednesday, "", time.Time{}, true},
+		{"no title", "fri 8pm", wednesday, "", time.Time{}, true},
+		{"no time", "Jazz Night fri", wednesday, "", time.Time{}, true},
+		{"invalid time", "Jazz Night 13pm", wednesday, "", time.Time{}, true},
+		{"nothing", "", wednesday, "", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			title, start, err := ParseWhen(test.text, test.now)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ParseWhen(%q) = %q at %v, want an error", test.text, title, start)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWhen(%q) returned an error: %v", test.text, err)
+			}
+			if title != test.title {
+				t.Errorf("ParseWhen(%q) title = %q, want %q", test.text, title, test.title)
+			}
+			if !start.Equal(test.start) {
+				t.Errorf("ParseWhen(%q) start = %v, want %v", test.text, start, test.start)
+			}
+		})
+	}
+}
+
+func TestParseWhenSkippedHour(t *testing.T) {
+	sl := slLocation(t)
+
+	// 2:30am doesn't exist in SL time the night DST starts; the clocks read 3:30
+	_, start, err := ParseWhen("Owls sun 2:30am", time.Date(2026, 3, 7, 21, 0, 0, 0, sl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start.Day() != 8 || start.Hour() != 3 || start.Minute() != 30 {
+		t.Errorf("start = %v, want Mar 8 3:30 PDT", start)
+	}
+}
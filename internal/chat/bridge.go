@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"slbot/internal/bridge"
+	"slbot/internal/moderation"
+	"slbot/internal/types"
+)
+
+// relayChat passes a chat message heard in world to the chat bridge
+func (p *Processor) relayChat(notification map[string]interface{}, message types.ChatMessage) {
+	if !p.bridge.IsEnabled() {
+		return
+	}
+
+	channel := 0
+	if value, ok := notification["channel"].(string); ok {
+		channel, _ = strconv.Atoi(value)
+	}
+	p.bridge.Relay(message.Type, channel, message.Avatar, message.UUID, message.Message, p.corradeClient.GetCurrentRegion())
+}
+
+// HandleBridgeMessage says a message from the external chat system in
+// local chat, or IMs it to an avatar, with the sender's name in front
+func (p *Processor) HandleBridgeMessage(inbound bridge.Inbound) error {
+	text, err := p.bridge.Format(inbound)
+	if err != nil {
+		return err
+	}
+	text = p.truncate(text)
+
+	message := types.ChatMessage{
+		Type:    "local",
+		Avatar:  inbound.Name,
+		Message: inbound.Text,
+	}
+	if verdict := p.moderate(message, moderation.Outgoing, text); !verdict.Allowed {
+		return fmt.Errorf("the message was blocked by moderation")
+	}
+	p.bridge.Saying(text)
+
+	if inbound.To != "" {
+		err = p.corradeClient.Whisper(inbound.To, text)
+	} else {
+		err = p.corradeClient.Tell(text)
+	}
+	if err != nil {
+		log.Printf("Bridge: failed to pass on a message from %s: %v", inbound.Name, err)
+		return err
+	}
+
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "bridge",
+		Avatar:    inbound.Name,
+		Message:   inbound.Text,
+		Response:  text,
+	})
+	return nil
+}
+
+// GetBridge returns the chat bridge for external access
+func (p *Processor) GetBridge() *bridge.Bridge {
+	return p.bridge
+}
@@ -0,0 +1,149 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"slbot/internal/calendar"
+	"slbot/internal/slfunc"
+	"slbot/internal/types"
+)
+
+// calendarRoutine reminds subscribers and announces events shortly before they start
+func (p *Processor) calendarRoutine(ctx context.Context) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reminders, announcements := p.calendar.Due(now)
+			for _, event := range reminders {
+				p.remindSubscribers(event, now)
+			}
+			for _, event := range announcements {
+				p.announceEvent(event, now)
+			}
+		}
+	}
+}
+
+// eventText renders a calendar message template for an event
+func (p *Processor) eventText(template string, event *calendar.Event, now time.Time, avatar string) string {
+	ctx := p.promptContext(types.ChatMessage{Avatar: avatar}, "")
+	ctx.Event = event.Title
+	ctx.Data = map[string]string{
+		"title":   event.Title,
+		"time":    formatEventTime(event.Start),
+		"minutes": strconv.Itoa(int(math.Ceil(event.Start.Sub(now).Minutes()))),
+	}
+	return p.truncate(p.buildPrompt(template, ctx))
+}
+
+// remindSubscribers sends each subscriber an IM about an event. Subscribers
+// who couldn't be reached are tried again on the next tick.
+func (p *Processor) remindSubscribers(event *calendar.Event, now time.Time) {
+	if !p.corradeClient.IsOnline() {
+		log.Printf("Not reminding subscribers about %s, the bot is offline", event.Title)
+		return
+	}
+
+	reminded := 0
+	for uuid, name := range event.Subscribers {
+		text, ok := p.moderateOutgoing(types.ChatMessage{Type: "message", Avatar: name, UUID: uuid}, p.eventText(p.config.Calendar.ReminderMessage, event, now, name))
+		if !ok {
+			// Blocked by moderation; trying again would only be blocked again
+			p.calendar.MarkReminded(event.ID, uuid)
+			continue
+		}
+		if err := p.corradeClient.Whisper(uuid, text); err != nil {
+			log.Printf("Failed to remind %s about %s: %v", name, event.Title, err)
+			continue
+		}
+		p.calendar.MarkReminded(event.ID, uuid)
+		reminded++
+	}
+	if reminded > 0 {
+		p.SystemLog("Reminded %d subscribers about %s", reminded, event.Title)
+	}
+}
+
+// announceEvent says in local chat that an event is about to start. If it
+// can't, it is tried again on the next tick.
+func (p *Processor) announceEvent(event *calendar.Event, now time.Time) {
+	if !p.corradeClient.IsOnline() {
+		log.Printf("Not announcing %s, the bot is offline", event.Title)
+		return
+	}
+
+	text, ok := p.moderateOutgoing(types.ChatMessage{Type: "local", Avatar: "Calendar"}, p.eventText(p.config.Calendar.AnnounceMessage, event, now, ""))
+	if !ok {
+		// Blocked by moderation; trying again would only be blocked again
+		p.calendar.MarkAnnounced(event.ID)
+		return
+	}
+	if err := p.corradeClient.Tell(text); err != nil {
+		log.Printf("Failed to announce %s: %v", event.Title, err)
+		return
+	}
+	p.calendar.MarkAnnounced(event.ID)
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    "Calendar",
+		Message:   event.Title,
+		Response:  text,
+	})
+}
+
+// formatEventTime shows when an event starts in SL time
+func formatEventTime(start time.Time) string {
+	return slfunc.SLTime(start).Format("Mon Jan 2 3:04 PM")
+}
+
+// AddEvent puts an event on the calendar from text like "Jazz Night fri 8pm" in SL time
+func (p *Processor) AddEvent(text, createdBy string) (*calendar.Event, error) {
+	title, start, err := calendar.ParseWhen(text, slfunc.SLTime(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	return p.AddEventAt(title, start, createdBy)
+}
+
+// AddEventAt puts an event on the calendar
+func (p *Processor) AddEventAt(title string, start time.Time, createdBy string) (*calendar.Event, error) {
+	event, err := p.calendar.Add(title, start, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	p.SystemLog("%s added event %s on %s SLT", createdBy, event.Title, formatEventTime(event.Start))
+	return event, nil
+}
+
+// CancelEvent removes an event and lets its subscribers know
+func (p *Processor) CancelEvent(query, cancelledBy string) (*calendar.Event, error) {
+	event, err := p.calendar.Cancel(query)
+	if err != nil {
+		return nil, err
+	}
+
+	for uuid := range event.Subscribers {
+		notice := fmt.Sprintf("Sorry, %s on %s SLT has been cancelled.", event.Title, formatEventTime(event.Start))
+		if err := p.corradeClient.Whisper(uuid, notice); err != nil {
+			log.Printf("Failed to tell %s that %s was cancelled: %v", uuid, event.Title, err)
+		}
+	}
+	p.SystemLog("%s cancelled event %s", cancelledBy, event.Title)
+	return event, nil
+}
+
+// GetCalendar returns the event calendar for external access
+func (p *Processor) GetCalendar() *calendar.Manager {
+	return p.calendar
+}
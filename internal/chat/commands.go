@@ -0,0 +1,1070 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"slbot/internal/commands"
+	"slbot/internal/language"
+	"slbot/internal/roles"
+	"slbot/internal/types"
+)
+
+// registerCommands declares all chat commands understood by the bot
+func (p *Processor) registerCommands() {
+	r := p.commands
+
+	// General commands
+	r.MustRegister(&commands.Command{
+		Name:    "help",
+		Args:    []commands.Arg{{Name: "command", Optional: true, Rest: true}},
+		Help:    "Lists the commands you can use, or explains one of them.",
+		Handler: p.cmdHelp,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "status",
+		Help:    "Tells where I am and what I'm doing.",
+		Handler: p.cmdStatus,
+	})
+
+	// Movement commands
+	r.MustRegister(&commands.Command{
+		Name:    "follow me",
+		Aliases: []string{"come here"},
+		Help:    "I'll follow you around.",
+		Handler: p.cmdFollow,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "stop following",
+		Aliases: []string{"stay here"},
+		Help:    "I'll stop following and stay where I am.",
+		Handler: p.cmdStopFollowing,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "sit on",
+		Args:    []commands.Arg{{Name: "object", Rest: true}},
+		Help:    "I'll sit on the named object.",
+		Handler: p.cmdSitOn,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "stand up",
+		Aliases: []string{"get up"},
+		Help:    "I'll stand up if I'm sitting.",
+		Handler: p.cmdStandUp,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "go to",
+		Args:    []commands.Arg{{Name: "x"}, {Name: "y"}, {Name: "z"}},
+		Help:    "I'll walk to the given region coordinates.",
+		Handler: p.cmdGoTo,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "walk to",
+		Args:    []commands.Arg{{Name: "place", Rest: true}},
+		Help:    "I'll walk to a landmark in this region.",
+		Handler: p.cmdWalkTo,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "teleport to",
+		Args:    []commands.Arg{{Name: "place", Rest: true}},
+		Role:    roles.Staff,
+		Help:    "I'll teleport to a landmark.",
+		Handler: p.cmdTeleportTo,
+	})
+
+	// Macro commands
+	r.MustRegister(&commands.Command{
+		Name:    "record macro",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Starts recording my actions into a new macro.",
+		Handler: p.cmdRecordMacro,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "stop recording",
+		Args:    []commands.Arg{{Name: "options", Optional: true, Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Saves the macro being recorded. Options: idle, autogreet, tags a,b, description text.",
+		Handler: p.cmdStopRecording,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "cancel recording",
+		Role:    roles.Admin,
+		Help:    "Discards the macro being recorded.",
+		Handler: p.cmdCancelRecording,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "play macro",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Staff,
+		Help:    "Plays a saved macro.",
+		Handler: p.cmdPlayMacro,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "list macros",
+		Role:    roles.Staff,
+		Help:    "Lists the saved macros.",
+		Handler: p.cmdListMacros,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "delete macro",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Deletes a saved macro.",
+		Handler: p.cmdDeleteMacro,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "set idle",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Marks a macro as an idle behavior.",
+		Handler: p.cmdSetIdle,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "unset idle",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Removes a macro from the idle behaviors.",
+		Handler: p.cmdUnsetIdle,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "list idle",
+		Role:    roles.Admin,
+		Help:    "Lists the idle behavior macros.",
+		Handler: p.cmdListIdle,
+	})
+
+	// Avatar tracking and auto-greet commands
+	r.MustRegister(&commands.Command{
+		Name:    "set autogreet",
+		Args:    []commands.Arg{{Name: "macro", Optional: true, Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Greets new arrivals with the given macro, or only by the greeting policies when none is given.",
+		Handler: p.cmdSetAutoGreet,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "disable autogreet",
+		Aliases: []string{"stop autogreet"},
+		Role:    roles.Admin,
+		Help:    "Stops greeting new arrivals.",
+		Handler: p.cmdDisableAutoGreet,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "autogreet status",
+		Aliases: []string{"show autogreet"},
+		Role:    roles.Admin,
+		Help:    "Tells whether auto-greet is enabled.",
+		Handler: p.cmdAutoGreetStatus,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "list avatars",
+		Aliases: []string{"who is here"},
+		Role:    roles.Staff,
+		Help:    "Lists the avatars in the region.",
+		Handler: p.cmdListAvatars,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "set autogreet macro",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Marks a macro as an auto-greet macro.",
+		Handler: p.cmdSetAutoGreetMacro,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "unset autogreet macro",
+		Args:    []commands.Arg{{Name: "name", Rest: true}},
+		Role:    roles.Admin,
+		Help:    "Removes the auto-greet marking from a macro.",
+		Handler: p.cmdUnsetAutoGreetMacro,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "list autogreet",
+		Role:    roles.Admin,
+		Help:    "Lists the auto-greet macros.",
+		Handler: p.cmdListAutoGreet,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "don't greet me",
+		Aliases: []string{"dont greet me", "stop greeting me"},
+		Help:    "I won't greet you when you arrive.",
+		Handler: p.cmdGreetOptOut,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "greet me again",
+		Help:    "I'll greet you when you arrive again.",
+		Handler: p.cmdGreetOptIn,
+	})
+
+	// Notecard commands
+	r.MustRegister(&commands.Command{
+		Name:    "reload notes",
+		Aliases: []string{"reload notecards"},
+		Role:    roles.Admin,
+		Help:    "Reads my FAQ notecards from inventory again.",
+		Handler: p.cmdReloadNotes,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "faq",
+		Aliases: []string{"lookup"},
+		Args:    []commands.Arg{{Name: "keyword", Rest: true}},
+		Help:    "Looks up a keyword in my FAQ notecards.",
+		Handler: p.cmdFAQ,
+	})
+
+	// Conversation commands
+	r.MustRegister(&commands.Command{
+		Name:    "forget our chat",
+		Aliases: []string{"forget our conversation"},
+		Help:    "I'll forget what we've talked about.",
+		Handler: p.cmdForgetChat,
+	})
+
+	// Language commands
+	r.MustRegister(&commands.Command{
+		Name:    "speak",
+		Args:    []commands.Arg{{Name: "language", Rest: true}},
+		Help:    "Tells me which language to answer you in, e.g. speak Spanish. Use speak auto to let me work it out.",
+		Handler: p.cmdSpeak,
+	})
+
+	// Persona commands
+	r.MustRegister(&commands.Command{
+		Name:    "persona",
+		Args:    []commands.Arg{{Name: "name", Optional: true, Rest: true}},
+		Role:    roles.Owner,
+		Help:    "Switches to another persona, or back to automatic with persona auto. Without a name, lists the personas.",
+		Handler: p.cmdPersona,
+	})
+
+	// Calendar commands
+	r.MustRegister(&commands.Command{
+		Name:    "add event",
+		Args:    []commands.Arg{{Name: "event", Rest: true}},
+		Role:    roles.Staff,
+		Help:    "Adds an event to the calendar in SL time, e.g. add event Jazz Night fri 8pm.",
+		Handler: p.cmdAddEvent,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "cancel event",
+		Aliases: []string{"remove event"},
+		Args:    []commands.Arg{{Name: "event", Rest: true}},
+		Role:    roles.Staff,
+		Help:    "Removes an event from the calendar and tells its subscribers.",
+		Handler: p.cmdCancelEvent,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "events",
+		Aliases: []string{"list events", "upcoming events"},
+		Help:    "Lists the upcoming events.",
+		Handler: p.cmdListEvents,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "remind me about",
+		Args:    []commands.Arg{{Name: "event", Rest: true}},
+		Help:    "I'll IM you before an event starts.",
+		Handler: p.cmdRemindMe,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "stop reminding me about",
+		Args:    []commands.Arg{{Name: "event", Rest: true}},
+		Help:    "Cancels a reminder about an event.",
+		Handler: p.cmdStopReminding,
+	})
+
+	// Message commands
+	r.MustRegister(&commands.Command{
+		Name:    "tell",
+		Args:    []commands.Arg{{Name: "avatar"}, {Name: "message", Optional: true, Rest: true}},
+		Help:    "Leaves a message I'll IM to an avatar when they're next around, e.g. tell \"Jane Doe\" the party moved to 9pm.",
+		Handler: p.cmdTell,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "my messages",
+		Aliases: []string{"pending messages"},
+		Help:    "Lists the messages you've left that haven't been delivered yet.",
+		Handler: p.cmdMyMessages,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "cancel message",
+		Args:    []commands.Arg{{Name: "message", Rest: true}},
+		Help:    "Cancels a message you've left, by its number or who it's for.",
+		Handler: p.cmdCancelMessage,
+	})
+
+	// Role commands
+	r.MustRegister(&commands.Command{
+		Name:    "grant",
+		Args:    []commands.Arg{{Name: "role"}, {Name: "avatar", Rest: true}},
+		Role:    roles.Owner,
+		Help:    "Gives a nearby avatar (or avatar UUID) a role: " + strings.Join(roles.Names()[:roles.Owner], ", ") + ".",
+		Handler: p.cmdGrantRole,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "revoke",
+		Args:    []commands.Arg{{Name: "avatar", Rest: true}},
+		Role:    roles.Owner,
+		Help:    "Removes the role given to an avatar.",
+		Handler: p.cmdRevokeRole,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "list roles",
+		Role:    roles.Admin,
+		Help:    "Lists the avatars that have been given roles.",
+		Handler: p.cmdListRoles,
+	})
+	r.MustRegister(&commands.Command{
+		Name:    "my role",
+		Help:    "Tells which role you have.",
+		Handler: p.cmdMyRole,
+	})
+
+	// Apply the required roles configured for individual commands
+	for _, cmd := range r.Commands() {
+		current := r.RoleOf(cmd)
+		if role := p.roleManager.CommandRole(cmd.Name, current); role != current {
+			r.SetRole(cmd.Name, role)
+		}
+	}
+}
+
+// authorizeCommand checks whether the sender of a message holds a role
+func (p *Processor) authorizeCommand(message types.ChatMessage, role roles.Role) bool {
+	return p.roleManager.Has(message.UUID, role)
+}
+
+// GetCommands returns the command registry for external access
+func (p *Processor) GetCommands() *commands.Registry {
+	return p.commands
+}
+
+// General command handlers
+
+func (p *Processor) cmdHelp(ctx *commands.Context) error {
+	// "help me find the shop" is a question, not a help lookup
+	topic := ctx.Arg("command")
+	if _, known := p.commands.Lookup(topic); topic != "" && !known {
+		return commands.ErrNotCommand
+	}
+	ctx.Reply(p.commands.Help(ctx.Message, topic))
+	return nil
+}
+
+func (p *Processor) cmdStatus(ctx *commands.Context) error {
+	status := p.corradeClient.GetStatus()
+	text := fmt.Sprintf("I'm in %s at %.0f, %.0f, %.0f.", status.CurrentSim, status.Position.X, status.Position.Y, status.Position.Z)
+	if status.IsFollowing {
+		text += fmt.Sprintf(" Following %s.", status.FollowTarget)
+	}
+	if status.IsSitting {
+		text += fmt.Sprintf(" Sitting on %s.", status.SitObject)
+	}
+	ctx.Reply(text)
+	return nil
+}
+
+// Movement command handlers
+
+func (p *Processor) cmdFollow(ctx *commands.Context) error {
+	avatar := ctx.Message.Avatar
+	if err := p.followAvatar(avatar); err != nil {
+		ctx.Reply("Sorry, I can't follow you right now.")
+		return err
+	}
+
+	ctx.Reply(fmt.Sprintf("Following %s!", avatar))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "movement",
+		Avatar:    avatar,
+		Message:   fmt.Sprintf("Started following %s", avatar),
+	})
+
+	// Record action if recording
+	p.recordAction("follow", map[string]interface{}{
+		"avatar": avatar,
+	})
+	return nil
+}
+
+func (p *Processor) cmdStopFollowing(ctx *commands.Context) error {
+	p.stopFollowing()
+	ctx.Reply("I've stopped following.")
+	p.recordAction("stop_follow", map[string]interface{}{})
+	return nil
+}
+
+func (p *Processor) cmdSitOn(ctx *commands.Context) error {
+	return p.handleSitCommand(ctx.Arg("object"), ctx.Message.Avatar, ctx.Reply)
+}
+
+func (p *Processor) cmdStandUp(ctx *commands.Context) error {
+	status := p.corradeClient.GetStatus()
+	if !status.IsSitting {
+		ctx.Reply("I'm already standing.")
+		return nil
+	}
+
+	if err := p.corradeClient.StandUp(); err != nil {
+		ctx.Reply("I'm having trouble standing up.")
+		return err
+	}
+
+	ctx.Reply("Standing up!")
+	p.recordAction("stand", map[string]interface{}{})
+	return nil
+}
+
+func (p *Processor) cmdGoTo(ctx *commands.Context) error {
+	coords := make([]float64, 3)
+	for i, name := range []string{"x", "y", "z"} {
+		value, err := strconv.ParseFloat(ctx.Arg(name), 64)
+		if err != nil {
+			ctx.Reply(fmt.Sprintf("Usage: %s", ctx.Command.Usage()))
+			return nil
+		}
+		coords[i] = value
+	}
+	x, y, z := coords[0], coords[1], coords[2]
+
+	if err := p.corradeClient.WalkTo(x, y, z); err != nil {
+		ctx.Reply("I can't reach that location.")
+		return err
+	}
+
+	ctx.Reply(fmt.Sprintf("Moving to %.0f, %.0f, %.0f", x, y, z))
+	p.recordAction("walk", map[string]interface{}{
+		"x": x,
+		"y": y,
+		"z": z,
+	})
+	return nil
+}
+
+func (p *Processor) cmdWalkTo(ctx *commands.Context) error {
+	landmark, exists := p.config.FindLandmark(ctx.Arg("place"))
+	if !exists {
+		ctx.Reply(fmt.Sprintf("I don't know a place called '%s'.", ctx.Arg("place")))
+		return nil
+	}
+
+	if region := p.corradeClient.GetCurrentRegion(); landmark.Region != "" && !strings.EqualFold(region, landmark.Region) {
+		ctx.Reply(fmt.Sprintf("%s is in %s, I can't walk there from here.", landmark.Name, landmark.Region))
+		return nil
+	}
+
+	if err := p.corradeClient.WalkTo(landmark.X, landmark.Y, landmark.Z); err != nil {
+		ctx.Reply("I can't reach that location.")
+		return err
+	}
+
+	ctx.Reply(fmt.Sprintf("Walking to %s.", landmark.Name))
+	p.recordAction("walk", map[string]interface{}{
+		"x": landmark.X,
+		"y": landmark.Y,
+		"z": landmark.Z,
+	})
+	return nil
+}
+
+func (p *Processor) cmdTeleportTo(ctx *commands.Context) error {
+	landmark, exists := p.config.FindLandmark(ctx.Arg("place"))
+	if !exists {
+		ctx.Reply(fmt.Sprintf("I don't know a place called '%s'.", ctx.Arg("place")))
+		return nil
+	}
+
+	region := landmark.Region
+	if region == "" {
+		region = p.corradeClient.GetCurrentRegion()
+	}
+
+	if err := p.corradeClient.Teleport(region, landmark.X, landmark.Y, landmark.Z); err != nil {
+		ctx.Reply(fmt.Sprintf("I couldn't teleport to %s.", landmark.Name))
+		return err
+	}
+
+	ctx.Reply(fmt.Sprintf("Teleporting to %s.", landmark.Name))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "movement",
+		Avatar:    ctx.Message.Avatar,
+		Message:   fmt.Sprintf("Teleported to %s (%s %.0f, %.0f, %.0f)", landmark.Name, region, landmark.X, landmark.Y, landmark.Z),
+	})
+	p.recordAction("teleport", map[string]interface{}{
+		"region": region,
+		"x":      landmark.X,
+		"y":      landmark.Y,
+		"z":      landmark.Z,
+	})
+	return nil
+}
+
+// Macro command handlers
+
+func (p *Processor) cmdRecordMacro(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.StartRecording(macroName, ctx.Message.Avatar); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot start recording: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Started recording macro '%s'. Perform actions then say 'stop recording'.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdStopRecording(ctx *commands.Context) error {
+	// Extract description, tags, and flags if provided
+	description := ""
+	tags := []string{}
+	isIdleBehavior := false
+	isAutoGreet := false
+
+	parts := strings.Fields(ctx.Arg("options"))
+	for i, part := range parts {
+		if strings.EqualFold(part, "description") && i+1 < len(parts) {
+			description = strings.Join(parts[i+1:], " ")
+			break
+		}
+		if strings.EqualFold(part, "tags") && i+1 < len(parts) {
+			tags = strings.Split(parts[i+1], ",")
+			// Clean up tags
+			for j := range tags {
+				tags[j] = strings.TrimSpace(tags[j])
+			}
+		}
+		if strings.EqualFold(part, "idle") {
+			isIdleBehavior = true
+		}
+		if strings.EqualFold(part, "autogreet") {
+			isAutoGreet = true
+		}
+	}
+
+	if err := p.macroManager.StopRecording(description, tags, isIdleBehavior, isAutoGreet); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot stop recording: %s", err.Error()))
+		return nil
+	}
+
+	response := "Recording stopped and macro saved!"
+	if isIdleBehavior {
+		response += " (marked as idle behavior)"
+	}
+	if isAutoGreet {
+		response += " (marked as auto-greet)"
+	}
+	ctx.Reply(response)
+	return nil
+}
+
+func (p *Processor) cmdCancelRecording(ctx *commands.Context) error {
+	if err := p.macroManager.CancelRecording(); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot cancel recording: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply("Recording cancelled.")
+	return nil
+}
+
+func (p *Processor) cmdPlayMacro(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.PlayMacro(macroName, ctx.Message.Avatar); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot play macro: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Playing macro '%s'...", macroName))
+	return nil
+}
+
+func (p *Processor) cmdListMacros(ctx *commands.Context) error {
+	macros := p.macroManager.GetMacros()
+	if len(macros) == 0 {
+		ctx.Reply("No macros available.")
+		return nil
+	}
+
+	macroNames := make([]string, 0, len(macros))
+	for name := range macros {
+		macroNames = append(macroNames, name)
+	}
+	ctx.Reply(fmt.Sprintf("Available macros: %s", strings.Join(macroNames, ", ")))
+	return nil
+}
+
+func (p *Processor) cmdDeleteMacro(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.DeleteMacro(macroName, ctx.Message.Avatar); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot delete macro: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Deleted macro '%s'.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdSetIdle(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.SetIdleBehavior(macroName, ctx.Message.Avatar, true); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot set idle behavior: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Macro '%s' is now an idle behavior.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdUnsetIdle(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.SetIdleBehavior(macroName, ctx.Message.Avatar, false); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot unset idle behavior: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Macro '%s' is no longer an idle behavior.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdListIdle(ctx *commands.Context) error {
+	idleMacros := p.macroManager.GetIdleBehaviorMacros()
+	if len(idleMacros) == 0 {
+		ctx.Reply("No idle behavior macros configured.")
+		return nil
+	}
+
+	macroNames := make([]string, len(idleMacros))
+	for i, macro := range idleMacros {
+		macroNames[i] = macro.Name
+	}
+	ctx.Reply(fmt.Sprintf("Idle behaviors: %s", strings.Join(macroNames, ", ")))
+	return nil
+}
+
+// Avatar tracking and auto-greet command handlers
+
+func (p *Processor) cmdSetAutoGreet(ctx *commands.Context) error {
+	macroName := ctx.Arg("macro")
+	if macroName == "" {
+		if len(p.config.Greeting.Policies) == 0 {
+			ctx.Reply(fmt.Sprintf("Usage: %s", ctx.Command.Usage()))
+			return nil
+		}
+		p.corradeClient.SetAutoGreet(true, "")
+		ctx.Reply("Auto-greet enabled using the greeting policies.")
+		p.SystemLog("%s enabled auto-greet with the greeting policies", ctx.Message.Avatar)
+		return nil
+	}
+
+	// Check if macro exists
+	if _, exists := p.macroManager.GetMacro(macroName); !exists {
+		ctx.Reply(fmt.Sprintf("Macro '%s' not found.", macroName))
+		return nil
+	}
+
+	// Enable auto-greet with this macro
+	p.corradeClient.SetAutoGreet(true, macroName)
+	ctx.Reply(fmt.Sprintf("Auto-greet enabled using macro '%s'.", macroName))
+
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    ctx.Message.Avatar,
+		Message:   fmt.Sprintf("Auto-greet enabled with macro '%s'", macroName),
+	})
+	return nil
+}
+
+func (p *Processor) cmdDisableAutoGreet(ctx *commands.Context) error {
+	p.corradeClient.SetAutoGreet(false, "")
+	ctx.Reply("Auto-greet disabled.")
+
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    ctx.Message.Avatar,
+		Message:   "Auto-greet disabled",
+	})
+	return nil
+}
+
+func (p *Processor) cmdAutoGreetStatus(ctx *commands.Context) error {
+	enabled, macroName := p.corradeClient.GetAutoGreetConfig()
+	policies := len(p.config.Greeting.Policies)
+	if enabled && macroName != "" {
+		ctx.Reply(fmt.Sprintf("Auto-greet is enabled using macro '%s' and %d greeting policies, once every %d hours per avatar.", macroName, policies, p.config.Greeting.CooldownHours))
+	} else if enabled && policies > 0 {
+		ctx.Reply(fmt.Sprintf("Auto-greet is enabled using %d greeting policies, once every %d hours per avatar.", policies, p.config.Greeting.CooldownHours))
+	} else {
+		ctx.Reply("Auto-greet is disabled.")
+	}
+	return nil
+}
+
+func (p *Processor) cmdListAvatars(ctx *commands.Context) error {
+	avatars, err := p.corradeClient.GetNearbyAvatars()
+	if err != nil {
+		ctx.Reply("Error getting nearby avatars.")
+		return err
+	}
+
+	if len(avatars) == 0 {
+		ctx.Reply("No other avatars in the region.")
+		return nil
+	}
+
+	names := make([]string, 0, len(avatars))
+	for name := range avatars {
+		names = append(names, name)
+	}
+	ctx.Reply(fmt.Sprintf("Nearby avatars: %s", strings.Join(names, ", ")))
+	return nil
+}
+
+func (p *Processor) cmdSetAutoGreetMacro(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.SetAutoGreet(macroName, ctx.Message.Avatar, true); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot set auto-greet macro: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Macro '%s' marked as auto-greet macro.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdUnsetAutoGreetMacro(ctx *commands.Context) error {
+	macroName := ctx.Arg("name")
+	if err := p.macroManager.SetAutoGreet(macroName, ctx.Message.Avatar, false); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot unset auto-greet macro: %s", err.Error()))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Macro '%s' is no longer an auto-greet macro.", macroName))
+	return nil
+}
+
+func (p *Processor) cmdListAutoGreet(ctx *commands.Context) error {
+	autoGreetMacros := p.macroManager.GetAutoGreetMacros()
+	if len(autoGreetMacros) == 0 {
+		ctx.Reply("No auto-greet macros configured.")
+		return nil
+	}
+
+	macroNames := make([]string, len(autoGreetMacros))
+	for i, macro := range autoGreetMacros {
+		macroNames[i] = macro.Name
+	}
+	ctx.Reply(fmt.Sprintf("Auto-greet macros: %s", strings.Join(macroNames, ", ")))
+	return nil
+}
+
+func (p *Processor) cmdGreetOptOut(ctx *commands.Context) error {
+	if ctx.Message.UUID == "" {
+		return nil
+	}
+	p.greetings.OptOut(ctx.Message.UUID, ctx.Message.Avatar)
+	ctx.Reply("OK, I won't greet you when you arrive. Say \"greet me again\" if you change your mind.")
+	return nil
+}
+
+func (p *Processor) cmdGreetOptIn(ctx *commands.Context) error {
+	if !p.greetings.OptIn(ctx.Message.UUID) {
+		ctx.Reply("I'm already greeting you.")
+		return nil
+	}
+	ctx.Reply("OK, I'll greet you again when you arrive.")
+	return nil
+}
+
+// Notecard command handlers
+
+func (p *Processor) cmdReloadNotes(ctx *commands.Context) error {
+	if !p.notecardManager.IsEnabled() {
+		ctx.Reply("Notecard reading is disabled.")
+		return nil
+	}
+
+	ctx.Reply("Reloading notecards...")
+	go func() {
+		count, err := p.reloadNotecards(ctx.Message.Avatar)
+		if err != nil {
+			ctx.Reply("I couldn't reload my notecards.")
+			log.Printf("Notecard reload error: %v", err)
+			return
+		}
+		ctx.Reply(fmt.Sprintf("Reloaded %d notecards.", count))
+	}()
+	return nil
+}
+
+func (p *Processor) cmdFAQ(ctx *commands.Context) error {
+	query := ctx.Arg("keyword")
+	matches := p.notecardManager.Lookup(query, 1)
+	if len(matches) == 0 {
+		ctx.Reply(fmt.Sprintf("I couldn't find anything about '%s' in my notes.", query))
+		return nil
+	}
+
+	answer := p.truncate(matches[0].Text)
+	ctx.Reply(answer)
+
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "chat",
+		Avatar:    ctx.Message.Avatar,
+		Message:   ctx.Message.Message,
+		Response:  answer,
+	})
+	return nil
+}
+
+// Conversation command handlers
+
+func (p *Processor) cmdForgetChat(ctx *commands.Context) error {
+	if p.ClearConversation(ctx.Message.UUID, ctx.Message.Avatar) {
+		ctx.Reply("Done, I've forgotten our conversation.")
+	} else {
+		ctx.Reply("We don't have a conversation for me to forget.")
+	}
+	return nil
+}
+
+// Language command handlers
+
+// cmdSpeak sets the language to answer an avatar in. Anything that isn't a
+// language, e.g. "speak up", is left for the conversation.
+func (p *Processor) cmdSpeak(ctx *commands.Context) error {
+	if !p.languages.IsEnabled() {
+		return commands.ErrNotCommand
+	}
+
+	if strings.EqualFold(ctx.Arg("language"), "auto") {
+		p.languages.Clear(ctx.Message.UUID)
+		ctx.Reply("OK, I'll answer in whichever language you write in.")
+		return nil
+	}
+
+	code, ok := language.Lookup(ctx.Arg("language"))
+	if !ok {
+		return commands.ErrNotCommand
+	}
+
+	p.languages.Set(ctx.Message.UUID, ctx.Message.Avatar, code)
+	ctx.Reply(fmt.Sprintf("OK, I'll answer you in %s.", language.Name(code)))
+	return nil
+}
+
+// Persona command handlers
+
+func (p *Processor) cmdPersona(ctx *commands.Context) error {
+	name := ctx.Arg("name")
+	if name == "" {
+		names := make([]string, 0)
+		for _, info := range p.personas.List() {
+			names = append(names, info.Name)
+		}
+		mode := "chosen by hand"
+		if p.personas.Manual() == "" {
+			mode = "chosen automatically"
+		}
+		ctx.Reply(fmt.Sprintf("I'm using the %s persona (%s). Personas: %s.", p.personas.Active(), mode, strings.Join(names, ", ")))
+		return nil
+	}
+
+	active, err := p.SetPersona(name, ctx.Message.Avatar)
+	if err != nil {
+		ctx.Reply(err.Error())
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Now using the %s persona.", active))
+	return nil
+}
+
+// Calendar command handlers
+
+func (p *Processor) cmdAddEvent(ctx *commands.Context) error {
+	event, err := p.AddEvent(ctx.Arg("event"), ctx.Message.Avatar)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("I couldn't add that event: %v. Try e.g. add event Jazz Night fri 8pm", err))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Added %s on %s SLT. Visitors can say \"remind me about %s\".", event.Title, formatEventTime(event.Start), strings.ToLower(event.Title)))
+	return nil
+}
+
+func (p *Processor) cmdCancelEvent(ctx *commands.Context) error {
+	event, err := p.CancelEvent(ctx.Arg("event"), ctx.Message.Avatar)
+	if err != nil {
+		ctx.Reply(err.Error())
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Cancelled %s and told its %d subscribers.", event.Title, len(event.Subscribers)))
+	return nil
+}
+
+func (p *Processor) cmdListEvents(ctx *commands.Context) error {
+	events := p.calendar.Upcoming(time.Now())
+	if len(events) == 0 {
+		ctx.Reply("There are no upcoming events.")
+		return nil
+	}
+
+	listed := make([]string, 0, len(events))
+	for _, event := range events {
+		listed = append(listed, fmt.Sprintf("%s (%s)", event.Title, formatEventTime(event.Start)))
+	}
+	ctx.Reply(p.truncate("Upcoming events, SL time: " + strings.Join(listed, "; ")))
+	return nil
+}
+
+func (p *Processor) cmdRemindMe(ctx *commands.Context) error {
+	event, err := p.calendar.Subscribe(ctx.Arg("event"), ctx.Message.UUID, ctx.Message.Avatar)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Sorry, %v.", err))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("I'll IM you before %s starts on %s SLT.", event.Title, formatEventTime(event.Start)))
+	return nil
+}
+
+func (p *Processor) cmdStopReminding(ctx *commands.Context) error {
+	event, err := p.calendar.Unsubscribe(ctx.Arg("event"), ctx.Message.UUID)
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Sorry, %v.", err))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("OK, I won't remind you about %s.", event.Title))
+	return nil
+}
+
+// Message command handlers
+
+func (p *Processor) cmdTell(ctx *commands.Context) error {
+	toUUID, toName, ok := p.messageRecipient(ctx.Arg("avatar"))
+	if !ok || ctx.Arg("message") == "" {
+		return commands.ErrNotCommand
+	}
+
+	message, err := p.LeaveMessage(ctx.Message, toUUID, toName, ctx.Arg("message"))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Sorry, %v.", err))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("OK, I'll pass that on to %s when I see them (message %s).", message.To, message.ID))
+	return nil
+}
+
+func (p *Processor) cmdMyMessages(ctx *commands.Context) error {
+	messages := p.mailbox.From(ctx.Message.UUID)
+	if len(messages) == 0 {
+		ctx.Reply("You have no messages waiting to be delivered.")
+		return nil
+	}
+
+	listed := make([]string, 0, len(messages))
+	for _, message := range messages {
+		listed = append(listed, fmt.Sprintf("%s. for %s: %s", message.ID, message.To, message.Text))
+	}
+	ctx.Reply(p.truncate("Waiting to be delivered: " + strings.Join(listed, "; ")))
+	return nil
+}
+
+func (p *Processor) cmdCancelMessage(ctx *commands.Context) error {
+	message, err := p.mailbox.Cancel(ctx.Message.UUID, ctx.Arg("message"))
+	if err != nil {
+		ctx.Reply(fmt.Sprintf("Sorry, %v.", err))
+		return nil
+	}
+	ctx.Reply(fmt.Sprintf("Cancelled your message for %s.", message.To))
+	return nil
+}
+
+// Role command handlers
+
+func (p *Processor) cmdGrantRole(ctx *commands.Context) error {
+	role, err := roles.Parse(ctx.Arg("role"))
+	if err != nil {
+		ctx.Reply(err.Error())
+		return nil
+	}
+
+	uuid, name, err := p.resolveAvatar(ctx.Arg("avatar"))
+	if err != nil {
+		ctx.Reply(err.Error())
+		return nil
+	}
+
+	if err := p.roleManager.Grant(uuid, name, role, ctx.Message.Avatar); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot grant role: %s", err.Error()))
+		return nil
+	}
+
+	ctx.Reply(fmt.Sprintf("%s now has the %s role.", name, role))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    ctx.Message.Avatar,
+		Message:   fmt.Sprintf("Granted role %s to %s (%s)", role, name, uuid),
+	})
+	return nil
+}
+
+func (p *Processor) cmdRevokeRole(ctx *commands.Context) error {
+	uuid, name, err := p.resolveAvatar(ctx.Arg("avatar"))
+	if err != nil {
+		ctx.Reply(err.Error())
+		return nil
+	}
+
+	if err := p.roleManager.Revoke(uuid, ctx.Message.Avatar); err != nil {
+		ctx.Reply(fmt.Sprintf("Cannot revoke role: %s", err.Error()))
+		return nil
+	}
+
+	ctx.Reply(fmt.Sprintf("%s no longer has a role.", name))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    ctx.Message.Avatar,
+		Message:   fmt.Sprintf("Revoked role from %s (%s)", name, uuid),
+	})
+	return nil
+}
+
+func (p *Processor) cmdListRoles(ctx *commands.Context) error {
+	assignments := p.roleManager.Assignments()
+	if len(assignments) == 0 {
+		ctx.Reply("No roles have been assigned.")
+		return nil
+	}
+
+	entries := make([]string, len(assignments))
+	for i, assignment := range assignments {
+		entries[i] = fmt.Sprintf("%s (%s)", assignment.Name, assignment.Role)
+	}
+	ctx.Reply(fmt.Sprintf("Roles: %s", strings.Join(entries, ", ")))
+	return nil
+}
+
+func (p *Processor) cmdMyRole(ctx *commands.Context) error {
+	role := p.roleManager.RoleOf(ctx.Message.UUID)
+	ctx.Reply(fmt.Sprintf("You have the %s role.", role))
+	return nil
+}
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// resolveAvatar turns an avatar UUID or the name of a nearby avatar into a UUID and name
+func (p *Processor) resolveAvatar(avatar string) (string, string, error) {
+	avatar = strings.TrimSpace(avatar)
+	if uuidRegex.MatchString(avatar) {
+		name := avatar
+		for _, assignment := range p.roleManager.Assignments() {
+			if strings.EqualFold(assignment.UUID, avatar) {
+				name = assignment.Name
+			}
+		}
+		return avatar, name, nil
+	}
+
+	info, err := p.corradeClient.LookupAvatar(avatar)
+	if err != nil || info.UUID == "" {
+		return "", "", fmt.Errorf("I can't find %s nearby; try their UUID instead", avatar)
+	}
+	return info.UUID, info.Name, nil
+}
@@ -0,0 +1,74 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"slbot/internal/conversation"
+	"slbot/internal/llm"
+	"slbot/internal/types"
+)
+
+// formatTurns renders conversation turns as "Name: text" lines
+func (p *Processor) formatTurns(avatar string, turns []conversation.Turn) string {
+	var sb strings.Builder
+	for _, turn := range turns {
+		speaker := avatar
+		if turn.Role == conversation.RoleAssistant {
+			speaker = p.config.Bot.Name
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", speaker, turn.Text))
+	}
+	return sb.String()
+}
+
+// summarizeConversation folds the turns that no longer fit in the history
+// window into a short summary written by the model
+func (p *Processor) summarizeConversation(uuid string) {
+	previous, turns := p.conversations.Overflow(uuid)
+	if len(turns) == 0 {
+		return
+	}
+
+	conv := p.conversations.Get(uuid)
+	avatar := "the visitor"
+	if conv != nil {
+		avatar = conv.Avatar
+	}
+
+	text := p.formatTurns(avatar, turns)
+	if previous != "" {
+		text = "Summary so far: " + previous + "\n" + text
+	}
+
+	data := p.promptContext(types.ChatMessage{Avatar: avatar, UUID: uuid}, text)
+	prompt := p.buildPrompt(p.config.Conversation.SummaryPrompt, data)
+	summary, err := p.chatCompletion([]llm.Message{{Role: llm.RoleUser, Content: prompt}}, nil)
+	if err != nil {
+		log.Printf("Failed to summarize conversation with %s: %v", avatar, err)
+		summary = ""
+	}
+
+	p.conversations.Compact(uuid, summary, len(turns))
+}
+
+// ClearConversation forgets the conversation with an avatar
+func (p *Processor) ClearConversation(uuid, requestedBy string) bool {
+	cleared := p.conversations.Clear(uuid)
+	if cleared {
+		p.addLog(types.LogEntry{
+			Timestamp: time.Now(),
+			Type:      "system",
+			Avatar:    requestedBy,
+			Message:   fmt.Sprintf("Cleared conversation history for %s", uuid),
+		})
+	}
+	return cleared
+}
+
+// GetConversations returns the conversation store for external access
+func (p *Processor) GetConversations() *conversation.Store {
+	return p.conversations
+}
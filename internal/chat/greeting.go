@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/corrade"
+	"slbot/internal/greeting"
+	"slbot/internal/llm"
+	"slbot/internal/queue"
+	"slbot/internal/types"
+)
+
+// greetAvatars greets the avatars in the region who have stayed long
+// enough, are close enough and haven't been greeted within the cooldown
+func (p *Processor) greetAvatars(avatars map[string]*types.AvatarInfo) {
+	enabled, macro := p.corradeClient.GetAutoGreetConfig()
+	if !enabled || (macro == "" && len(p.config.Greeting.Policies) == 0) {
+		return
+	}
+
+	now := time.Now()
+	minDwell := time.Duration(p.config.Greeting.MinDwell) * time.Second
+	ownPosition := p.corradeClient.GetOwnPosition()
+
+	for _, avatar := range avatars {
+		// Skip avatars who are just teleporting through
+		if avatar.UUID == "" || now.Sub(avatar.FirstSeen) < minDwell {
+			continue
+		}
+		if p.greetings.IsQuiet(avatar.UUID, avatar.Name) {
+			continue
+		}
+		if p.greetings.RecentlyGreeted(avatar.UUID, now) {
+			// Greeted before stepping away; keep the dashboard in step
+			p.corradeClient.MarkAvatarGreeted(avatar.Name)
+			continue
+		}
+		if !p.inGreetingRadius(ownPosition, avatar.Position) {
+			continue
+		}
+
+		if err := p.greet(avatar, macro); err != nil {
+			log.Printf("Failed to greet %s: %v", avatar.Name, err)
+			continue
+		}
+		p.greetings.MarkGreeted(avatar.UUID, avatar.Name, now)
+		p.corradeClient.MarkAvatarGreeted(avatar.Name)
+	}
+}
+
+// inGreetingRadius reports whether an avatar is close enough to greet. Avatars
+// whose position isn't known yet are treated as close.
+func (p *Processor) inGreetingRadius(own, position types.Position) bool {
+	if p.config.Greeting.Radius <= 0 || own == (types.Position{}) || position == (types.Position{}) {
+		return true
+	}
+	return corrade.CalculateDistance(own, position) <= p.config.Greeting.Radius
+}
+
+// greet greets an avatar as the policy for their case of visitor says,
+// falling back to the auto-greet macro
+func (p *Processor) greet(avatar *types.AvatarInfo, macro string) error {
+	visits, lastVisit := 1, ""
+	if visitor, known := p.visitors.Get(avatar.UUID); known {
+		visits = visitor.Visits
+		if len(visitor.History) > 0 {
+			lastVisit = formatEventTime(visitor.History[len(visitor.History)-1].Left)
+		}
+	}
+	visitor := greeting.Case(visits)
+
+	policy, found := p.config.FindGreetingPolicy(visitor)
+	if !found {
+		policy = &config.GreetingPolicy{Case: visitor, Macro: macro}
+	}
+
+	message := types.ChatMessage{
+		Type:   "local",
+		Avatar: avatar.Name,
+		UUID:   avatar.UUID,
+	}
+	ctx := p.promptContext(message, "")
+	ctx.Event = "greeting"
+	ctx.Data = map[string]string{
+		"case":      visitor,
+		"visits":    strconv.Itoa(visits),
+		"lastVisit": lastVisit,
+	}
+
+	// The macro goes first; if another one is playing the avatar is tried again next scan
+	var done []string
+	if policy.Macro != "" {
+		if err := p.macroManager.PlayAutoGreetMacro(policy.Macro, avatar.Name); err != nil {
+			return fmt.Errorf("failed to play macro %s: %v", policy.Macro, err)
+		}
+		done = append(done, "played "+policy.Macro)
+	}
+
+	if policy.Say != "" {
+		text := p.truncate(p.buildPrompt(policy.Say, ctx))
+		p.sayModerated(message, text)
+		done = append(done, "said "+text)
+	}
+
+	if policy.IM != "" {
+		text := p.truncate(p.buildPrompt(policy.IM, ctx))
+		if err := p.corradeClient.Whisper(avatar.UUID, text); err != nil {
+			log.Printf("Failed to send greeting IM to %s: %v", avatar.Name, err)
+		} else {
+			done = append(done, "sent IM "+text)
+		}
+	}
+
+	if policy.Prompt != "" && p.llamaEnabled {
+		systemPrompt := p.buildPrompt(p.personas.Prompts().SystemPrompt, ctx)
+		userPrompt := p.buildPrompt(policy.Prompt, ctx)
+		run := func() {
+			if !p.rateLimiter.AllowLLM() {
+				log.Printf("Rate limit: too many LLM replies, skipping the greeting for %s", avatar.Name)
+				return
+			}
+			reply, err := p.chatCompletion([]llm.Message{
+				{Role: llm.RoleSystem, Content: systemPrompt},
+				{Role: llm.RoleUser, Content: userPrompt},
+			}, nil)
+			if err != nil {
+				log.Printf("Error getting Llama greeting for %s: %v", avatar.Name, err)
+				return
+			}
+			p.sayModerated(message, p.truncate(strings.TrimSpace(reply)))
+		}
+		if p.queue.Submit("greeting:"+avatar.UUID, run) != queue.Full {
+			done = append(done, "asked the LLM")
+		}
+	}
+
+	log.Printf("Greeted %s (%s visitor): %s", avatar.Name, visitor, strings.Join(done, ", "))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "avatar",
+		Avatar:    "System",
+		Message:   fmt.Sprintf("Greeted %s as a %s visitor", avatar.Name, visitor),
+		Response:  strings.Join(done, ", "),
+	})
+	return nil
+}
+
+// GetGreetings returns who has been greeted and who opted out for external access
+func (p *Processor) GetGreetings() *greeting.Manager {
+	return p.greetings
+}
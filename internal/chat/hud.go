@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"slbot/internal/hud"
+	"slbot/internal/moderation"
+	"slbot/internal/types"
+)
+
+// isHUDMessage reports whether a chat notification is a signed HUD request.
+// Requests are only accepted on the configured channel, which Corrade is
+// asked to relay, or by IM (e.g. llInstantMessage), so they never travel
+// over public local chat; the signature is what authenticates them.
+func (p *Processor) isHUDMessage(notification map[string]interface{}, eventType, text string) bool {
+	if !p.config.HUD.Enabled || !hud.Looks(text) {
+		return false
+	}
+	if eventType == "message" {
+		return true
+	}
+
+	channel := 0
+	if value, ok := notification["channel"].(string); ok {
+		channel, _ = strconv.Atoi(value)
+	}
+	return channel == p.config.HUD.Channel
+}
+
+// hudSender returns who a HUD request acts for: the owner of the object
+// that sent it, or the avatar itself
+func hudSender(notification map[string]interface{}, uuid string) string {
+	if owner, ok := notification["owner"].(string); ok && owner != "" {
+		return owner
+	}
+	return uuid
+}
+
+// handleHUD runs a signed request from a HUD or script with the chat
+// command handlers, answering on the response channel
+func (p *Processor) handleHUD(message types.ChatMessage) {
+	request, err := hud.Parse(message.Message)
+	if err == nil {
+		err = p.hudVerifier.Verify(request, time.Now())
+	}
+	if err != nil {
+		log.Printf("HUD: rejected request from %s: %v", message.Avatar, err)
+		p.addLog(types.LogEntry{
+			Timestamp: time.Now(),
+			Type:      "moderation",
+			Avatar:    message.Avatar,
+			Message:   fmt.Sprintf("Rejected HUD request: %v", err),
+			Response:  message.Message,
+		})
+		p.tellHUD(request, false, err.Error())
+		return
+	}
+
+	var replies []string
+	reply := func(text string) { replies = append(replies, text) }
+
+	// Arguments are moderated like chat before any command stores or repeats them
+	said := request.Text()
+	for _, value := range request.Args() {
+		said = strings.TrimSpace(said + " " + value)
+	}
+
+	command := request.Command()
+	switch {
+	case said != "" && !p.moderate(message, moderation.Incoming, said).Allowed:
+		err = fmt.Errorf("blocked by moderation")
+	case request.Text() != "":
+		command = request.Text()
+		if !p.commands.Dispatch(message, command, reply) {
+			err = fmt.Errorf("unknown command")
+		}
+	case command != "":
+		err = p.commands.Run(message, command, request.Args(), reply)
+	default:
+		err = fmt.Errorf("missing %s or %s", hud.FieldCommand, hud.FieldText)
+	}
+
+	response := strings.Join(replies, " ")
+	if err != nil {
+		response = strings.TrimSpace(err.Error() + ". " + response)
+	}
+
+	log.Printf("HUD - %s: %s | Bot: %s", message.Avatar, command, response)
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "hud",
+		Avatar:    message.Avatar,
+		Message:   command,
+		Response:  response,
+	})
+
+	p.tellHUD(request, err == nil, response)
+}
+
+// tellHUD says a response on the HUD response channel, shortening the
+// reply so the encoded response fits in one message
+func (p *Processor) tellHUD(request *hud.Request, ok bool, reply string) {
+	response, err := hud.FitResponse(request, ok, reply, p.config.Bot.MaxMessageLen)
+	if err == nil {
+		err = p.corradeClient.TellChannel(p.config.HUD.ResponseChannel, response)
+	}
+	if err != nil {
+		log.Printf("Error sending HUD response: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+
+	"slbot/internal/llm"
+	"slbot/internal/types"
+)
+
+// askIntent asks the model what a message is trying to do
+func (p *Processor) askIntent(ctx context.Context, text string) (string, error) {
+	if !p.llamaEnabled {
+		return "", fmt.Errorf("llm is disabled")
+	}
+
+	prompt := p.buildPrompt(p.config.Intent.Prompt, p.promptContext(types.ChatMessage{}, text))
+	return p.chatCompletion([]llm.Message{{Role: llm.RoleUser, Content: prompt}}, nil)
+}
@@ -0,0 +1,13 @@
+package chat
+
+// ReindexKnowledge updates the knowledge index and logs the result
+func (p *Processor) ReindexKnowledge(requestedBy string) (int, error) {
+	count, err := p.knowledgeManager.Reindex(p.ctx)
+	if err != nil {
+		p.SystemLog("Knowledge reindex requested by %s failed: %v", requestedBy, err)
+		return 0, err
+	}
+
+	p.SystemLog("Reindexed %d knowledge documents (requested by %s)", count, requestedBy)
+	return count, nil
+}
@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"slbot/internal/intent"
+	"slbot/internal/language"
+	"slbot/internal/llm"
+	"slbot/internal/types"
+)
+
+// streamMinChars is how much streamed text is collected before it is said at
+// the next sentence boundary, so the bot doesn't chat one word at a time
+const streamMinChars = 60
+
+// getLlamaResponse gets a response from the LLM provider to a chat message,
+// including the conversation history with the avatar when there is one. If
+// emit is set and streaming is enabled, complete sentences are passed to it
+// as they arrive. When tools are enabled the model may act for the sender.
+// The model is asked to reply in the given language.
+func (p *Processor) getLlamaResponse(message types.ChatMessage, prompt, context, lang string, emit func(string)) (string, error) {
+	history := p.conversations.Get(message.UUID)
+
+	data := p.promptContext(message, prompt)
+	data.Language = language.Name(lang)
+
+	// Use different prompts based on the message's intent
+	prompts := p.personas.Prompts()
+	var finalPrompt string
+	switch context {
+	case intent.Greeting:
+		finalPrompt = p.buildPrompt(prompts.GreetingPrompt, data)
+	case intent.Help:
+		finalPrompt = p.buildPrompt(prompts.HelpPrompt, data)
+	default:
+		finalPrompt = p.buildPrompt(prompts.ChatPrompt, data)
+	}
+
+	systemPrompt := p.buildPrompt(prompts.SystemPrompt, data)
+	if notes := p.notecardManager.PromptContext(); notes != "" {
+		systemPrompt += "\n\nUse these notes to answer questions:\n" + notes
+	}
+	if docs := p.knowledgeManager.PromptContext(p.ctx, prompt); docs != "" {
+		systemPrompt += "\n\nRelevant information from the venue's documents:\n" + docs
+	}
+	if history != nil && history.Summary != "" {
+		systemPrompt += fmt.Sprintf("\n\nEarlier you talked with %s about: %s", history.Avatar, history.Summary)
+	}
+	if p.languages.IsEnabled() {
+		systemPrompt += fmt.Sprintf("\n\nAlways reply in %s, the language %s speaks.", language.Name(lang), message.Avatar)
+	}
+
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: systemPrompt}}
+	if history != nil {
+		for _, turn := range history.Turns {
+			messages = append(messages, llm.Message{Role: turn.Role, Content: turn.Text})
+		}
+	}
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: finalPrompt, Said: prompt})
+
+	if caller, ok := p.provider.(llm.ToolCaller); ok && p.config.Llama.Tools {
+		return p.chatWithTools(caller, message, messages)
+	}
+
+	return p.chatCompletion(messages, emit)
+}
+
+// chatCompletion sends messages to the LLM provider and returns the reply.
+// The request is cancelled when the processor shuts down.
+func (p *Processor) chatCompletion(messages []llm.Message, emit func(string)) (string, error) {
+	if p.provider == nil {
+		return "", fmt.Errorf("no llm provider configured")
+	}
+
+	if !p.config.Llama.Stream || emit == nil {
+		return p.provider.Chat(p.ctx, messages, nil)
+	}
+
+	writer := &sentenceWriter{minLen: streamMinChars, maxLen: p.config.Bot.MaxMessageLen, emit: emit}
+	reply, err := p.provider.Chat(p.ctx, messages, writer.Write)
+	if err != nil {
+		return reply, err
+	}
+
+	writer.Flush()
+	return reply, nil
+}
+
+// sentenceWriter collects streamed text and emits it in whole sentences that
+// fit in a chat message
+type sentenceWriter struct {
+	buf    string
+	minLen int
+	maxLen int
+	emit   func(string)
+}
+
+// Write adds streamed text, emitting any sentences that are ready
+func (s *sentenceWriter) Write(text string) {
+	s.buf += text
+	for {
+		cut := s.cut()
+		if cut <= 0 {
+			return
+		}
+		s.send(s.buf[:cut])
+		s.buf = s.buf[cut:]
+	}
+}
+
+// Flush emits whatever text is left over
+func (s *sentenceWriter) Flush() {
+	for len(s.buf) > s.maxLen {
+		cut := s.cut()
+		s.send(s.buf[:cut])
+		s.buf = s.buf[cut:]
+	}
+	s.send(s.buf)
+	s.buf = ""
+}
+
+// send emits a piece of text unless it is blank
+func (s *sentenceWriter) send(text string) {
+	if text = strings.TrimSpace(text); text != "" {
+		s.emit(text)
+	}
+}
+
+// cut returns where the buffer should be split, or 0 if more text is needed
+func (s *sentenceWriter) cut() int {
+	if len(s.buf) > s.maxLen {
+		window := s.buf[:s.maxLen]
+		if end := lastSentenceEnd(window); end > 0 {
+			return end
+		}
+		if space := strings.LastIndex(window, " "); space > 0 {
+			return space + 1
+		}
+		// No break in sight, split at a character boundary
+		end := s.maxLen
+		for end > 0 && !utf8.RuneStart(s.buf[end]) {
+			end--
+		}
+		return end
+	}
+
+	if len(s.buf) >= s.minLen {
+		return lastSentenceEnd(s.buf)
+	}
+	return 0
+}
+
+// lastSentenceEnd returns the index just past the last sentence ending in
+// text that is followed by whitespace, or 0 if there is none
+func lastSentenceEnd(text string) int {
+	for i := len(text) - 2; i >= 0; i-- {
+		switch text[i] {
+		case '.', '!', '?', '\n':
+			if next := text[i+1]; next == ' ' || next == '\n' {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"slbot/internal/llm"
+	"slbot/internal/lsl"
+	"slbot/internal/queue"
+	"slbot/internal/types"
+)
+
+// HandleLSLEvent runs what the configuration says to do when an in-world
+// object reports an event: play a macro, say something in local chat
+// and/or say the LLM's answer to a prompt
+func (p *Processor) HandleLSLEvent(object *lsl.Object, name string, data map[string]string) error {
+	event, found := p.config.FindLSLEvent(name)
+	if !found {
+		return fmt.Errorf("unknown event %s", name)
+	}
+
+	// The object speaks for the event; moderation and the queue see it as the sender
+	message := types.ChatMessage{
+		Type:    "local",
+		Avatar:  object.Name,
+		UUID:    object.UUID,
+		Message: data["message"],
+	}
+	ctx := p.promptContext(message, data["message"])
+	ctx.Avatar = data["avatar"]
+	ctx.UUID = data["uuid"]
+	ctx.Event = event.Name
+	ctx.Data = data
+
+	var done []string
+	if event.Macro != "" {
+		if err := p.macroManager.PlayMacro(event.Macro, "LSL:"+object.Name); err != nil {
+			return fmt.Errorf("failed to play macro %s: %v", event.Macro, err)
+		}
+		done = append(done, "played "+event.Macro)
+	}
+
+	if event.Say != "" {
+		text := p.truncate(p.buildPrompt(event.Say, ctx))
+		p.sayModerated(message, text)
+		done = append(done, "said "+text)
+	}
+
+	if event.Prompt != "" && p.llamaEnabled {
+		systemPrompt := p.buildPrompt(p.personas.Prompts().SystemPrompt, ctx)
+		userPrompt := p.buildPrompt(event.Prompt, ctx)
+		run := func() {
+			if !p.rateLimiter.AllowLLM() {
+				log.Printf("Rate limit: too many LLM replies, skipping the reply to LSL event %s", event.Name)
+				return
+			}
+			reply, err := p.chatCompletion([]llm.Message{
+				{Role: llm.RoleSystem, Content: systemPrompt},
+				{Role: llm.RoleUser, Content: userPrompt},
+			}, nil)
+			if err != nil {
+				log.Printf("Error getting Llama response to LSL event %s: %v", event.Name, err)
+				return
+			}
+			p.sayModerated(message, p.truncate(strings.TrimSpace(reply)))
+		}
+		if p.queue.Submit("lsl:"+object.UUID, run) == queue.Full {
+			return fmt.Errorf("the LLM queue is full")
+		}
+		done = append(done, "asked the LLM")
+	}
+
+	log.Printf("LSL - %s: %s %v | Bot: %s", object.Name, event.Name, data, strings.Join(done, ", "))
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "lsl",
+		Avatar:    object.Name,
+		Message:   event.Name,
+		Response:  strings.Join(done, ", "),
+	})
+	return nil
+}
+
+// sayModerated says text in local chat if outgoing moderation allows it
+func (p *Processor) sayModerated(message types.ChatMessage, text string) {
+	if text == "" {
+		return
+	}
+	if text, ok := p.moderateOutgoing(message, text); ok {
+		p.reply(message, text)
+	}
+}
+
+// GetLSLRegistry returns the registry of objects trusted to send events
+func (p *Processor) GetLSLRegistry() *lsl.Registry {
+	return p.lslRegistry
+}
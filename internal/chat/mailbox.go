@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"slbot/internal/mailbox"
+	"slbot/internal/moderation"
+	"slbot/internal/types"
+)
+
+// messageRecipient works out who a message is for: a UUID, an avatar in
+// the region, a visitor seen before, or a full name given in quotes to be
+// matched when someone by that name turns up. Anything else is reported as
+// not a recipient, e.g. the "jokes" in "tell jokes about cats".
+func (p *Processor) messageRecipient(to string) (uuid, name string, ok bool) {
+	to = strings.TrimSpace(to)
+	if uuidRegex.MatchString(to) {
+		uuid, name, _ = p.resolveAvatar(to)
+		return uuid, name, true
+	}
+	if avatar := p.presentAvatar(to); avatar != nil {
+		return avatar.UUID, avatar.Name, true
+	}
+	if visitor, known := p.visitors.FindByName(to); known {
+		return visitor.UUID, visitor.Name, true
+	}
+	// Only quoted names have a space in a single argument
+	if strings.Contains(to, " ") {
+		return "", to, true
+	}
+	return "", "", false
+}
+
+// LeaveMessage stores a message from an avatar for a recipient found by
+// messageRecipient, if moderation allows the text
+func (p *Processor) LeaveMessage(sender types.ChatMessage, toUUID, toName, text string) (*mailbox.Message, error) {
+	if verdict := p.moderate(sender, moderation.Incoming, text); !verdict.Allowed {
+		return nil, fmt.Errorf("I can't pass that message on")
+	}
+
+	message, err := p.mailbox.Leave(sender.Avatar, sender.UUID, toName, toUUID, text)
+	if err != nil {
+		return nil, err
+	}
+	p.SystemLog("%s left a message for %s", sender.Avatar, message.To)
+
+	// Hand it over straight away if they're already here
+	if toUUID != "" && p.presentAvatar(toName) != nil {
+		go p.deliverMessages(toName, toUUID)
+	}
+	return message, nil
+}
+
+// presentAvatar finds an avatar in the region by full name, or by first
+// name if only one avatar has it
+func (p *Processor) presentAvatar(name string) *types.AvatarInfo {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	p.presenceMutex.Lock()
+	defer p.presenceMutex.Unlock()
+
+	var match *types.AvatarInfo
+	for _, avatar := range p.present {
+		full := strings.ToLower(avatar.Name)
+		if full == name || full == name+" resident" {
+			return avatar
+		}
+		if first, _, _ := strings.Cut(full, " "); first == name {
+			if match != nil {
+				return nil
+			}
+			match = avatar
+		}
+	}
+	return match
+}
+
+// deliverMessages IMs an avatar the messages left for them
+func (p *Processor) deliverMessages(name, uuid string) {
+	if uuid == "" {
+		return
+	}
+
+	for _, message := range p.mailbox.Take(name, uuid) {
+		ctx := p.promptContext(types.ChatMessage{Avatar: name, UUID: uuid}, message.Text)
+		ctx.Data = map[string]string{
+			"from": message.From,
+			"time": formatEventTime(message.Left),
+			"text": message.Text,
+		}
+		text := p.truncate(p.buildPrompt(p.config.Messages.DeliveryMessage, ctx))
+
+		if err := p.corradeClient.Whisper(uuid, text); err != nil {
+			log.Printf("Failed to deliver message %s to %s: %v", message.ID, name, err)
+			p.mailbox.Return(message)
+			continue
+		}
+		p.addLog(types.LogEntry{
+			Timestamp: time.Now(),
+			Type:      "system",
+			Avatar:    "Messages",
+			Message:   fmt.Sprintf("%s -> %s", message.From, name),
+			Response:  text,
+		})
+	}
+}
+
+// GetMailbox returns the messages waiting for avatars for external access
+func (p *Processor) GetMailbox() *mailbox.Manager {
+	return p.mailbox
+}
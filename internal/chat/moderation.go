@@ -0,0 +1,79 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"slbot/internal/llm"
+	"slbot/internal/moderation"
+	"slbot/internal/types"
+)
+
+// moderate checks a message to or from an avatar, logging and reporting
+// anything that is blocked
+func (p *Processor) moderate(message types.ChatMessage, direction, text string) moderation.Verdict {
+	verdict := p.moderator.Check(p.ctx, direction, text)
+	if verdict.Allowed {
+		return verdict
+	}
+
+	event := fmt.Sprintf("Blocked %s message (%s, action %s)", direction, verdict.Reason, verdict.Action)
+	log.Printf("Moderation: %s for %s: %s", event, message.Avatar, text)
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "moderation",
+		Avatar:    message.Avatar,
+		Message:   event,
+		Response:  text,
+	})
+
+	if p.moderator.NotifyOwners() {
+		notice := fmt.Sprintf("Moderation: blocked %s message in chat with %s (%s): %s", direction, message.Avatar, verdict.Reason, text)
+		for uuid := range p.roleManager.Owners() {
+			if err := p.corradeClient.Whisper(uuid, p.truncate(notice)); err != nil {
+				log.Printf("Failed to notify owner %s: %v", uuid, err)
+			}
+		}
+	}
+
+	return verdict
+}
+
+// moderateOutgoing checks something the bot is about to say, returning the
+// text to say instead, if any, and whether to say anything at all
+func (p *Processor) moderateOutgoing(message types.ChatMessage, text string) (string, bool) {
+	verdict := p.moderate(message, moderation.Outgoing, text)
+	switch {
+	case verdict.Allowed:
+		return text, true
+	case verdict.Action == moderation.ActionReplace:
+		return p.personas.Prompts().ErrorMessage, true
+	}
+	return "", false
+}
+
+// selfCheck asks the model whether a reply is fit to say in public chat
+func (p *Processor) selfCheck(ctx context.Context, text string) (bool, string, error) {
+	if !p.llamaEnabled {
+		return true, "", nil
+	}
+
+	prompt := p.buildPrompt(p.config.Moderation.SelfCheckPrompt, p.promptContext(types.ChatMessage{}, text))
+	answer, err := p.chatCompletion([]llm.Message{{Role: llm.RoleUser, Content: prompt}}, nil)
+	if err != nil {
+		return true, "", err
+	}
+
+	answer = strings.TrimSpace(answer)
+	if strings.HasPrefix(strings.ToUpper(answer), "UNSAFE") {
+		reason := strings.TrimSpace(strings.TrimLeft(answer[len("UNSAFE"):], " :-"))
+		if reason == "" {
+			reason = "flagged by the model"
+		}
+		return false, reason, nil
+	}
+	return true, "", nil
+}
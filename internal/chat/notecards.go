@@ -0,0 +1,18 @@
+package chat
+
+// reloadNotecards reads the notecards again and logs the result
+func (p *Processor) reloadNotecards(requestedBy string) (int, error) {
+	count, err := p.notecardManager.Reload()
+	if err != nil {
+		p.SystemLog("Notecard reload requested by %s failed: %v", requestedBy, err)
+		return 0, err
+	}
+
+	p.SystemLog("Reloaded %d notecards (requested by %s)", count, requestedBy)
+	return count, nil
+}
+
+// ReloadNotecards reloads the notecards on behalf of the web interface
+func (p *Processor) ReloadNotecards() (int, error) {
+	return p.reloadNotecards("WebInterface")
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"slbot/internal/persona"
+)
+
+// personaRoutine switches persona when the bot enters a region or a
+// scheduled time that a persona is bound to
+func (p *Processor) personaRoutine(ctx context.Context) {
+	// Pick the bound persona now rather than a minute after startup
+	p.updatePersona()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.updatePersona()
+		}
+	}
+}
+
+// updatePersona re-evaluates the persona bindings
+func (p *Processor) updatePersona() {
+	region := ""
+	if p.personas.NeedsRegion() {
+		region = p.corradeClient.GetCurrentRegion()
+	}
+
+	if active, changed := p.personas.Update(region, time.Now()); changed {
+		p.SystemLog("Switched to persona %s", active)
+	}
+}
+
+// SetPersona switches to a persona by name, or back to automatic selection with "auto"
+func (p *Processor) SetPersona(name, requestedBy string) (string, error) {
+	active, err := p.personas.Set(name)
+	if err != nil {
+		return "", err
+	}
+
+	if p.personas.Manual() == "" {
+		// Bindings may pick something other than the default straight away
+		p.updatePersona()
+		active = p.personas.Active()
+		p.SystemLog("%s returned to automatic persona selection, using %s", requestedBy, active)
+	} else {
+		p.SystemLog("%s switched to persona %s", requestedBy, active)
+	}
+	return active, nil
+}
+
+// GetPersonaManager returns the persona manager for external access
+func (p *Processor) GetPersonaManager() *persona.Manager {
+	return p.personas
+}
@@ -0,0 +1,138 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"slbot/internal/types"
+	"slbot/internal/visitors"
+	"slbot/internal/webhook"
+)
+
+// trackPresence compares the avatars in the region with the last scan and
+// reports who arrived and who left
+func (p *Processor) trackPresence(avatars map[string]*types.AvatarInfo) {
+	p.presenceMutex.Lock()
+	previous := p.present
+	p.present = avatars
+	p.presenceMutex.Unlock()
+
+	p.visitors.Seen(avatars, p.corradeClient.GetCurrentRegion(), time.Now())
+
+	// Hand over messages left for anyone in the region
+	for _, avatar := range avatars {
+		p.deliverMessages(avatar.Name, avatar.UUID)
+	}
+
+	// The first scan only sets the baseline
+	if previous == nil {
+		return
+	}
+
+	for name, avatar := range avatars {
+		if _, seen := previous[name]; !seen {
+			p.avatarArrived(avatar)
+		}
+	}
+	for name, avatar := range previous {
+		if _, here := avatars[name]; !here {
+			p.avatarLeft(avatar)
+		}
+	}
+}
+
+// avatarArrived is called when an avatar shows up in the region
+func (p *Processor) avatarArrived(avatar *types.AvatarInfo) {
+	p.webhooks.Emit(webhook.EventAvatarArrived, map[string]interface{}{
+		"name":   avatar.Name,
+		"uuid":   avatar.UUID,
+		"region": p.corradeClient.GetCurrentRegion(),
+	})
+}
+
+// avatarLeft is called when an avatar is no longer in the region
+func (p *Processor) avatarLeft(avatar *types.AvatarInfo) {
+	p.webhooks.Emit(webhook.EventAvatarLeft, map[string]interface{}{
+		"name":    avatar.Name,
+		"uuid":    avatar.UUID,
+		"region":  p.corradeClient.GetCurrentRegion(),
+		"seconds": int(avatar.LastSeen.Sub(avatar.FirstSeen).Seconds()),
+	})
+}
+
+// botStatusRoutine watches for the bot losing its connection or leaving
+// its home region
+func (p *Processor) botStatusRoutine(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	online, atHome := true, true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := p.corradeClient.TestConnection()
+		switch {
+		case err != nil && online:
+			online = false
+			p.SystemLog("Lost the connection to Corrade: %v", err)
+			p.webhooks.Emit(webhook.EventOffline, map[string]interface{}{"error": err.Error()})
+		case err == nil && !online:
+			online = true
+			p.SystemLog("Connection to Corrade restored")
+			p.webhooks.Emit(webhook.EventOnline, map[string]interface{}{})
+		}
+
+		if !online || p.config.Bot.Home == "" {
+			continue
+		}
+		region := p.corradeClient.GetCurrentRegion()
+		home := strings.EqualFold(strings.TrimSpace(region), strings.TrimSpace(p.config.Bot.Home))
+		if atHome && !home && region != "" {
+			p.webhooks.Emit(webhook.EventLeftHome, map[string]interface{}{
+				"region": region,
+				"home":   p.config.Bot.Home,
+			})
+		}
+		if region != "" {
+			atHome = home
+		}
+	}
+}
+
+// macroFinished is told by the macro manager when a macro has played
+func (p *Processor) macroFinished(name, requestedBy string, elapsed time.Duration) {
+	p.webhooks.Emit(webhook.EventMacroFinished, map[string]interface{}{
+		"macro":       name,
+		"requestedBy": requestedBy,
+		"seconds":     elapsed.Seconds(),
+	})
+}
+
+// paymentFields are the fields of a Corrade economy notification passed on to webhooks
+var paymentFields = []string{"agent", "firstname", "lastname", "amount", "transaction", "description"}
+
+// paymentReceived passes the known fields of a Corrade economy notification on to webhooks
+func (p *Processor) paymentReceived(notification map[string]interface{}) {
+	data := make(map[string]interface{}, len(paymentFields))
+	for _, key := range paymentFields {
+		if value, exists := notification[key]; exists {
+			data[key] = value
+		}
+	}
+	p.webhooks.Emit(webhook.EventPayment, data)
+}
+
+// GetVisitors returns the visitor registry for external access
+func (p *Processor) GetVisitors() *visitors.Registry {
+	return p.visitors
+}
+
+// GetWebhooks returns the webhook dispatcher for external access
+func (p *Processor) GetWebhooks() *webhook.Dispatcher {
+	return p.webhooks
+}
@@ -1,24 +1,41 @@
 package chat
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math/rand"
 	"net/http"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
 
+	"slbot/internal/bridge"
+	"slbot/internal/calendar"
+	"slbot/internal/commands"
 	"slbot/internal/config"
+	"slbot/internal/conversation"
 	"slbot/internal/corrade"
+	"slbot/internal/greeting"
+	"slbot/internal/hud"
+	"slbot/internal/intent"
+	"slbot/internal/knowledge"
+	"slbot/internal/language"
+	"slbot/internal/llm"
+	"slbot/internal/lsl"
 	"slbot/internal/macros"
-	"slbot/internal/types"
+	"slbot/internal/mailbox"
+	"slbot/internal/moderation"
+	"slbot/internal/notecards"
+	"slbot/internal/persona"
+	"slbot/internal/queue"
+	"slbot/internal/ratelimit"
+	"slbot/internal/roles"
+	"slbot/internal/scheduler"
 	"slbot/internal/slfunc"
+	"slbot/internal/types"
+	"slbot/internal/visitors"
+	"slbot/internal/webhook"
 )
 
 // Processor handles chat processing and AI responses
@@ -26,7 +43,32 @@ type Processor struct {
 	config                 *config.Config
 	corradeClient          *corrade.Client
 	macroManager           *macros.Manager
+	notecardManager        *notecards.Manager
+	knowledgeManager       *knowledge.Manager
+	commands               *commands.Registry
+	roleManager            *roles.Manager
+	moderator              *moderation.Moderator
+	rateLimiter            *ratelimit.Limiter
+	languages              *language.Manager
+	personas               *persona.Manager
+	queue                  *queue.Pool
+	classifier             *intent.Classifier
+	hudVerifier            *hud.Verifier
+	lslRegistry            *lsl.Registry
+	schedules              *scheduler.Manager
+	calendar               *calendar.Manager
+	mailbox                *mailbox.Manager
+	visitors               *visitors.Registry
+	greetings              *greeting.Manager
+	webhooks               *webhook.Dispatcher
+	bridge                 *bridge.Bridge
+	present                map[string]*types.AvatarInfo // Avatars seen in the last scan, by name
+	presenceMutex          sync.Mutex
+	conversations          *conversation.Store
 	httpClient             *http.Client
+	provider               llm.Provider
+	ctx                    context.Context // Cancelled on shutdown to abort Llama requests
+	cancel                 context.CancelFunc
 	followTarget           *types.FollowTarget
 	isFollowing            bool
 	logs                   []types.LogEntry
@@ -58,9 +100,83 @@ func NewProcessor(cfg *config.Config, corradeClient *corrade.Client) *Processor
 		avatarTrackingStopChan: make(chan struct{}),
 		lastAvatarScan:         time.Now(),
 	}
+	processor.ctx, processor.cancel = context.WithCancel(context.Background())
+
+	// Initialize the LLM provider
+	provider, err := llm.New(cfg.Llama, processor.httpClient)
+	if err != nil {
+		log.Printf("LLM provider error, disabling AI chat: %v", err)
+		processor.llamaEnabled = false
+	}
+	processor.provider = provider
+
+	// Initialize the knowledge base, using the provider's embeddings
+	embedder, _ := provider.(llm.Embedder)
+	processor.knowledgeManager = knowledge.NewManager(cfg, embedder)
+
+	// Initialize webhooks for bot events
+	processor.webhooks = webhook.NewDispatcher(cfg)
+
+	// Initialize the chat bridge to an external chat system
+	processor.bridge = bridge.NewBridge(cfg)
 
 	// Initialize macro manager
 	processor.macroManager = macros.NewManager(cfg, corradeClient)
+	processor.macroManager.SetOnFinished(processor.macroFinished)
+
+	// Initialize notecard manager
+	processor.notecardManager = notecards.NewManager(cfg, corradeClient)
+
+	// Initialize role manager
+	processor.roleManager = roles.NewManager(cfg)
+
+	// Initialize moderation, letting the model check its own replies
+	processor.moderator = moderation.NewModerator(cfg)
+	processor.moderator.SetSelfCheck(processor.selfCheck)
+
+	// Initialize the pool of workers answering with the model
+	processor.queue = queue.NewPool(cfg)
+
+	// Initialize flood protection
+	processor.rateLimiter = ratelimit.NewLimiter(cfg)
+
+	// Initialize personas
+	processor.personas = persona.NewManager(cfg)
+
+	// Initialize language detection and avatars' language preferences
+	processor.languages = language.NewManager(cfg)
+
+	// Initialize intent classification, asking the model when the rules aren't sure
+	processor.classifier = intent.NewClassifier(cfg)
+	processor.classifier.SetAsk(processor.askIntent)
+
+	// Initialize signature checks for HUD requests
+	processor.hudVerifier = hud.NewVerifier(cfg.HUD)
+
+	// Initialize the objects trusted to send events over HTTP
+	processor.lslRegistry = lsl.NewRegistry(cfg)
+
+	// Initialize scheduled tasks
+	processor.schedules = scheduler.NewManager(cfg)
+
+	// Initialize the event calendar
+	processor.calendar = calendar.NewManager(cfg)
+
+	// Initialize messages left for avatars who aren't around
+	processor.mailbox = mailbox.NewManager(cfg)
+
+	// Initialize the registry of everyone who has visited
+	processor.visitors = visitors.NewRegistry(cfg)
+
+	// Initialize greeting policies and who has been greeted
+	processor.greetings = greeting.NewManager(cfg)
+
+	// Initialize conversation history
+	processor.conversations = conversation.NewStore(cfg)
+
+	// Register chat commands
+	processor.commands = commands.NewRegistry(processor.authorizeCommand)
+	processor.registerCommands()
 
 	// Set the bot name in the corrade client for position queries
 	processor.corradeClient.SetBotName(cfg.Bot.Name)
@@ -68,21 +184,20 @@ func NewProcessor(cfg *config.Config, corradeClient *corrade.Client) *Processor
 	return processor
 }
 
-// TestConnection tests the connection to Llama (if enabled)
+// TestConnection tests the connection to the LLM provider (if enabled)
 func (p *Processor) TestConnection() error {
 	if !p.llamaEnabled {
 		log.Println("Llama chat is disabled - bot will use fallback responses")
 		return nil
 	}
 
-	_, err := p.getLlamaResponse("Hello, are you working?", "chat")
-	if err != nil {
-		log.Printf("Llama connection failed, disabling AI chat: %v", err)
+	if err := p.provider.TestConnection(p.ctx); err != nil {
+		log.Printf("LLM connection to %s failed, disabling AI chat: %v", p.provider.Name(), err)
 		p.llamaEnabled = false
 		return nil // Don't fail startup, just disable AI
 	}
 
-	log.Println("Llama connection successful")
+	log.Printf("LLM connection to %s successful", p.provider.Name())
 	return nil
 }
 
@@ -94,6 +209,15 @@ func (p *Processor) Start(ctx context.Context) error {
 		return err
 	}
 
+	// Start the workers answering questions with the model
+	p.queue.Start(ctx)
+
+	// Start posting events to webhooks
+	p.webhooks.Start(ctx)
+
+	// Start relaying chat over the bridge
+	p.bridge.Start(ctx)
+
 	// Start follow routine
 	go p.followRoutine(ctx)
 
@@ -103,25 +227,66 @@ func (p *Processor) Start(ctx context.Context) error {
 	// Start avatar tracking routine
 	go p.avatarTrackingRoutine(ctx)
 
+	// Switch personas bound to a region or schedule
+	go p.personaRoutine(ctx)
+
+	// Run scheduled tasks
+	go p.scheduleRoutine(ctx)
+
+	// Remind subscribers and announce upcoming events
+	go p.calendarRoutine(ctx)
+
+	// Watch the connection and home region
+	go p.botStatusRoutine(ctx)
+
+	// Read notecards if nothing was cached from a previous run
+	if p.notecardManager.IsEnabled() && p.notecardManager.Count() == 0 {
+		go p.reloadNotecards("System")
+	}
+
+	// Bring the knowledge index up to date with the document folder
+	if p.knowledgeManager.IsEnabled() {
+		go p.ReindexKnowledge("System")
+	}
+
 	// Keep the context alive
 	<-ctx.Done()
+
+	// Abort any Llama requests still in flight
+	p.cancel()
 	return nil
 }
 
 // setupNotifications sets up Corrade notifications for chat events
 func (p *Processor) setupNotifications() error {
 	// Set up notification for LocalChat
-	err := p.corradeClient.SetupNotification("local", fmt.Sprintf("http://localhost:%d/corrade/notifications", p.config.Bot.WebPort))
+	err := p.corradeClient.SetupNotification("local", p.config.NotificationURL())
 	if err != nil {
 		log.Printf("Failed to setup LocalChat notification: %v", err)
 	}
 
 	// Set up notification for InstantMessage
-	err = p.corradeClient.SetupNotification("message", fmt.Sprintf("http://localhost:%d/corrade/notifications", p.config.Bot.WebPort))
+	err = p.corradeClient.SetupNotification("message", p.config.NotificationURL())
 	if err != nil {
 		log.Printf("Failed to setup InstantMessage notification: %v", err)
 	}
 
+	// Relay the HUD command channel; agents otherwise only hear channel 0
+	if p.config.HUD.Enabled {
+		err = p.corradeClient.SetupChannelNotification(p.config.HUD.Channel, p.config.NotificationURL())
+		if err != nil {
+			log.Printf("Failed to setup HUD channel notification: %v", err)
+		}
+	}
+
+	// Set up notification for payments when a webhook wants them
+	if p.webhooks.Wants(webhook.EventPayment) {
+		err = p.corradeClient.SetupNotification("economy", p.config.NotificationURL())
+		if err != nil {
+			log.Printf("Failed to setup economy notification: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -148,59 +313,33 @@ func (p *Processor) avatarTrackingRoutine(ctx context.Context) {
 // scanForNewAvatars scans for new avatars and triggers auto-greet if configured
 func (p *Processor) scanForNewAvatars() {
 	// Get nearby avatars
-	//avatars, err := p.corradeClient.GetNearbyAvatars()
-	_, err := p.corradeClient.GetNearbyAvatars()
+	avatars, err := p.corradeClient.GetNearbyAvatars()
 	if err != nil {
 		log.Printf("Error scanning for avatars: %v", err)
 		return
 	}
+	p.trackPresence(avatars)
 
-	// Check for auto-greet configuration
-	autoGreetEnabled, autoGreetMacro := p.corradeClient.GetAutoGreetConfig()
-	if !autoGreetEnabled || autoGreetMacro == "" {
-		return
-	}
-
-	// Get new avatars that haven't been greeted
-	newAvatars := p.corradeClient.GetNewAvatars()
-
-	for _, avatar := range newAvatars {
-		// Only greet avatars that have been in the region for at least 10 seconds
-		// This prevents greeting avatars that are just teleporting through
-		if time.Since(avatar.FirstSeen) >= 10*time.Second {
-			log.Printf("Auto-greeting new avatar: %s", avatar.Name)
-
-			// Play the auto-greet macro
-			err := p.macroManager.PlayAutoGreetMacro(autoGreetMacro, avatar.Name)
-			if err != nil {
-				log.Printf("Failed to play auto-greet macro for %s: %v", avatar.Name, err)
-			} else {
-				// Mark avatar as greeted
-				p.corradeClient.MarkAvatarGreeted(avatar.Name)
-
-				// Log the auto-greet
-				p.addLog(types.LogEntry{
-					Timestamp: time.Now(),
-					Type:      "avatar",
-					Avatar:    "System",
-					Message:   fmt.Sprintf("Auto-greeted %s with macro '%s'", avatar.Name, autoGreetMacro),
-				})
-			}
-		}
-	}
+	// Greet avatars who have arrived, as the greeting policies say
+	p.greetAvatars(avatars)
 
 	p.lastAvatarScan = time.Now()
 }
 
 // HandleNotification processes incoming notifications from Corrade
 func (p *Processor) HandleNotification(notification map[string]interface{}) {
-   log.Printf("DEBUG: %q", notification)
+	log.Printf("DEBUG: %q", notification)
 	// Extract event type
 	eventType, ok := notification["type"].(string)
 	if !ok {
 		return
 	}
 
+	if eventType == "economy" {
+		p.paymentReceived(notification)
+		return
+	}
+
 	// Process LocalChat and InstantMessage events
 	if eventType == "local" || eventType == "message" {
 		// Extract message data
@@ -208,18 +347,40 @@ func (p *Processor) HandleNotification(notification map[string]interface{}) {
 		uuid, _ := notification["agent"].(string)
 		message, _ := notification["message"].(string)
 
-      if uuid == p.corradeClient.GetBotUUID() {
-         return
-      }
+		if uuid == p.corradeClient.GetBotUUID() {
+			return
+		}
+
+		// Signed commands from HUDs and scripts
+		if p.isHUDMessage(notification, eventType, message) {
+			go p.handleHUD(types.ChatMessage{
+				Avatar:  avatar,
+				UUID:    hudSender(notification, uuid),
+				Message: message,
+				Type:    eventType,
+			})
+			return
+		}
 
 		if avatar != "" && message != "" {
 			chatMessage := types.ChatMessage{
 				Avatar:  avatar,
-            UUID:    uuid,
+				UUID:    uuid,
 				Message: message,
 				Type:    eventType,
 			}
 
+			event := webhook.EventChat
+			if eventType == "message" {
+				event = webhook.EventIM
+			}
+			p.webhooks.Emit(event, map[string]interface{}{
+				"name":    avatar,
+				"uuid":    uuid,
+				"message": message,
+			})
+			p.relayChat(notification, chatMessage)
+
 			go p.processChat(chatMessage)
 		}
 	}
@@ -230,485 +391,127 @@ func (p *Processor) processChat(message types.ChatMessage) {
 	// Update last interaction time
 	p.lastInteractionTime = time.Now()
 
-   cleanMessage := strings.ToLower(message.Message)
-	cleanMessage = strings.TrimSpace(cleanMessage)
-   chatName := strings.ToLower(p.config.Bot.ChatName)
+	// Hand over any messages left for the sender
+	p.deliverMessages(message.Avatar, message.UUID)
 
-	// Check if bot is mentioned or being directly addressed
-	if !strings.HasPrefix(cleanMessage, chatName) && 
-      message.Type != "message" &&
-		!strings.HasPrefix(message.Message, "/") {
+	text, addressed := p.addressedText(message)
+	if !addressed {
 		return
 	}
 
-	// Handle movement commands
-	if p.handleMovementCommands(message) {
+	// Drop messages from avatars flooding the bot
+	if !p.allowMessage(message) {
 		return
 	}
 
-	// Handle macro commands
-	if p.handleMacroCommands(message) {
+	// Moderate the message before commands store or repeat any of it, or it reaches the model
+	if verdict := p.moderate(message, moderation.Incoming, text); !verdict.Allowed {
+		if verdict.Action == moderation.ActionReplace {
+			p.reply(message, p.personas.Prompts().ErrorMessage)
+		}
 		return
 	}
 
-	// Handle avatar tracking commands
-	if p.handleAvatarCommands(message) {
+	// Handle chat commands
+	if p.commands.Dispatch(message, text, func(reply string) { p.reply(message, reply) }) {
 		return
 	}
 
-	// Clean the message for processing
-	cleanMessage = strings.ReplaceAll(cleanMessage, chatName, "")
-	cleanMessage = strings.TrimSpace(cleanMessage)
-
-	// Determine context based on message content
-	context := "general"
-	if strings.Contains(cleanMessage, "hello") ||
-		strings.Contains(cleanMessage, "hi") ||
-		strings.Contains(cleanMessage, "hey") {
-		context = "greeting"
-	} else if strings.Contains(cleanMessage, "help") {
-		context = "help"
-	}
-
-	var response string
-	var err error
+	// Answer in the sender's language
+	lang := p.languages.Resolve(message.UUID, message.Avatar, text)
 
-	// Get response from Llama if enabled, otherwise use fallbacks
+	// Queue questions for the model so only a few are answered at once
 	if p.llamaEnabled {
-		response, err = p.getLlamaResponse(cleanMessage, context)
-		if err != nil {
-			log.Printf("Error getting Llama response: %v", err)
-			// Fall back to predefined responses if Llama fails
-			response = p.getFallbackResponse(context, cleanMessage)
-		}
-	} else {
-		response = p.getFallbackResponse(context, cleanMessage)
-	}
-
-	// Truncate response if too long for SL chat
-	if len(response) > p.config.Bot.MaxMessageLen {
-		response = response[:p.config.Bot.MaxMessageLen-3] + "..."
-	}
-
-	// Send response back to Second Life
-   if message.Type == "local" {
-   	if err := p.corradeClient.Tell(response); err != nil {
-   		log.Printf("Error sending response to SL: %v", err)
-   	}
-   } else {
-      log.Printf("DEBUG: %s", message.UUID)
-   	if err := p.corradeClient.Whisper(message.UUID, response); err != nil {
-   		log.Printf("Error sending response to SL: %v", err)
-   	}
-   }
-
-	log.Printf("%s - %s: %s | Bot: %s", message.Type, message.Avatar, message.Message, response)
-
-	// Log to web interface
-	p.addLog(types.LogEntry{
-		Timestamp: time.Now(),
-		Type:      "chat",
-		Avatar:    message.Avatar,
-		Message:   message.Message,
-		Response:  response,
-	})
-}
-
-// handleAvatarCommands processes avatar tracking and auto-greet commands
-func (p *Processor) handleAvatarCommands(message types.ChatMessage) bool {
-	msg := strings.ToLower(message.Message)
-
-	// Check if user is an owner
-	if !p.macroManager.IsOwner(message.Avatar) {
-		return false
-	}
-
-	// Enable auto-greet with specific macro
-	if strings.HasPrefix(msg, "set autogreet ") {
-		macroName := strings.TrimPrefix(message.Message, "set autogreet ")
-		macroName = strings.TrimPrefix(macroName, "Set autogreet ")
-		macroName = strings.TrimSpace(macroName)
-
-		// Check if macro exists
-		if _, exists := p.macroManager.GetMacro(macroName); !exists {
-			p.corradeClient.Tell(fmt.Sprintf("Macro '%s' not found.", macroName))
-			return true
-		}
-
-		// Enable auto-greet with this macro
-		p.corradeClient.SetAutoGreet(true, macroName)
-		p.corradeClient.Tell(fmt.Sprintf("Auto-greet enabled using macro '%s'.", macroName))
-
-		p.addLog(types.LogEntry{
-			Timestamp: time.Now(),
-			Type:      "system",
-			Avatar:    message.Avatar,
-			Message:   fmt.Sprintf("Auto-greet enabled with macro '%s'", macroName),
-		})
-		return true
-	}
-
-	// Disable auto-greet
-	if strings.Contains(msg, "disable autogreet") || strings.Contains(msg, "stop autogreet") {
-		p.corradeClient.SetAutoGreet(false, "")
-		p.corradeClient.Tell("Auto-greet disabled.")
-
-		p.addLog(types.LogEntry{
-			Timestamp: time.Now(),
-			Type:      "system",
-			Avatar:    message.Avatar,
-			Message:   "Auto-greet disabled",
-		})
-		return true
-	}
-
-	// Show auto-greet status
-	if strings.Contains(msg, "autogreet status") || strings.Contains(msg, "show autogreet") {
-		enabled, macroName := p.corradeClient.GetAutoGreetConfig()
-		if enabled && macroName != "" {
-			p.corradeClient.Tell(fmt.Sprintf("Auto-greet is enabled using macro '%s'.", macroName))
-		} else {
-			p.corradeClient.Tell("Auto-greet is disabled.")
-		}
-		return true
-	}
-
-	// List nearby avatars
-	if strings.Contains(msg, "list avatars") || strings.Contains(msg, "who is here") {
-		avatars, err := p.corradeClient.GetNearbyAvatars()
-		if err != nil {
-			p.corradeClient.Tell("Error getting nearby avatars.")
-			return true
-		}
-
-		if len(avatars) == 0 {
-			p.corradeClient.Tell("No other avatars in the region.")
-		} else {
-			names := make([]string, 0, len(avatars))
-			for name := range avatars {
-				names = append(names, name)
-			}
-			p.corradeClient.Tell(fmt.Sprintf("Nearby avatars: %s", strings.Join(names, ", ")))
+		switch p.queue.Submit(message.UUID, func() { p.answer(message, text, lang) }) {
+		case queue.Superseded:
+			log.Printf("Queue: replaced an unanswered question from %s with a newer one", message.Avatar)
+		case queue.Full:
+			log.Printf("Queue: full, telling %s the bot is busy", message.Avatar)
+			p.reply(message, p.config.Queue.BusyMessage)
 		}
-		return true
-	}
-
-	// Mark macro as auto-greet
-	if strings.HasPrefix(msg, "set autogreet macro ") {
-		macroName := strings.TrimPrefix(message.Message, "set autogreet macro ")
-		macroName = strings.TrimPrefix(macroName, "Set autogreet macro ")
-		macroName = strings.TrimSpace(macroName)
-
-		err := p.macroManager.SetAutoGreet(macroName, message.Avatar, true)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot set auto-greet macro: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Macro '%s' marked as auto-greet macro.", macroName))
-		}
-		return true
-	}
-
-	// Remove auto-greet marking from macro
-	if strings.HasPrefix(msg, "unset autogreet macro ") {
-		macroName := strings.TrimPrefix(message.Message, "unset autogreet macro ")
-		macroName = strings.TrimPrefix(macroName, "Unset autogreet macro ")
-		macroName = strings.TrimSpace(macroName)
-
-		err := p.macroManager.SetAutoGreet(macroName, message.Avatar, false)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot unset auto-greet macro: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Macro '%s' is no longer an auto-greet macro.", macroName))
-		}
-		return true
-	}
-
-	// List auto-greet macros
-	if strings.Contains(msg, "list autogreet") {
-		autoGreetMacros := p.macroManager.GetAutoGreetMacros()
-		if len(autoGreetMacros) == 0 {
-			p.corradeClient.Tell("No auto-greet macros configured.")
-		} else {
-			macroNames := make([]string, len(autoGreetMacros))
-			for i, macro := range autoGreetMacros {
-				macroNames[i] = macro.Name
-			}
-			p.corradeClient.Tell(fmt.Sprintf("Auto-greet macros: %s", strings.Join(macroNames, ", ")))
-		}
-		return true
-	}
-
-	return false
-}
-
-// handleMovementCommands processes movement and sitting commands
-func (p *Processor) handleMovementCommands(message types.ChatMessage) bool {
-	msg := strings.ToLower(message.Message)
-
-	// Follow commands
-	if strings.Contains(msg, "follow me") || strings.Contains(msg, "come here") {
-		err := p.followAvatar(message.Avatar)
-		if err != nil {
-			p.corradeClient.Tell("Sorry, I can't follow you right now.")
-			log.Printf("Follow error: %v", err)
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Following %s!", message.Avatar))
-			p.addLog(types.LogEntry{
-				Timestamp: time.Now(),
-				Type:      "movement",
-				Avatar:    message.Avatar,
-				Message:   fmt.Sprintf("Started following %s", message.Avatar),
-			})
-
-			// Record action if recording
-			p.recordAction("follow", map[string]interface{}{
-				"avatar": message.Avatar,
-			})
-		}
-		return true
-	}
-
-	// Stop following
-	if strings.Contains(msg, "stop following") || strings.Contains(msg, "stay here") {
-		p.stopFollowing()
-		p.corradeClient.Tell("I've stopped following.")
-		p.recordAction("stop_follow", map[string]interface{}{})
-		return true
-	}
-
-	// Sit commands
-	if strings.HasPrefix(msg, "sit on ") {
-		objectName := strings.TrimPrefix(msg, "sit on ")
-		objectName = strings.TrimSpace(objectName)
-
-		err := p.handleSitCommand(objectName, message.Avatar)
-		if err != nil {
-			log.Printf("Sit error: %v", err)
-		}
-		return true
-	}
-
-	// Stand up commands
-	if strings.Contains(msg, "stand up") || strings.Contains(msg, "get up") {
-		status := p.corradeClient.GetStatus()
-		if status.IsSitting {
-			err := p.corradeClient.StandUp()
-			if err != nil {
-				p.corradeClient.Tell("I'm having trouble standing up.")
-				log.Printf("Stand error: %v", err)
-			} else {
-				p.corradeClient.Tell("Standing up!")
-				p.recordAction("stand", map[string]interface{}{})
-			}
-		} else {
-			p.corradeClient.Tell("I'm already standing.")
-		}
-		return true
-	}
-
-	// Move to coordinates (e.g., "go to 128 128 22")
-	coordRegex := regexp.MustCompile(`go to (\d+(?:\.\d+)?) (\d+(?:\.\d+)?) (\d+(?:\.\d+)?)`)
-	matches := coordRegex.FindStringSubmatch(msg)
-	if len(matches) == 4 {
-		var x, y, z float64
-		fmt.Sscanf(matches[1], "%f", &x)
-		fmt.Sscanf(matches[2], "%f", &y)
-		fmt.Sscanf(matches[3], "%f", &z)
-
-		err := p.corradeClient.WalkTo(x, y, z)
-		if err != nil {
-			p.corradeClient.Tell("I can't reach that location.")
-			log.Printf("Walk error: %v", err)
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Moving to %.0f, %.0f, %.0f", x, y, z))
-			p.recordAction("walk", map[string]interface{}{
-				"x": x,
-				"y": y,
-				"z": z,
-			})
-		}
-		return true
+		return
 	}
 
-	return false
+	p.answer(message, text, lang)
 }
 
-// handleMacroCommands processes macro recording and playback commands
-func (p *Processor) handleMacroCommands(message types.ChatMessage) bool {
-	msg := strings.ToLower(message.Message)
-
-	// Check if user is an owner
-	if !p.macroManager.IsOwner(message.Avatar) {
-		return false
-	}
+// answer replies to a message addressed to the bot with the model or a
+// fallback response, in the given language
+func (p *Processor) answer(message types.ChatMessage, text, lang string) {
+	cleanMessage := strings.ToLower(text)
 
-	// Start recording macro
-	if strings.HasPrefix(msg, "record macro ") {
-		macroName := strings.TrimPrefix(message.Message, "record macro ")
-		macroName = strings.TrimPrefix(macroName, "Record macro ")
-		macroName = strings.TrimSpace(macroName)
+	// Work out what the message is trying to do to pick the prompt and fallback
+	classified := p.classifier.Classify(p.ctx, text)
+	context := classified.Intent
 
-		err := p.macroManager.StartRecording(macroName, message.Avatar)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot start recording: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Started recording macro '%s'. Perform actions then say 'stop recording'.", macroName))
-		}
-		return true
-	}
+	var response string
+	var err error
+	streamed := false
+	var said []string
 
-	// Stop recording macro with enhanced syntax
-	if strings.Contains(msg, "stop recording") {
-		// Extract description, tags, and flags if provided
-		description := ""
-		tags := []string{}
-		isIdleBehavior := false
-		isAutoGreet := false
-
-		// Parse extended stop recording syntax
-		parts := strings.Split(message.Message, " ")
-		for i, part := range parts {
-			if strings.EqualFold(part, "description") && i+1 < len(parts) {
-				description = strings.Join(parts[i+1:], " ")
-				break
-			}
-			if strings.EqualFold(part, "tags") && i+1 < len(parts) {
-				tagsPart := parts[i+1]
-				if strings.Contains(tagsPart, ",") {
-					tags = strings.Split(tagsPart, ",")
-				} else {
-					tags = []string{tagsPart}
-				}
-				// Clean up tags
-				for j := range tags {
-					tags[j] = strings.TrimSpace(tags[j])
-				}
-			}
-			if strings.EqualFold(part, "idle") {
-				isIdleBehavior = true
-			}
-			if strings.EqualFold(part, "autogreet") {
-				isAutoGreet = true
-			}
+	// say moderates a reply and sends it; nothing more is said once a part is blocked
+	blocked := false
+	say := func(part string) {
+		if blocked {
+			return
 		}
-
-		err := p.macroManager.StopRecording(description, tags, isIdleBehavior, isAutoGreet)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot stop recording: %s", err.Error()))
-		} else {
-			response := "Recording stopped and macro saved!"
-			if isIdleBehavior {
-				response += " (marked as idle behavior)"
-			}
-			if isAutoGreet {
-				response += " (marked as auto-greet)"
+		if verdict := p.moderate(message, moderation.Outgoing, part); !verdict.Allowed {
+			blocked = true
+			if verdict.Action != moderation.ActionReplace {
+				return
 			}
-			p.corradeClient.Tell(response)
+			part = p.personas.Prompts().ErrorMessage
 		}
-		return true
-	}
-
-	// Cancel recording
-	if strings.Contains(msg, "cancel recording") {
-		err := p.macroManager.CancelRecording()
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot cancel recording: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell("Recording cancelled.")
+		p.reply(message, part)
+		said = append(said, part)
+	}
+
+	// Get response from Llama if enabled and within the global limit, otherwise use fallbacks
+	if p.llamaEnabled && !p.rateLimiter.AllowLLM() {
+		log.Printf("Rate limit: too many LLM replies, using a fallback response for %s", message.Avatar)
+		response = p.getFallbackResponse(context, cleanMessage, lang)
+	} else if p.llamaEnabled {
+		emit := func(part string) {
+			streamed = true
+			say(part)
 		}
-		return true
-	}
-
-	// Play macro
-	if strings.HasPrefix(msg, "play macro ") {
-		macroName := strings.TrimPrefix(message.Message, "play macro ")
-		macroName = strings.TrimPrefix(macroName, "Play macro ")
-		macroName = strings.TrimSpace(macroName)
-
-		err := p.macroManager.PlayMacro(macroName, message.Avatar)
+		response, err = p.getLlamaResponse(message, cleanMessage, context, lang, emit)
 		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot play macro: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Playing macro '%s'...", macroName))
-		}
-		return true
-	}
-
-	// List macros
-	if strings.Contains(msg, "list macros") {
-		macros := p.macroManager.GetMacros()
-		if len(macros) == 0 {
-			p.corradeClient.Tell("No macros available.")
-		} else {
-			macroNames := make([]string, 0, len(macros))
-			for name := range macros {
-				macroNames = append(macroNames, name)
+			log.Printf("Error getting Llama response: %v", err)
+			// Fall back to predefined responses if Llama fails before saying anything
+			if !streamed {
+				response = p.getFallbackResponse(context, cleanMessage, lang)
 			}
-			p.corradeClient.Tell(fmt.Sprintf("Available macros: %s", strings.Join(macroNames, ", ")))
 		}
-		return true
+	} else {
+		response = p.getFallbackResponse(context, cleanMessage, lang)
 	}
 
-	// Delete macro
-	if strings.HasPrefix(msg, "delete macro ") {
-		macroName := strings.TrimPrefix(message.Message, "delete macro ")
-		macroName = strings.TrimPrefix(macroName, "Delete macro ")
-		macroName = strings.TrimSpace(macroName)
-
-		err := p.macroManager.DeleteMacro(macroName, message.Avatar)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot delete macro: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Deleted macro '%s'.", macroName))
-		}
-		return true
+	if !streamed {
+		// Truncate response if too long for SL chat, then send it back to Second Life
+		say(p.truncate(response))
 	}
+	response = strings.Join(said, " ")
 
-	// Set/unset idle behavior
-	if strings.HasPrefix(msg, "set idle ") {
-		macroName := strings.TrimPrefix(message.Message, "set idle ")
-		macroName = strings.TrimPrefix(macroName, "Set idle ")
-		macroName = strings.TrimSpace(macroName)
-
-		err := p.macroManager.SetIdleBehavior(macroName, message.Avatar, true)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot set idle behavior: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Macro '%s' is now an idle behavior.", macroName))
+	// Remember the exchange for follow-up questions
+	if response != "" && !blocked {
+		p.conversations.Append(message.UUID, message.Avatar, text, response)
+		if p.config.Conversation.Summarize && p.llamaEnabled {
+			go p.summarizeConversation(message.UUID)
 		}
-		return true
 	}
 
-	if strings.HasPrefix(msg, "unset idle ") {
-		macroName := strings.TrimPrefix(message.Message, "unset idle ")
-		macroName = strings.TrimPrefix(macroName, "Unset idle ")
-		macroName = strings.TrimSpace(macroName)
+	log.Printf("%s - %s: %s [%s] | Bot: %s", message.Type, message.Avatar, message.Message, classified, response)
 
-		err := p.macroManager.SetIdleBehavior(macroName, message.Avatar, false)
-		if err != nil {
-			p.corradeClient.Tell(fmt.Sprintf("Cannot unset idle behavior: %s", err.Error()))
-		} else {
-			p.corradeClient.Tell(fmt.Sprintf("Macro '%s' is no longer an idle behavior.", macroName))
-		}
-		return true
-	}
-
-	// List idle behaviors
-	if strings.Contains(msg, "list idle") {
-		idleMacros := p.macroManager.GetIdleBehaviorMacros()
-		if len(idleMacros) == 0 {
-			p.corradeClient.Tell("No idle behavior macros configured.")
-		} else {
-			macroNames := make([]string, len(idleMacros))
-			for i, macro := range idleMacros {
-				macroNames[i] = macro.Name
-			}
-			p.corradeClient.Tell(fmt.Sprintf("Idle behaviors: %s", strings.Join(macroNames, ", ")))
-		}
-		return true
-	}
-
-	return false
+	// Log to web interface
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "chat",
+		Avatar:    message.Avatar,
+		Message:   message.Message,
+		Response:  response,
+		Intent:    classified.String(),
+	})
 }
 
 // followAvatar starts following a specific avatar
@@ -779,23 +582,64 @@ func (p *Processor) followRoutine(ctx context.Context) {
 	}
 }
 
-// getFallbackResponse returns predefined responses when AI is disabled or fails
-func (p *Processor) getFallbackResponse(context, message string) string {
-	fallbacks := p.config.Prompts.FallbackResponses
+// addressedText strips the bot's chat name from a message and reports
+// whether the message was addressed to the bot at all
+func (p *Processor) addressedText(message types.ChatMessage) (string, bool) {
+	text := strings.TrimSpace(message.Message)
+	chatName := strings.TrimSpace(p.config.Bot.ChatName)
+
+	switch {
+	case chatName != "" && len(text) >= len(chatName) && strings.EqualFold(text[:len(chatName)], chatName):
+		text = text[len(chatName):]
+	case strings.HasPrefix(text, "/"):
+		text = text[1:]
+	case message.Type == "message" || chatName == "":
+	default:
+		return "", false
+	}
+
+	return strings.TrimLeft(text, " ,:;!"), true
+}
+
+// reply answers a message the way it arrived: in local chat or by IM
+func (p *Processor) reply(message types.ChatMessage, text string) {
+	var err error
+	if message.Type == "local" {
+		err = p.corradeClient.Tell(text)
+	} else {
+		err = p.corradeClient.Whisper(message.UUID, text)
+	}
+	if err != nil {
+		log.Printf("Error sending response to SL: %v", err)
+	}
+}
+
+// truncate shortens a response to the maximum SL chat message length
+func (p *Processor) truncate(response string) string {
+	if len(response) > p.config.Bot.MaxMessageLen {
+		return response[:p.config.Bot.MaxMessageLen-3] + "..."
+	}
+	return response
+}
+
+// getFallbackResponse returns predefined responses when AI is disabled or
+// fails, translated into the given language when the config has a set for it
+func (p *Processor) getFallbackResponse(context, message, lang string) string {
+	prompts := p.personas.Prompts()
+	fallbacks := prompts.Fallbacks(lang)
 
 	switch context {
-	case "greeting":
+	case intent.Greeting:
 		return fallbacks.Greeting
-	case "help":
+	case intent.Help:
 		return fallbacks.Help
-	case "general":
-		// Check for some basic keywords to provide more specific responses
-		lowerMsg := strings.ToLower(message)
-		if strings.Contains(lowerMsg, "what") || strings.Contains(lowerMsg, "who") ||
-			strings.Contains(lowerMsg, "how") || strings.Contains(lowerMsg, "why") {
-			return fallbacks.General
+	case intent.Farewell:
+		if fallbacks.Farewell != "" {
+			return fallbacks.Farewell
 		}
 		return fallbacks.General
+	case intent.Question, intent.SmallTalk, intent.General:
+		return fallbacks.General
 	default:
 		return fallbacks.Unknown
 	}
@@ -806,8 +650,26 @@ func (p *Processor) IsLlamaEnabled() bool {
 	return p.llamaEnabled
 }
 
-// SetLlamaEnabled enables or disables Llama chat at runtime
-func (p *Processor) SetLlamaEnabled(enabled bool) {
+// LLMProvider returns the name of the active LLM provider, or "" if there is none
+func (p *Processor) LLMProvider() string {
+	if p.provider == nil {
+		return ""
+	}
+	return p.provider.Name()
+}
+
+// SetLlamaEnabled enables or disables Llama chat at runtime. Enabling checks
+// the provider's connection first.
+func (p *Processor) SetLlamaEnabled(enabled bool) error {
+	if enabled {
+		if p.provider == nil {
+			return fmt.Errorf("no llm provider configured")
+		}
+		if err := p.provider.TestConnection(p.ctx); err != nil {
+			return fmt.Errorf("%s is not reachable: %v", p.provider.Name(), err)
+		}
+	}
+
 	p.llamaEnabled = enabled
 
 	status := "disabled"
@@ -821,76 +683,19 @@ func (p *Processor) SetLlamaEnabled(enabled bool) {
 		Avatar:    "System",
 		Message:   fmt.Sprintf("Llama chat %s", status),
 	})
-}
-
-// getLlamaResponse gets a response from the Llama API
-func (p *Processor) getLlamaResponse(prompt, context string) (string, error) {
-	// Use different prompts based on context
-	var finalPrompt string
-	switch context {
-	case "greeting":
-		finalPrompt = p.buildPrompt(p.config.Prompts.GreetingPrompt, prompt)
-	case "help":
-		finalPrompt = p.buildPrompt(p.config.Prompts.HelpPrompt, prompt)
-	case "chat":
-		fallthrough
-	default:
-		finalPrompt = p.buildPrompt(p.config.Prompts.ChatPrompt, prompt)
-	}
-
-	req := types.LlamaRequest{
-		Model:  p.config.Llama.Model,
-		Prompt: p.config.Prompts.SystemPrompt + "\n\n" + finalPrompt,
-		Stream: false,
-	}
-
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := p.httpClient.Post(p.config.Llama.URL+"/api/generate", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
-	}
-
-	var llamaResp types.LlamaResponse
-	if err := json.Unmarshal(body, &llamaResp); err != nil {
-		return "", err
-	}
-
-	return strings.TrimSpace(llamaResp.Response), nil
-}
-
-// buildPrompt builds a prompt with variable substitution
-func (p *Processor) buildPrompt(template, userMessage string) string {
-	prompt := strings.ReplaceAll(template, "{message}", userMessage)
-	prompt = strings.ReplaceAll(prompt, "{botname}", p.config.Bot.Name)
-	prompt = strings.ReplaceAll(prompt, "{maxlen}", fmt.Sprintf("%d", p.config.Bot.MaxMessageLen))
-	return prompt
+	return nil
 }
 
 // SystemLog adds log entry
 func (p *Processor) SystemLog(format string, v ...any) {
-   ent := types.LogEntry{
-	   Timestamp: time.Now(),
-	   Type:      "system",
-	   Avatar:    p.config.Bot.Name, 
-   }
-   if len(v) > 0 {
-      log.Printf(format, v)
-      ent.Message = fmt.Sprintf(format, v)
-   } else {
-      log.Printf(format)
-      ent.Message = fmt.Sprintf(format)
-   }
-   p.addLog(ent)
+	ent := types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    p.config.Bot.Name,
+	}
+	ent.Message = fmt.Sprintf(format, v...)
+	log.Print(ent.Message)
+	p.addLog(ent)
 }
 
 // addLog adds a log entry
@@ -1079,16 +884,15 @@ func (p *Processor) IsIdle() bool {
 }
 
 // handleSitCommand processes sit commands
-func (p *Processor) handleSitCommand(objectName, avatar string) error {
+func (p *Processor) handleSitCommand(objectName, avatar string, reply func(string)) error {
 	// Try to sit on the object directly
 	err := p.corradeClient.SitOn(objectName)
 	if err != nil {
-		p.corradeClient.Tell("I couldn't find that object to sit on.")
-		log.Printf("Sit error: %v", err)
+		reply("I couldn't find that object to sit on.")
 		return err
 	}
 
-	p.corradeClient.Tell(fmt.Sprintf("Sitting on %s", objectName))
+	reply(fmt.Sprintf("Sitting on %s", objectName))
 	p.recordAction("sit", map[string]interface{}{
 		"object": objectName,
 	})
@@ -1107,6 +911,26 @@ func (p *Processor) GetMacroManager() *macros.Manager {
 	return p.macroManager
 }
 
+// GetRoleManager returns the role manager for external access
+func (p *Processor) GetRoleManager() *roles.Manager {
+	return p.roleManager
+}
+
+// GetLanguageManager returns the language manager for external access
+func (p *Processor) GetLanguageManager() *language.Manager {
+	return p.languages
+}
+
+// GetKnowledgeManager returns the knowledge base manager for external access
+func (p *Processor) GetKnowledgeManager() *knowledge.Manager {
+	return p.knowledgeManager
+}
+
+// GetNotecardManager returns the notecard manager for external access
+func (p *Processor) GetNotecardManager() *notecards.Manager {
+	return p.notecardManager
+}
+
 // GetPendingSitRequest returns the current pending sit confirmation request (simplified)
 func (p *Processor) GetPendingSitRequest() *types.PendingSitConfirmation {
 	// This functionality has been simplified since FindNearbyObjects doesn't exist
@@ -1138,3 +962,8 @@ func (p *Processor) GetAutoGreetConfig() (bool, string) {
 func (p *Processor) SetAutoGreetConfig(enabled bool, macroName string) {
 	p.corradeClient.SetAutoGreet(enabled, macroName)
 }
+
+// GetQueueStats returns the counters of the model's worker pool
+func (p *Processor) GetQueueStats() queue.Stats {
+	return p.queue.Stats()
+}
@@ -0,0 +1,63 @@
+package chat
+
+import (
+	"log"
+	"sort"
+
+	"slbot/internal/prompt"
+	"slbot/internal/slfunc"
+	"slbot/internal/types"
+)
+
+// promptContext gathers what prompt templates can refer to when answering
+// a message. Region, position and nearby avatars are only looked up if a
+// template uses them.
+func (p *Processor) promptContext(message types.ChatMessage, text string) *prompt.Context {
+	ctx := prompt.NewContext(prompt.Lookups{
+		Region:   p.corradeClient.GetCurrentRegion,
+		Position: p.corradeClient.GetOwnPosition,
+		Nearby:   p.nearbyNames,
+	})
+	ctx.Message = text
+	ctx.Avatar = message.Avatar
+	ctx.UUID = message.UUID
+	ctx.BotName = p.config.Bot.Name
+	ctx.MaxLen = p.config.Bot.MaxMessageLen
+	ctx.Persona = p.personas.Active()
+
+	if message.UUID != "" {
+		if history := p.conversations.Get(message.UUID); history != nil {
+			ctx.History = p.formatTurns(history.Avatar, history.Turns)
+			ctx.Summary = history.Summary
+		}
+	}
+
+	return ctx
+}
+
+// nearbyNames returns the names of the other avatars in the region, sorted
+func (p *Processor) nearbyNames() []string {
+	avatars, err := p.corradeClient.GetNearbyAvatars()
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(avatars))
+	for _, avatar := range avatars {
+		if !slfunc.MatchName(avatar.Name, p.config.Bot.Name) {
+			names = append(names, avatar.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildPrompt renders a prompt template, using it as it is if it can't be rendered
+func (p *Processor) buildPrompt(template string, ctx *prompt.Context) string {
+	rendered, err := prompt.Render(template, ctx)
+	if err != nil {
+		log.Printf("Failed to render prompt: %v", err)
+		return template
+	}
+	return rendered
+}
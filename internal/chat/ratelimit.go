@@ -0,0 +1,52 @@
+package chat
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"slbot/internal/ratelimit"
+	"slbot/internal/roles"
+	"slbot/internal/types"
+)
+
+// allowMessage applies the flood limits to a message addressed to the bot,
+// telling the sender to slow down once a minute when they are limited
+func (p *Processor) allowMessage(message types.ChatMessage) bool {
+	if !p.rateLimiter.IsEnabled() {
+		return true
+	}
+
+	exempt, err := roles.Parse(p.config.RateLimit.ExemptRole)
+	if err != nil {
+		exempt = roles.Owner
+	}
+	if p.roleManager.Has(message.UUID, exempt) {
+		return true
+	}
+
+	decision := p.rateLimiter.Check(message.UUID, message.Avatar)
+	switch {
+	case decision.Allowed:
+		return true
+	case decision.Ignored:
+		event := fmt.Sprintf("Ignoring %s for %d minutes after flooding", message.Avatar, p.config.RateLimit.IgnoreMinutes)
+		log.Printf("Rate limit: %s", event)
+		p.addLog(types.LogEntry{
+			Timestamp: time.Now(),
+			Type:      "moderation",
+			Avatar:    message.Avatar,
+			Message:   event,
+			Response:  message.Message,
+		})
+	case decision.Warn:
+		log.Printf("Rate limit: %s is sending messages too quickly", message.Avatar)
+		p.reply(message, p.config.RateLimit.WarnMessage)
+	}
+	return false
+}
+
+// GetRateLimiter returns the rate limiter for external access
+func (p *Processor) GetRateLimiter() *ratelimit.Limiter {
+	return p.rateLimiter
+}
@@ -0,0 +1,145 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/roles"
+	"slbot/internal/scheduler"
+	"slbot/internal/types"
+)
+
+// scheduleRoutine runs scheduled tasks as they come due
+func (p *Processor) scheduleRoutine(ctx context.Context) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, task := range p.schedules.Due(now) {
+				p.runScheduledTask(task)
+			}
+		}
+	}
+}
+
+// runScheduledTask runs a task unless the bot is offline or not in the
+// task's region, recording the outcome
+func (p *Processor) runScheduledTask(task config.ScheduledTask) {
+	result := "ok"
+	region := ""
+	if task.Region != "" {
+		region = p.corradeClient.GetCurrentRegion()
+	}
+
+	switch {
+	case !p.corradeClient.IsOnline():
+		result = "skipped: bot offline"
+	case task.Region != "" && !strings.EqualFold(region, task.Region):
+		result = fmt.Sprintf("skipped: in %s, not %s", region, task.Region)
+	default:
+		if err := p.scheduledAction(task); err != nil {
+			result = "failed: " + err.Error()
+		}
+	}
+	p.schedules.Record(task.Name, result)
+
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "system",
+		Avatar:    "Scheduler",
+		Message:   fmt.Sprintf("%s (%s %s)", task.Name, task.Action, task.Value),
+		Response:  result,
+	})
+}
+
+// scheduledAction carries out a task's action
+func (p *Processor) scheduledAction(task config.ScheduledTask) error {
+	by := "Schedule:" + task.Name
+	switch task.Action {
+	case scheduler.ActionSay:
+		text := p.buildPrompt(task.Value, p.promptContext(types.ChatMessage{}, ""))
+		return p.corradeClient.Tell(p.truncate(text))
+
+	case scheduler.ActionIM:
+		text := p.truncate(p.buildPrompt(task.Value, p.promptContext(types.ChatMessage{}, "")))
+		recipients := p.recipients(task.To)
+		if len(recipients) == 0 {
+			return fmt.Errorf("no recipients in '%s'", task.To)
+		}
+		var failed []string
+		for _, uuid := range recipients {
+			if err := p.corradeClient.Whisper(uuid, text); err != nil {
+				failed = append(failed, uuid)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("could not IM %s", strings.Join(failed, ", "))
+		}
+		return nil
+
+	case scheduler.ActionMacro:
+		return p.macroManager.PlayMacro(task.Value, by)
+
+	case scheduler.ActionTeleport:
+		// A landmark, or a region name for its centre
+		region, x, y, z := task.Value, 128.0, 128.0, 25.0
+		if landmark, exists := p.config.FindLandmark(task.Value); exists {
+			region, x, y, z = landmark.Region, landmark.X, landmark.Y, landmark.Z
+			if region == "" {
+				region = p.corradeClient.GetCurrentRegion()
+			}
+		}
+		return p.corradeClient.Teleport(region, x, y, z)
+
+	case scheduler.ActionAutoGreet:
+		_, macro := p.corradeClient.GetAutoGreetConfig()
+		enabled := strings.EqualFold(task.Value, "on")
+		p.corradeClient.SetAutoGreet(enabled, macro)
+		p.SystemLog("%s turned auto-greet %s", by, strings.ToLower(task.Value))
+		return nil
+	}
+	return fmt.Errorf("unknown action %s", task.Action)
+}
+
+// recipients expands a comma separated list of UUIDs and role names (for
+// everyone holding at least that role) into avatar UUIDs
+func (p *Processor) recipients(to string) []string {
+	seen := make(map[string]bool)
+	var uuids []string
+	add := func(uuid string) {
+		if uuid = strings.ToLower(uuid); uuid != "" && !seen[uuid] {
+			seen[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+
+	for _, entry := range strings.Split(to, ",") {
+		entry = strings.TrimSpace(entry)
+		role, err := roles.Parse(entry)
+		if err != nil {
+			add(entry)
+			continue
+		}
+		for uuid := range p.roleManager.Owners() {
+			add(uuid)
+		}
+		for _, assignment := range p.roleManager.Assignments() {
+			if assignment.Role >= role {
+				add(assignment.UUID)
+			}
+		}
+	}
+	return uuids
+}
+
+// GetScheduler returns the scheduled tasks for external access
+func (p *Processor) GetScheduler() *scheduler.Manager {
+	return p.schedules
+}
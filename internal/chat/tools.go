@@ -0,0 +1,242 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"slbot/internal/llm"
+	"slbot/internal/types"
+)
+
+// maxToolRounds limits how many times the model may call tools for one message
+const maxToolRounds = 3
+
+// botTool is a tool offered to the model, carried out by a chat command so
+// that it is subject to the same role checks as when typed in chat
+type botTool struct {
+	tool llm.Tool
+	run  func(message types.ChatMessage, args map[string]interface{}, reply func(string)) error
+}
+
+// stringParam returns a JSON schema property of type string
+func stringParam(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "string", "description": description}
+}
+
+// numberParam returns a JSON schema property of type number
+func numberParam(description string) map[string]interface{} {
+	return map[string]interface{}{"type": "number", "description": description}
+}
+
+// objectSchema returns a JSON schema for an arguments object
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	if required == nil {
+		required = []string{}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// argString returns a tool argument as text
+func argString(args map[string]interface{}, name string) string {
+	switch value := args[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// runCommand returns a tool implementation that runs a chat command,
+// mapping tool arguments onto command arguments
+func (p *Processor) runCommand(command string, argNames map[string]string) func(types.ChatMessage, map[string]interface{}, func(string)) error {
+	return func(message types.ChatMessage, args map[string]interface{}, reply func(string)) error {
+		cmdArgs := make(map[string]string)
+		for toolArg, cmdArg := range argNames {
+			cmdArgs[cmdArg] = argString(args, toolArg)
+		}
+		return p.commands.Run(message, command, cmdArgs, reply)
+	}
+}
+
+// botTools returns the tools offered to the model
+func (p *Processor) botTools() []botTool {
+	landmarks := make([]string, 0, len(p.config.Bot.Landmarks))
+	for _, landmark := range p.config.Bot.Landmarks {
+		landmarks = append(landmarks, landmark.Name)
+	}
+	landmarkHelp := "Name of a landmark"
+	if len(landmarks) > 0 {
+		landmarkHelp += ": " + strings.Join(landmarks, ", ")
+	}
+
+	return []botTool{
+		{
+			tool: llm.Tool{
+				Name:        "walk_to",
+				Description: "Walk to a landmark, or to x, y, z coordinates in the current region.",
+				Parameters: objectSchema(map[string]interface{}{
+					"landmark": stringParam(landmarkHelp),
+					"x":        numberParam("Region x coordinate"),
+					"y":        numberParam("Region y coordinate"),
+					"z":        numberParam("Region z coordinate"),
+				}),
+			},
+			run: func(message types.ChatMessage, args map[string]interface{}, reply func(string)) error {
+				if argString(args, "landmark") != "" {
+					return p.runCommand("walk to", map[string]string{"landmark": "place"})(message, args, reply)
+				}
+				return p.runCommand("go to", map[string]string{"x": "x", "y": "y", "z": "z"})(message, args, reply)
+			},
+		},
+		{
+			tool: llm.Tool{
+				Name:        "follow",
+				Description: "Follow the person you are talking to.",
+				Parameters:  objectSchema(map[string]interface{}{}),
+			},
+			run: p.runCommand("follow me", nil),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "stop",
+				Description: "Stop following and stay where you are.",
+				Parameters:  objectSchema(map[string]interface{}{}),
+			},
+			run: p.runCommand("stop following", nil),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "sit",
+				Description: "Sit on a nearby object.",
+				Parameters: objectSchema(map[string]interface{}{
+					"object": stringParam("Name of the object to sit on"),
+				}, "object"),
+			},
+			run: p.runCommand("sit on", map[string]string{"object": "object"}),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "stand",
+				Description: "Stand up if sitting.",
+				Parameters:  objectSchema(map[string]interface{}{}),
+			},
+			run: p.runCommand("stand up", nil),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "teleport_to",
+				Description: "Teleport to a landmark, possibly in another region.",
+				Parameters: objectSchema(map[string]interface{}{
+					"landmark": stringParam(landmarkHelp),
+				}, "landmark"),
+			},
+			run: p.runCommand("teleport to", map[string]string{"landmark": "place"}),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "play_macro",
+				Description: "Play a recorded macro of actions, such as a dance or a greeting.",
+				Parameters: objectSchema(map[string]interface{}{
+					"name": stringParam("Name of the macro"),
+				}, "name"),
+			},
+			run: p.runCommand("play macro", map[string]string{"name": "name"}),
+		},
+		{
+			tool: llm.Tool{
+				Name:        "who_is_here",
+				Description: "List the avatars in the region.",
+				Parameters:  objectSchema(map[string]interface{}{}),
+			},
+			run: p.runCommand("list avatars", nil),
+		},
+	}
+}
+
+// callTool carries out a tool call for the sender of a message and returns
+// the result to give back to the model
+func (p *Processor) callTool(message types.ChatMessage, tools []botTool, call llm.ToolCall) string {
+	var tool *botTool
+	for i := range tools {
+		if tools[i].tool.Name == call.Name {
+			tool = &tools[i]
+		}
+	}
+	if tool == nil {
+		return fmt.Sprintf("Error: there is no tool called %s.", call.Name)
+	}
+
+	var replies []string
+	err := tool.run(message, call.Arguments, func(text string) {
+		replies = append(replies, text)
+	})
+
+	result := strings.Join(replies, " ")
+	if err != nil {
+		result = strings.TrimSpace("Error: " + err.Error() + ". " + result)
+	}
+	if result == "" {
+		result = "Done."
+	}
+
+	arguments, _ := json.Marshal(call.Arguments)
+	log.Printf("Tool %s%s for %s: %s", call.Name, arguments, message.Avatar, result)
+	p.addLog(types.LogEntry{
+		Timestamp: time.Now(),
+		Type:      "tool",
+		Avatar:    message.Avatar,
+		Message:   fmt.Sprintf("%s %s", call.Name, arguments),
+		Response:  result,
+	})
+
+	return result
+}
+
+// chatWithTools lets the model call tools on behalf of the sender of a
+// message, feeding the results back until it gives its final reply
+func (p *Processor) chatWithTools(caller llm.ToolCaller, message types.ChatMessage, messages []llm.Message) (string, error) {
+	tools := p.botTools()
+	descriptions := make([]llm.Tool, len(tools))
+	for i, tool := range tools {
+		descriptions[i] = tool.tool
+	}
+
+	for round := 0; round < maxToolRounds; round++ {
+		reply, err := caller.ChatWithTools(p.ctx, messages, descriptions)
+		if err != nil {
+			return "", err
+		}
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+
+		messages = append(messages, reply)
+		for i, call := range reply.ToolCalls {
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call-%d-%d", round, i)
+				reply.ToolCalls[i].ID = call.ID
+			}
+			messages = append(messages, llm.Message{
+				Role:       llm.RoleTool,
+				Content:    p.callTool(message, tools, call),
+				ToolCallID: call.ID,
+				ToolName:   call.Name,
+			})
+		}
+	}
+
+	// Out of rounds, ask for a reply without offering tools again
+	return p.chatCompletion(messages, nil)
+}
@@ -0,0 +1,315 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"slbot/internal/roles"
+	"slbot/internal/types"
+)
+
+// Arg describes one argument in a command's grammar
+type Arg struct {
+	Name     string
+	Optional bool
+	Rest     bool // Consumes all remaining words
+}
+
+// Context carries a parsed command invocation to its handler
+type Context struct {
+	Command *Command
+	Message types.ChatMessage
+	Args    map[string]string
+	Reply   func(text string)
+}
+
+// Arg returns the value of a named argument, or "" if it was not given
+func (c *Context) Arg(name string) string {
+	return c.Args[name]
+}
+
+// Handler executes a command
+type Handler func(ctx *Context) error
+
+// ErrNotCommand is returned by a handler, before it replies, when the text
+// turns out not to be meant as the command, e.g. "tell me a joke", so it is
+// treated as conversation instead
+var ErrNotCommand = errors.New("not a command")
+
+// Command is a chat command with its grammar, required role and help text
+type Command struct {
+	Name    string
+	Aliases []string
+	Args    []Arg
+	Role    roles.Role
+	Help    string
+	Handler Handler
+}
+
+// Usage returns the command syntax, e.g. "play macro <name>"
+func (c *Command) Usage() string {
+	parts := []string{c.Name}
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseArgs maps the words following the command name onto its grammar
+func (c *Command) parseArgs(words []string) (map[string]string, error) {
+	args := make(map[string]string)
+	i := 0
+	for _, arg := range c.Args {
+		if i >= len(words) {
+			if !arg.Optional {
+				return nil, fmt.Errorf("missing %s", arg.Name)
+			}
+			continue
+		}
+		if arg.Rest {
+			args[arg.Name] = strings.Join(words[i:], " ")
+			i = len(words)
+			continue
+		}
+		args[arg.Name] = words[i]
+		i++
+	}
+	if i < len(words) {
+		return nil, fmt.Errorf("too many arguments")
+	}
+	return args, nil
+}
+
+// Authorizer decides whether the sender of a message holds a role
+type Authorizer func(message types.ChatMessage, role roles.Role) bool
+
+// Registry holds the chat commands and dispatches messages to them
+type Registry struct {
+	commands  []*Command
+	phrases   map[string]*Command // Lower-case name or alias -> command
+	authorize Authorizer
+	mutex     sync.RWMutex
+}
+
+// NewRegistry creates an empty command registry
+func NewRegistry(authorize Authorizer) *Registry {
+	return &Registry{
+		commands:  make([]*Command, 0),
+		phrases:   make(map[string]*Command),
+		authorize: authorize,
+	}
+}
+
+// Register adds a command to the registry
+func (r *Registry) Register(cmd *Command) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cmd.Name == "" || cmd.Handler == nil {
+		return fmt.Errorf("command must have a name and a handler")
+	}
+
+	phrases := append([]string{cmd.Name}, cmd.Aliases...)
+	for _, phrase := range phrases {
+		key := normalizePhrase(phrase)
+		if existing, exists := r.phrases[key]; exists {
+			return fmt.Errorf("'%s' is already registered by command '%s'", phrase, existing.Name)
+		}
+	}
+	for _, phrase := range phrases {
+		r.phrases[normalizePhrase(phrase)] = cmd
+	}
+
+	r.commands = append(r.commands, cmd)
+	return nil
+}
+
+// MustRegister adds a command and panics if it conflicts with another
+func (r *Registry) MustRegister(cmd *Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// Commands returns all registered commands sorted by name
+func (r *Registry) Commands() []*Command {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	result := make([]*Command, len(r.commands))
+	copy(result, r.commands)
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Lookup finds a command by its name or one of its aliases
+func (r *Registry) Lookup(name string) (*Command, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	cmd, exists := r.phrases[normalizePhrase(name)]
+	return cmd, exists
+}
+
+// Match finds the command a message invokes, preferring the longest phrase,
+// and returns the remaining words as arguments
+func (r *Registry) Match(text string) (*Command, []string, error) {
+	words, err := Tokenize(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for n := len(words); n > 0; n-- {
+		phrase := strings.ToLower(strings.Join(words[:n], " "))
+		if cmd, exists := r.phrases[phrase]; exists {
+			return cmd, words[n:], nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// SetRole changes the role required by a command
+func (r *Registry) SetRole(name string, role roles.Role) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	cmd, exists := r.phrases[normalizePhrase(name)]
+	if !exists {
+		return fmt.Errorf("no command called '%s'", name)
+	}
+	cmd.Role = role
+	return nil
+}
+
+// RoleOf returns the role a command requires. Commands are shared, so the
+// role is read under the lock SetRole changes it under.
+func (r *Registry) RoleOf(cmd *Command) roles.Role {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return cmd.Role
+}
+
+// Allowed reports whether the sender of a message may use a command
+func (r *Registry) Allowed(cmd *Command, message types.ChatMessage) bool {
+	role := r.RoleOf(cmd)
+	if role == roles.Guest {
+		return true
+	}
+	return r.holds(message, role)
+}
+
+// holds reports whether the sender of a message holds a role
+func (r *Registry) holds(message types.ChatMessage, role roles.Role) bool {
+	return r.authorize != nil && r.authorize(message, role)
+}
+
+// Dispatch runs the command invoked by text, if any, and reports whether
+// the text was handled as a command. Text that doesn't fit the command's
+// grammar, e.g. "go to the bar", is left for the conversation.
+func (r *Registry) Dispatch(message types.ChatMessage, text string, reply func(string)) bool {
+	cmd, words, err := r.Match(text)
+	if err != nil || cmd == nil {
+		return false
+	}
+
+	args, err := cmd.parseArgs(words)
+	if err != nil {
+		return false
+	}
+
+	if !r.Allowed(cmd, message) {
+		// A guest's sentence that happens to start with a command's name,
+		// e.g. "grant me a wish", is conversation rather than an attempt
+		if len(cmd.Args) > 0 && !r.holds(message, roles.Trusted) {
+			return false
+		}
+		reply(fmt.Sprintf("Sorry, '%s' requires the %s role.", cmd.Name, r.RoleOf(cmd)))
+		return true
+	}
+
+	ctx := &Context{
+		Command: cmd,
+		Message: message,
+		Args:    args,
+		Reply:   reply,
+	}
+	if err := cmd.Handler(ctx); err == ErrNotCommand {
+		return false
+	} else if err != nil {
+		log.Printf("Command '%s' from %s failed: %v", cmd.Name, message.Avatar, err)
+	}
+	return true
+}
+
+// Run invokes a command by name with already-parsed arguments, applying the
+// same role check as Dispatch. It is used when commands are triggered other
+// than by chat, e.g. by LLM tool calls.
+func (r *Registry) Run(message types.ChatMessage, name string, args map[string]string, reply func(string)) error {
+	cmd, exists := r.Lookup(name)
+	if !exists {
+		return fmt.Errorf("no command called '%s'", name)
+	}
+
+	for _, arg := range cmd.Args {
+		if !arg.Optional && args[arg.Name] == "" {
+			return fmt.Errorf("missing %s", arg.Name)
+		}
+	}
+
+	if !r.Allowed(cmd, message) {
+		return fmt.Errorf("'%s' requires the %s role", cmd.Name, r.RoleOf(cmd))
+	}
+
+	ctx := &Context{
+		Command: cmd,
+		Message: message,
+		Args:    args,
+		Reply:   reply,
+	}
+	return cmd.Handler(ctx)
+}
+
+// Help returns the help text for one command, or a list of the commands
+// available to the sender when topic is empty
+func (r *Registry) Help(message types.ChatMessage, topic string) string {
+	if topic != "" {
+		cmd, _, _ := r.Match(topic)
+		if cmd == nil {
+			return fmt.Sprintf("There is no command called '%s'.", topic)
+		}
+		text := fmt.Sprintf("%s - %s", cmd.Usage(), cmd.Help)
+		if len(cmd.Aliases) > 0 {
+			text += fmt.Sprintf(" (also: %s)", strings.Join(cmd.Aliases, ", "))
+		}
+		return text
+	}
+
+	names := make([]string, 0)
+	for _, cmd := range r.Commands() {
+		if r.Allowed(cmd, message) {
+			names = append(names, cmd.Name)
+		}
+	}
+	return fmt.Sprintf("Commands: %s. Say 'help <command>' for details.", strings.Join(names, ", "))
+}
+
+// normalizePhrase lower-cases a phrase and collapses its whitespace
+func normalizePhrase(phrase string) string {
+	return strings.ToLower(strings.Join(strings.Fields(phrase), " "))
+}
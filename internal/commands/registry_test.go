@@ -0,0 +1,199 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"slbot/internal/roles"
+	"slbot/internal/types"
+)
+
+// testRegistry registers commands that record the arguments they were run with
+func testRegistry(ran *map[string]string, called *string) *Registry {
+	// The avatar's name is the role they hold
+	r := NewRegistry(func(message types.ChatMessage, role roles.Role) bool {
+		held, err := roles.Parse(message.Avatar)
+		return err == nil && held >= role
+	})
+
+	record := func(ctx *Context) error {
+		*called = ctx.Command.Name
+		*ran = ctx.Args
+		return nil
+	}
+	r.MustRegister(&Command{Name: "stand", Handler: record})
+	r.MustRegister(&Command{Name: "play", Args: []Arg{{Name: "song"}}, Handler: record})
+	r.MustRegister(&Command{Name: "play macro", Aliases: []string{"dance"}, Args: []Arg{{Name: "name"}}, Role: roles.Staff, Handler: record})
+	r.MustRegister(&Command{Name: "go", Args: []Arg{{Name: "place"}}, Handler: record})
+	r.MustRegister(&Command{Name: "help", Args: []Arg{{Name: "topic", Optional: true}}, Handler: record})
+	r.MustRegister(&Command{Name: "tell", Args: []Arg{{Name: "avatar"}, {Name: "message", Rest: true}}, Handler: func(ctx *Context) error {
+		if ctx.Arg("avatar") == "jokes" {
+			return ErrNotCommand
+		}
+		return record(ctx)
+	}})
+	r.MustRegister(&Command{Name: "grant", Args: []Arg{{Name: "avatar"}, {Name: "role"}}, Role: roles.Owner, Handler: record})
+	r.MustRegister(&Command{Name: "shutdown", Role: roles.Owner, Handler: record})
+	return r
+}
+
+func TestMatch(t *testing.T) {
+	var ran map[string]string
+	var called string
+	r := testRegistry(&ran, &called)
+
+	tests := []struct {
+		text  string
+		name  string
+		words []string
+	}{
+		{text: "play macro dance", name: "play macro", words: []string{"dance"}},
+		{text: "PLAY   Macro dance", name: "play macro", words: []string{"dance"}},
+		{text: "play jazz", name: "play", words: []string{"jazz"}},
+		{text: `play "macro dance"`, name: "play", words: []string{"macro dance"}},
+		{text: "dance waltz", name: "play macro", words: []string{"waltz"}},
+		{text: "stand", name: "stand", words: []string{}},
+		{text: "hello there", name: ""},
+		{text: "", name: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			cmd, words, err := r.Match(test.text)
+			if err != nil {
+				t.Fatalf("Match(%q) returned an error: %v", test.text, err)
+			}
+			if test.name == "" {
+				if cmd != nil {
+					t.Errorf("Match(%q) = %s, want no command", test.text, cmd.Name)
+				}
+				return
+			}
+			if cmd == nil || cmd.Name != test.name {
+				t.Fatalf("Match(%q) = %v, want %s", test.text, cmd, test.name)
+			}
+			if len(words) != len(test.words) || len(words) > 0 && !reflect.DeepEqual(words, test.words) {
+				t.Errorf("Match(%q) words = %q, want %q", test.text, words, test.words)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []Arg
+		words   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", args: nil, words: nil, want: map[string]string{}},
+		{name: "extra words", args: nil, words: []string{"up"}, wantErr: true},
+		{name: "one", args: []Arg{{Name: "name"}}, words: []string{"dance"}, want: map[string]string{"name": "dance"}},
+		{name: "missing", args: []Arg{{Name: "name"}}, words: nil, wantErr: true},
+		{name: "too many", args: []Arg{{Name: "name"}}, words: []string{"dance", "now"}, wantErr: true},
+		{name: "optional given", args: []Arg{{Name: "topic", Optional: true}}, words: []string{"tell"}, want: map[string]string{"topic": "tell"}},
+		{name: "optional left out", args: []Arg{{Name: "topic", Optional: true}}, words: nil, want: map[string]string{}},
+		{
+			name:  "rest",
+			args:  []Arg{{Name: "avatar"}, {Name: "message", Rest: true}},
+			words: []string{"Jane Doe", "see", "you", "at", "8"},
+			want:  map[string]string{"avatar": "Jane Doe", "message": "see you at 8"},
+		},
+		{name: "rest missing", args: []Arg{{Name: "avatar"}, {Name: "message", Rest: true}}, words: []string{"Jane"}, wantErr: true},
+		{
+			name:  "optional rest left out",
+			args:  []Arg{{Name: "event"}, {Name: "note", Optional: true, Rest: true}},
+			words: []string{"quiz"},
+			want:  map[string]string{"event": "quiz"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := &Command{Name: "test", Args: test.args}
+			got, err := cmd.parseArgs(test.words)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parseArgs(%q) = %v, want an error", test.words, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs(%q) returned an error: %v", test.words, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseArgs(%q) = %v, want %v", test.words, got, test.want)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		avatar  string // The role the sender holds
+		text    string
+		handled bool
+		called  string
+		args    map[string]string
+		replied bool
+	}{
+		{name: "command", avatar: "guest", text: "stand", handled: true, called: "stand", args: map[string]string{}},
+		{name: "longest phrase", avatar: "staff", text: "play macro dance", handled: true, called: "play macro", args: map[string]string{"name": "dance"}},
+		{name: "quoted argument", avatar: "guest", text: `play "Blue Moon"`, handled: true, called: "play", args: map[string]string{"song": "Blue Moon"}},
+		{name: "rest argument", avatar: "guest", text: `tell "Jane Doe" see you at 8`, handled: true, called: "tell", args: map[string]string{"avatar": "Jane Doe", "message": "see you at 8"}},
+		{name: "optional argument left out", avatar: "guest", text: "help", handled: true, called: "help", args: map[string]string{}},
+		{name: "not a command", avatar: "guest", text: "hello there"},
+		{name: "unterminated quote", avatar: "guest", text: `play "Blue Moon`},
+		{name: "extra words after an argument-less command", avatar: "guest", text: "stand up please"},
+		{name: "too many arguments", avatar: "guest", text: "go to the bar"},
+		{name: "missing argument", avatar: "guest", text: "play"},
+		{name: "handler says not a command", avatar: "guest", text: "tell jokes about cats"},
+		{name: "guest talking", avatar: "guest", text: "grant me wishes"},
+		{name: "guest talking with the right grammar", avatar: "guest", text: "grant Jane owner"},
+		{name: "guest without the role for an argument-less command", avatar: "guest", text: "shutdown", handled: true, replied: true},
+		{name: "trusted without the role", avatar: "trusted", text: "grant Jane owner", handled: true, replied: true},
+		{name: "staff without the role", avatar: "staff", text: "grant Jane owner", handled: true, replied: true},
+		{name: "owner", avatar: "owner", text: "grant Jane owner", handled: true, called: "grant", args: map[string]string{"avatar": "Jane", "role": "owner"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var ran map[string]string
+			var called string
+			r := testRegistry(&ran, &called)
+
+			replied := false
+			handled := r.Dispatch(types.ChatMessage{Avatar: test.avatar}, test.text, func(string) { replied = true })
+			if handled != test.handled {
+				t.Errorf("Dispatch(%q) = %v, want %v", test.text, handled, test.handled)
+			}
+			if called != test.called {
+				t.Errorf("Dispatch(%q) ran %q, want %q", test.text, called, test.called)
+			}
+			if test.called != "" && !reflect.DeepEqual(ran, test.args) {
+				t.Errorf("Dispatch(%q) args = %v, want %v", test.text, ran, test.args)
+			}
+			if replied != test.replied {
+				t.Errorf("Dispatch(%q) replied = %v, want %v", test.text, replied, test.replied)
+			}
+		})
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	var ran map[string]string
+	var called string
+	r := testRegistry(&ran, &called)
+
+	if err := r.SetRole("dance", roles.Guest); err != nil {
+		t.Fatalf("SetRole returned an error: %v", err)
+	}
+	if !r.Dispatch(types.ChatMessage{Avatar: "guest"}, "play macro waltz", func(string) {}) || called != "play macro" {
+		t.Errorf("guest couldn't play a macro after the role was lowered")
+	}
+	if err := r.SetRole("no such command", roles.Guest); err == nil {
+		t.Error("SetRole on an unknown command succeeded")
+	}
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Tokenize splits text into words, keeping "quoted phrases" together.
+// Curly quotes from the SL viewer are treated the same as straight ones.
+func Tokenize(text string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inQuotes := false
+	hasWord := false
+
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			inQuotes = !inQuotes
+			hasWord = true
+		case unicode.IsSpace(r) && !inQuotes:
+			if hasWord {
+				words = append(words, current.String())
+				current.Reset()
+				hasWord = false
+			}
+		default:
+			current.WriteRune(r)
+			hasWord = true
+		}
+	}
+
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if hasWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
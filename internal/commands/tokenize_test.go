@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{text: "play macro dance", want: []string{"play", "macro", "dance"}},
+		{text: "  play   macro\tdance  ", want: []string{"play", "macro", "dance"}},
+		{text: `tell "Jane Doe" hello there`, want: []string{"tell", "Jane Doe", "hello", "there"}},
+		{text: "tell “Jane Doe” hi", want: []string{"tell", "Jane Doe", "hi"}},
+		{text: `say ""`, want: []string{"say", ""}},
+		{text: `a"b c"d`, want: []string{"ab cd"}},
+		{text: "", want: nil},
+		{text: `tell "Jane Doe hi`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			got, err := Tokenize(test.text)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Tokenize(%q) = %q, want an error", test.text, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Tokenize(%q) returned an error: %v", test.text, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Tokenize(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,46 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"strings"
+
+	"slbot/internal/prompt"
+	"slbot/internal/signature"
 )
 
 // Config holds all configuration settings
 type Config struct {
-	XMLName xml.Name      `xml:"config"`
-	Corrade CorradeConfig `xml:"corrade"`
-	Llama   LlamaConfig   `xml:"llama"`
-   SimScan SimScanConfig  `xml:"simscan"`
-	Bot     BotConfig     `xml:"bot"`
-	Prompts PromptsConfig `xml:"prompts"`
+	XMLName      xml.Name           `xml:"config"`
+	Corrade      CorradeConfig      `xml:"corrade"`
+	Llama        LlamaConfig        `xml:"llama"`
+	SimScan      SimScanConfig      `xml:"simscan"`
+	Bot          BotConfig          `xml:"bot"`
+	Prompts      PromptsConfig      `xml:"prompts"`
+	Notecards    NotecardsConfig    `xml:"notecards"`
+	Roles        RolesConfig        `xml:"roles"`
+	Conversation ConversationConfig `xml:"conversation"`
+	Knowledge    KnowledgeConfig    `xml:"knowledge"`
+	Moderation   ModerationConfig   `xml:"moderation"`
+	RateLimit    RateLimitConfig    `xml:"rateLimit"`
+	Language     LanguageConfig     `xml:"language"`
+	Personas     PersonasConfig     `xml:"personas"`
+	Queue        QueueConfig        `xml:"queue"`
+	Intent       IntentConfig       `xml:"intent"`
+	HUD          HUDConfig          `xml:"hud"`
+	LSL          LSLConfig          `xml:"lsl"`
+	Schedules    SchedulesConfig    `xml:"schedules"`
+	Calendar     CalendarConfig     `xml:"calendar"`
+	Messages     MessagesConfig     `xml:"messages"`
+	Visitors     VisitorsConfig     `xml:"visitors"`
+	Greeting     GreetingConfig     `xml:"greeting"`
+	Webhooks     WebhooksConfig     `xml:"webhooks"`
+	Bridge       BridgeConfig       `xml:"bridge"`
 }
 
 // CorradeConfig holds Corrade connection settings
@@ -23,54 +50,415 @@ type CorradeConfig struct {
 	Password string `xml:"password"`
 }
 
-// LlamaConfig holds Llama API settings
+// LlamaConfig holds settings for the language model backend
 type LlamaConfig struct {
-	Enabled bool   `xml:"enabled"`
-	URL     string `xml:"url"`
-	Model   string `xml:"model"`
+	Enabled     bool            `xml:"enabled"`
+	Provider    string          `xml:"provider"` // ollama (default), openai or scripted
+	URL         string          `xml:"url"`      // Server URL; for openai the API base, e.g. http://localhost:8080/v1
+	Model       string          `xml:"model"`
+	APIKey      string          `xml:"apiKey"`      // Bearer token for openai-compatible servers that need one
+	EmbedModel  string          `xml:"embedModel"`  // Model used for knowledge base embeddings, defaults to model
+	Stream      bool            `xml:"stream"`      // Start replying as soon as the first sentences arrive
+	Temperature *float64        `xml:"temperature"` // Model defaults are used for options left out
+	TopP        *float64        `xml:"topP"`
+	NumCtx      int             `xml:"numCtx"`       // Context window size in tokens
+	NumPredict  int             `xml:"numPredict"`   // Maximum tokens to generate
+	Stop        []string        `xml:"stop"`         // Stop sequences, one <stop> element each
+	KeepAlive   string          `xml:"keepAlive"`    // How long the model stays loaded, e.g. "10m"
+	Tools       bool            `xml:"tools"`        // Let the model move the bot and play macros through tool calls
+	Script      []ScriptedReply `xml:"script>reply"` // Replies for the scripted provider
+}
+
+// ScriptedReply is a canned reply for the scripted provider, e.g.
+// <reply match="opening hours">We're open 8pm to midnight SLT.</reply>.
+// Replies without a match are used in turn for anything else. A reply with a
+// tool calls that tool first, e.g. <reply match="follow" tool="follow" args="{}">On my way!</reply>.
+type ScriptedReply struct {
+	Match string `xml:"match,attr"`
+	Tool  string `xml:"tool,attr"`
+	Args  string `xml:"args,attr"` // Tool arguments as a JSON object
+	Text  string `xml:",chardata"`
 }
 
 type SimScanConfig struct {
 	Enabled bool   `xml:"enabled"`
-	Storage    string `xml:"storage"`
+	Storage string `xml:"storage"`
+}
+
+// NotecardsConfig holds settings for reading FAQ notecards from inventory
+type NotecardsConfig struct {
+	Enabled         bool   `xml:"enabled"`
+	Folder          string `xml:"folder"`          // Inventory folder holding the notecards
+	Cache           string `xml:"cache"`           // Local file the notecard text is cached in
+	IncludeInPrompt bool   `xml:"includeInPrompt"` // Add notecard text to the Llama system prompt
+	MaxPromptChars  int    `xml:"maxPromptChars"`  // Limit on notecard text added to the prompt
+}
+
+// ConversationConfig holds settings for remembering recent chat with each avatar
+type ConversationConfig struct {
+	Enabled       bool   `xml:"enabled"`
+	MaxTurns      int    `xml:"maxTurns"`      // Exchanges with an avatar included in the prompt
+	IdleExpiry    int    `xml:"idleExpiry"`    // Minutes of silence before a conversation is forgotten
+	Summarize     bool   `xml:"summarize"`     // Summarize older exchanges instead of dropping them
+	SummaryPrompt string `xml:"summaryPrompt"` // Prompt used to summarize older exchanges
+}
+
+// KnowledgeConfig holds settings for answering from a folder of documents
+type KnowledgeConfig struct {
+	Enabled    bool    `xml:"enabled"`
+	Directory  string  `xml:"directory"`  // Folder of .md and .txt files to index
+	Index      string  `xml:"index"`      // File the embeddings are saved to
+	ChunkChars int     `xml:"chunkChars"` // Approximate size of the indexed passages
+	TopK       int     `xml:"topK"`       // Passages added to the prompt
+	MinScore   float64 `xml:"minScore"`   // Minimum similarity for a passage to be used
+}
+
+// ModerationConfig holds the rules applied to incoming messages and outgoing replies
+type ModerationConfig struct {
+	Enabled         bool     `xml:"enabled"`
+	Words           []string `xml:"blocklist>word"`    // Blocked words, matched as whole words
+	Patterns        []string `xml:"blocklist>pattern"` // Blocked regular expressions
+	MaxLinks        *int     `xml:"maxLinks"`          // Most web links allowed in a message; no limit if left out
+	SelfCheck       bool     `xml:"selfCheck"`         // Ask the model whether its reply is appropriate
+	SelfCheckPrompt string   `xml:"selfCheckPrompt"`
+	IncomingAction  string   `xml:"incomingAction"` // drop (ignore the message) or replace (answer with the error message)
+	OutgoingAction  string   `xml:"outgoingAction"` // drop (say nothing) or replace (say the error message instead)
+	NotifyOwners    bool     `xml:"notifyOwners"`   // IM the owners when something is blocked
+}
+
+// RateLimitConfig holds flood protection settings
+type RateLimitConfig struct {
+	Enabled        bool    `xml:"enabled"`
+	PerAvatarRate  float64 `xml:"perAvatarRate"`  // Messages per minute each avatar may send the bot
+	PerAvatarBurst int     `xml:"perAvatarBurst"` // Messages an avatar may send in a quick burst
+	GlobalRate     float64 `xml:"globalRate"`     // LLM replies per minute across all avatars
+	GlobalBurst    int     `xml:"globalBurst"`
+	WarnMessage    string  `xml:"warnMessage"`   // Said once per minute to an avatar who is being limited
+	IgnoreAfter    int     `xml:"ignoreAfter"`   // Limited messages within a minute before the avatar is ignored
+	IgnoreMinutes  int     `xml:"ignoreMinutes"` // How long abusive avatars are ignored
+	ExemptRole     string  `xml:"exemptRole"`    // Avatars with this role or higher are not limited
+}
+
+// HUDConfig holds settings for signed commands from HUDs and in-world scripts
+type HUDConfig struct {
+	Enabled         bool   `xml:"enabled"`
+	Channel         int    `xml:"channel"`         // Chat channel commands are sent on
+	ResponseChannel int    `xml:"responseChannel"` // Chat channel replies are said on
+	Secret          string `xml:"secret"`          // Shared secret requests are signed with
+	MaxSkew         int    `xml:"maxSkew"`         // Seconds a signed request stays valid
+}
+
+// LSLConfig holds settings for events pushed by in-world scripts over HTTP
+type LSLConfig struct {
+	Enabled bool        `xml:"enabled"`
+	Storage string      `xml:"storage"` // File objects registered at runtime are saved to
+	MaxSkew int         `xml:"maxSkew"` // Seconds a signed request stays valid
+	Objects []LSLObject `xml:"objects>object"`
+	Events  []LSLEvent  `xml:"events>event"`
+}
+
+// LSLObject is an in-world object trusted to send events, e.g.
+// <object uuid="..." secret="..." events="door-opened">Front door</object>
+type LSLObject struct {
+	UUID   string `xml:"uuid,attr"`
+	Secret string `xml:"secret,attr"`
+	Events string `xml:"events,attr"` // Comma separated events it may send; empty for any
+	Name   string `xml:",chardata"`
+}
+
+// LSLEvent is what the bot does when an event arrives. Say and prompt are
+// templates that can use the event's data as {{.Data.name}}.
+type LSLEvent struct {
+	Name   string `xml:"name,attr"`
+	Say    string `xml:"say"`    // Said in local chat
+	Macro  string `xml:"macro"`  // Macro to play
+	Prompt string `xml:"prompt"` // Prompt for the LLM, whose reply is said in local chat
+}
+
+// FindLSLEvent looks up an LSL event by name, ignoring case
+func (c *Config) FindLSLEvent(name string) (*LSLEvent, bool) {
+	for i := range c.LSL.Events {
+		if strings.EqualFold(c.LSL.Events[i].Name, name) {
+			return &c.LSL.Events[i], true
+		}
+	}
+	return nil, false
+}
+
+// SchedulesConfig holds tasks run on cron schedules in SL time
+type SchedulesConfig struct {
+	Storage string          `xml:"storage"` // File tasks added or edited at runtime are saved to
+	Tasks   []ScheduledTask `xml:"task"`
+}
+
+// ScheduledTask is an action run on a schedule, e.g.
+// <task name="doors" cron="0 20 * * fri" action="say">Doors are open!</task>
+type ScheduledTask struct {
+	Name     string `xml:"name,attr" json:"name"`
+	Cron     string `xml:"cron,attr" json:"cron"`                   // minute hour day month weekday, in SL time
+	Action   string `xml:"action,attr" json:"action"`               // say, im, macro, teleport or autogreet
+	To       string `xml:"to,attr" json:"to,omitempty"`             // For im: comma separated UUIDs or role names
+	Region   string `xml:"region,attr" json:"region,omitempty"`     // Only run while the bot is in this region
+	Disabled bool   `xml:"disabled,attr" json:"disabled,omitempty"` // Keep the task without running it
+	Value    string `xml:",chardata" json:"value"`                  // Message, macro, landmark or region, or on/off
+}
+
+// CalendarConfig holds settings for the venue's events and their reminders
+type CalendarConfig struct {
+	Storage         string `xml:"storage"`         // File events and subscriptions are saved to
+	ReminderMinutes int    `xml:"reminderMinutes"` // Minutes before the start subscribers are sent an IM
+	AnnounceMinutes int    `xml:"announceMinutes"` // Minutes before the start it is announced in local chat
+	ReminderMessage string `xml:"reminderMessage"` // IM template, e.g. {{.Data.title}} starts at {{.Data.time}}
+	AnnounceMessage string `xml:"announceMessage"` // Local chat template
+	KeepHours       int    `xml:"keepHours"`       // Hours after the start an event is kept before it is dropped
+}
+
+// MessagesConfig holds settings for messages left for avatars who aren't around
+type MessagesConfig struct {
+	Storage         string `xml:"storage"`         // File pending messages are saved to
+	MaxPerSender    int    `xml:"maxPerSender"`    // Pending messages one avatar may leave
+	ExpireDays      int    `xml:"expireDays"`      // Days an undelivered message is kept
+	DeliveryMessage string `xml:"deliveryMessage"` // IM template, e.g. {{.Data.from}} said at {{.Data.time}}: {{.Data.text}}
+}
+
+// VisitorsConfig holds settings for the registry of avatars who have visited
+type VisitorsConfig struct {
+	Storage string `xml:"storage"` // File the visitor registry is saved to
+	History int    `xml:"history"` // Recent visits kept per visitor
+}
+
+// GreetingConfig holds the policies for greeting avatars who arrive. Auto-greet
+// must be turned on; its macro is used for cases without a policy.
+type GreetingConfig struct {
+	Storage       string           `xml:"storage"`       // File greeted avatars and opt-outs are saved to
+	CooldownHours int              `xml:"cooldownHours"` // Hours before the same avatar is greeted again
+	Radius        float64          `xml:"radius"`        // Only greet avatars within this many meters; 0 for the whole region
+	MinDwell      int              `xml:"minDwell"`      // Seconds an avatar must stay before being greeted
+	Quiet         string           `xml:"quiet"`         // Comma separated names or UUIDs never greeted
+	Policies      []GreetingPolicy `xml:"policy"`
+}
+
+// GreetingPolicy is how one case of visitor is greeted. Say, IM and prompt
+// are templates that can use {{.Data.visits}} and {{.Data.lastVisit}}.
+type GreetingPolicy struct {
+	Case   string `xml:"case,attr"`  // new or returning
+	Macro  string `xml:"macro,attr"` // Macro to play
+	Say    string `xml:"say"`        // Said in local chat
+	IM     string `xml:"im"`         // Sent to the avatar as an IM
+	Prompt string `xml:"prompt"`     // Prompt for the LLM, whose reply is said in local chat
+}
+
+// NotificationURL returns the URL Corrade posts notifications and callbacks
+// to. It carries the callback token so forged notifications can be refused.
+func (c *Config) NotificationURL() string {
+	return fmt.Sprintf("http://localhost:%d/corrade/notifications?token=%s", c.Bot.WebPort, url.QueryEscape(c.Bot.CallbackToken))
+}
+
+// FindGreetingPolicy looks up the policy for a case of visitor
+func (c *Config) FindGreetingPolicy(visitor string) (*GreetingPolicy, bool) {
+	for i := range c.Greeting.Policies {
+		if strings.EqualFold(c.Greeting.Policies[i].Case, visitor) {
+			return &c.Greeting.Policies[i], true
+		}
+	}
+	return nil, false
+}
+
+// WebhooksConfig holds the URLs bot events are posted to
+type WebhooksConfig struct {
+	Retries    *int      `xml:"retries"`    // Further attempts after a failed delivery; 5 if left out
+	Timeout    int       `xml:"timeout"`    // Seconds to wait for a response
+	DeadLetter string    `xml:"deadLetter"` // File failed deliveries are appended to
+	Hooks      []Webhook `xml:"hook"`
+}
+
+// Webhook is a URL that events are posted to, e.g.
+// <hook name="crm" events="avatar.arrived,payment" secret="...">https://example.com/hook</hook>
+type Webhook struct {
+	Name   string `xml:"name,attr"`
+	Events string `xml:"events,attr"` // Comma separated events; empty for all
+	Secret string `xml:"secret,attr"` // Signs the body as X-SLBot-Signature
+	URL    string `xml:",chardata"`
+}
+
+// BridgeConfig holds settings for mirroring chat to an external chat room
+type BridgeConfig struct {
+	Enabled        bool   `xml:"enabled"`
+	URL            string `xml:"url"`            // Where relayed chat is posted
+	Secret         string `xml:"secret"`         // Signs outbound posts and authenticates inbound messages
+	Relay          string `xml:"relay"`          // Comma separated message types to relay: local, im
+	Channels       string `xml:"channels"`       // Comma separated local chat channels to relay; empty for all
+	Ignore         string `xml:"ignore"`         // Comma separated UUIDs never relayed, e.g. other bridge bots
+	OutboundFormat string `xml:"outboundFormat"` // How relayed chat reads, with {name}, {text} and {type}
+	InboundFormat  string `xml:"inboundFormat"`  // How inbound messages are said, with {source}, {name} and {text}
+	MaxSkew        int    `xml:"maxSkew"`        // Seconds a signed inbound message stays valid
+}
+
+// IntentConfig holds settings for working out what a message is trying to do
+type IntentConfig struct {
+	UseLLM        bool         `xml:"useLLM"`        // Ask the model when the rules aren't sure
+	MinConfidence float64      `xml:"minConfidence"` // Below this confidence the model is asked
+	Prompt        string       `xml:"prompt"`        // Prompt asking the model for the intent
+	Rules         []IntentRule `xml:"rules>rule"`    // Added to the built-in rules
+}
+
+// IntentRule scores a phrase for an intent, e.g. <rule intent="greeting" weight="1">aloha</rule>.
+// Phrases match whole words; with regex="true" the text is a regular expression.
+type IntentRule struct {
+	Intent  string  `xml:"intent,attr"`
+	Weight  float64 `xml:"weight,attr"`
+	Regex   bool    `xml:"regex,attr"`
+	Pattern string  `xml:",chardata"`
+}
+
+// QueueConfig limits how many LLM replies are worked on at once
+type QueueConfig struct {
+	Workers     int    `xml:"workers"`     // Replies generated at the same time
+	MaxQueue    int    `xml:"maxQueue"`    // Questions waiting for a worker before the bot says it's busy
+	BusyMessage string `xml:"busyMessage"` // Said when the queue is full
+}
+
+// LanguageConfig holds settings for answering visitors in their own language
+type LanguageConfig struct {
+	Enabled bool   `xml:"enabled"`
+	Default string `xml:"default"` // Language code used until a visitor's language is known
+	Storage string `xml:"storage"` // File that avatars' languages are saved to
+}
+
+// RolesConfig holds role assignments and the roles required by commands and APIs
+type RolesConfig struct {
+	Storage     string           `xml:"storage"` // File that role assignments are saved to
+	Assignments []RoleAssignment `xml:"assignments>assign"`
+	Commands    []RoleOverride   `xml:"commands>command"`
+	APIs        []RoleOverride   `xml:"apis>api"`
+	APIKeys     []APIKey         `xml:"apiKeys>key"`
+}
+
+// RoleAssignment gives an avatar a role, e.g. <assign uuid="..." role="staff">Jane Doe</assign>
+type RoleAssignment struct {
+	UUID string `xml:"uuid,attr"`
+	Role string `xml:"role,attr"`
+	Name string `xml:",chardata"`
+}
+
+// RoleOverride changes the role required by a chat command or an API route,
+// e.g. <command name="play macro" role="trusted"/> or <api route="POST /api/walk" role="admin"/>
+type RoleOverride struct {
+	Name  string `xml:"name,attr"`
+	Route string `xml:"route,attr"`
+	Role  string `xml:"role,attr"`
+}
+
+// APIKey grants web API callers a role, e.g. <key name="staff-laptop" role="staff">secret</key>
+type APIKey struct {
+	Name  string `xml:"name,attr"`
+	Role  string `xml:"role,attr"`
+	Token string `xml:",chardata"`
 }
 
 // BotConfig holds bot-specific settings
 type BotConfig struct {
-	Name                    string   `xml:"name"`
-   ChatName                string   `xml:"chatname"`
-	UUID                    string   `xml:"uuid"`
-	MaxMessageLen           int      `xml:"maxMessageLen"`
-	PollInterval            int      `xml:"pollInterval"`
-	ResponseTimeout         int      `xml:"responseTimeout"`
-	WebPort                 int      `xml:"webPort"`
-	IdleTimeout             int      `xml:"idleTimeout"`             // Minutes before idle behavior
-	IdleBehaviorMinInterval int      `xml:"idleBehaviorMinInterval"` // Minimum minutes between idle behaviors
-	IdleBehaviorMaxInterval int      `xml:"idleBehaviorMaxInterval"` // Maximum minutes between idle behaviors
-   Home                    string   `xml:"home"`
-	Owners                  []string `xml:"owners>owner"`
-//   Regions                 []Location `xml:"regions"`
+	Name                    string     `xml:"name"`
+	ChatName                string     `xml:"chatname"`
+	UUID                    string     `xml:"uuid"`
+	MaxMessageLen           int        `xml:"maxMessageLen"`
+	PollInterval            int        `xml:"pollInterval"`
+	ResponseTimeout         int        `xml:"responseTimeout"`
+	WebPort                 int        `xml:"webPort"`
+	CallbackToken           string     `xml:"callbackToken"`           // Secret in the URL Corrade posts notifications to; derived from the Corrade password if left out
+	IdleTimeout             int        `xml:"idleTimeout"`             // Minutes before idle behavior
+	IdleBehaviorMinInterval int        `xml:"idleBehaviorMinInterval"` // Minimum minutes between idle behaviors
+	IdleBehaviorMaxInterval int        `xml:"idleBehaviorMaxInterval"` // Maximum minutes between idle behaviors
+	Home                    string     `xml:"home"`
+	Owners                  []Owner    `xml:"owners>owner"`
+	Landmarks               []Landmark `xml:"landmarks>landmark"`
+	// Regions                 []Location `xml:"regions"`
+}
+
+// Owner identifies a bot owner by UUID, e.g. <owner uuid="...">Owner Name</owner>.
+// The name is only used for display and to look up a missing UUID at startup.
+type Owner struct {
+	UUID string `xml:"uuid,attr"`
+	Name string `xml:",chardata"`
+}
+
+// Landmark is a named place the bot can walk or teleport to, e.g.
+// <landmark name="fountain" region="My Region" x="128" y="128" z="22"/>
+type Landmark struct {
+	Name   string  `xml:"name,attr"`
+	Region string  `xml:"region,attr"`
+	X      float64 `xml:"x,attr"`
+	Y      float64 `xml:"y,attr"`
+	Z      float64 `xml:"z,attr"`
+}
+
+// FindLandmark looks up a landmark by name, ignoring case
+func (c *Config) FindLandmark(name string) (*Landmark, bool) {
+	name = strings.TrimSpace(name)
+	for i := range c.Bot.Landmarks {
+		if strings.EqualFold(c.Bot.Landmarks[i].Name, name) {
+			return &c.Bot.Landmarks[i], true
+		}
+	}
+	return nil, false
 }
 
 // PromptsConfig holds various prompts for different situations
 type PromptsConfig struct {
-	SystemPrompt      string            `xml:"systemPrompt"`
-	ChatPrompt        string            `xml:"chatPrompt"`
-	WelcomeMessage    string            `xml:"welcomeMessage"`
-	ErrorMessage      string            `xml:"errorMessage"`
-	GreetingPrompt    string            `xml:"greetingPrompt"`
-	HelpPrompt        string            `xml:"helpPrompt"`
-	FallbackResponses FallbackResponses `xml:"fallbackResponses"`
+	SystemPrompt      string              `xml:"systemPrompt"`
+	ChatPrompt        string              `xml:"chatPrompt"`
+	WelcomeMessage    string              `xml:"welcomeMessage"`
+	ErrorMessage      string              `xml:"errorMessage"`
+	GreetingPrompt    string              `xml:"greetingPrompt"`
+	HelpPrompt        string              `xml:"helpPrompt"`
+	FallbackResponses []FallbackResponses `xml:"fallbackResponses"` // One set per language
 }
 
-// FallbackResponses holds predefined responses when AI is disabled
+// FallbackResponses holds predefined responses when AI is disabled. Sets
+// for other languages carry a language code, e.g. <fallbackResponses lang="es">.
 type FallbackResponses struct {
+	Language string `xml:"lang,attr"`
 	Greeting string `xml:"greeting"`
+	Farewell string `xml:"farewell"`
 	Help     string `xml:"help"`
 	General  string `xml:"general"`
 	Unknown  string `xml:"unknown"`
 }
 
+// PersonasConfig holds alternative sets of prompts the bot can switch between
+type PersonasConfig struct {
+	Default  string    `xml:"default"` // Persona used when none is chosen or bound; empty for <prompts>
+	Storage  string    `xml:"storage"` // File the persona chosen at runtime is saved to
+	Personas []Persona `xml:"persona"`
+}
+
+// Persona is a named set of prompts. Anything left out is taken from <prompts>.
+type Persona struct {
+	Name     string `xml:"name,attr"`
+	Region   string `xml:"region,attr"`   // Used automatically while the bot is in this region
+	Schedule string `xml:"schedule,attr"` // Used automatically at these SL times, e.g. "fri,sat 20:00-23:00"
+	PromptsConfig
+}
+
+// Fallbacks returns the fallback responses for a language code, or the
+// default set (the one without a language) if there is no translation
+func (p PromptsConfig) Fallbacks(language string) FallbackResponses {
+	for _, set := range p.FallbackResponses {
+		if strings.EqualFold(set.Language, language) {
+			return set
+		}
+	}
+	for _, set := range p.FallbackResponses {
+		if set.Language == "" {
+			return set
+		}
+	}
+	if len(p.FallbackResponses) > 0 {
+		return p.FallbackResponses[0]
+	}
+	return FallbackResponses{}
+}
+
 // Load loads configuration from XML file
 func Load(path string) (*Config, error) {
 	file, err := os.Open(path)
@@ -89,9 +477,281 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	config.setDefaults()
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
+// validate checks the prompt templates and other settings so mistakes show
+// up at startup rather than when someone talks to the bot
+func (c *Config) validate() error {
+	if c.Webhooks.Retries != nil && *c.Webhooks.Retries < 0 {
+		return fmt.Errorf("webhook retries can't be negative")
+	}
+	if c.Bridge.Enabled && (c.Bridge.URL == "" || c.Bridge.Secret == "") {
+		return fmt.Errorf("bridge url and secret must be set")
+	}
+	if c.HUD.Enabled {
+		if c.HUD.Channel == 0 {
+			return fmt.Errorf("hud channel must not be 0, the public chat channel")
+		}
+		if c.HUD.Secret == "" {
+			return fmt.Errorf("hud secret must be set")
+		}
+	}
+
+	check := func(where string, prompts PromptsConfig) error {
+		templates := map[string]string{
+			"systemPrompt":   prompts.SystemPrompt,
+			"chatPrompt":     prompts.ChatPrompt,
+			"greetingPrompt": prompts.GreetingPrompt,
+			"helpPrompt":     prompts.HelpPrompt,
+		}
+		for name, text := range templates {
+			if err := prompt.Validate(text); err != nil {
+				return fmt.Errorf("%s %s: %v", where, name, err)
+			}
+		}
+		return nil
+	}
+
+	if err := check("prompts", c.Prompts); err != nil {
+		return err
+	}
+	for _, persona := range c.Personas.Personas {
+		if err := check(fmt.Sprintf("persona %s", persona.Name), persona.PromptsConfig); err != nil {
+			return err
+		}
+	}
+	if err := prompt.Validate(c.Conversation.SummaryPrompt); err != nil {
+		return fmt.Errorf("conversation summaryPrompt: %v", err)
+	}
+	if err := prompt.Validate(c.Moderation.SelfCheckPrompt); err != nil {
+		return fmt.Errorf("moderation selfCheckPrompt: %v", err)
+	}
+	if err := prompt.Validate(c.Intent.Prompt); err != nil {
+		return fmt.Errorf("intent prompt: %v", err)
+	}
+	if err := prompt.Validate(c.Calendar.ReminderMessage); err != nil {
+		return fmt.Errorf("calendar reminderMessage: %v", err)
+	}
+	if err := prompt.Validate(c.Calendar.AnnounceMessage); err != nil {
+		return fmt.Errorf("calendar announceMessage: %v", err)
+	}
+	if err := prompt.Validate(c.Messages.DeliveryMessage); err != nil {
+		return fmt.Errorf("messages deliveryMessage: %v", err)
+	}
+	for _, policy := range c.Greeting.Policies {
+		if visitor := strings.ToLower(policy.Case); visitor != "new" && visitor != "returning" {
+			return fmt.Errorf("greeting policy case must be new or returning, not '%s'", policy.Case)
+		}
+		for name, text := range map[string]string{"say": policy.Say, "im": policy.IM, "prompt": policy.Prompt} {
+			if err := prompt.Validate(text); err != nil {
+				return fmt.Errorf("greeting policy %s %s: %v", policy.Case, name, err)
+			}
+		}
+	}
+	for _, event := range c.LSL.Events {
+		if err := prompt.Validate(event.Say); err != nil {
+			return fmt.Errorf("lsl event %s say: %v", event.Name, err)
+		}
+		if err := prompt.Validate(event.Prompt); err != nil {
+			return fmt.Errorf("lsl event %s prompt: %v", event.Name, err)
+		}
+	}
+	return nil
+}
+
+// setDefaults fills in settings that were left out of the XML file
+func (c *Config) setDefaults() {
+	if c.Bot.CallbackToken == "" {
+		// Stay the same across restarts so Corrade doesn't collect stale notifications
+		if c.Corrade.Password != "" {
+			c.Bot.CallbackToken = signature.Hex(c.Corrade.Password, "slbot notifications")[:32]
+		} else {
+			token := make([]byte, 16)
+			rand.Read(token)
+			c.Bot.CallbackToken = hex.EncodeToString(token)
+		}
+	}
+	if c.Notecards.Folder == "" {
+		c.Notecards.Folder = "/My Inventory/Notecards"
+	}
+	if c.Notecards.Cache == "" {
+		c.Notecards.Cache = "notecards.json"
+	}
+	if c.Notecards.MaxPromptChars <= 0 {
+		c.Notecards.MaxPromptChars = 4000
+	}
+	if c.Roles.Storage == "" {
+		c.Roles.Storage = "roles.json"
+	}
+	if c.LSL.Storage == "" {
+		c.LSL.Storage = "lsl_objects.json"
+	}
+	if c.Bridge.Relay == "" {
+		c.Bridge.Relay = "local"
+	}
+	if c.Bridge.OutboundFormat == "" {
+		c.Bridge.OutboundFormat = "{name}: {text}"
+	}
+	if c.Bridge.InboundFormat == "" {
+		c.Bridge.InboundFormat = "[{source}] {name}: {text}"
+	}
+	if c.Bridge.MaxSkew <= 0 {
+		c.Bridge.MaxSkew = 120
+	}
+	if c.Webhooks.Retries == nil {
+		retries := 5
+		c.Webhooks.Retries = &retries
+	}
+	if c.Webhooks.Timeout <= 0 {
+		c.Webhooks.Timeout = 10
+	}
+	if c.Webhooks.DeadLetter == "" {
+		c.Webhooks.DeadLetter = "webhooks_failed.jsonl"
+	}
+	if c.Calendar.Storage == "" {
+		c.Calendar.Storage = "events.json"
+	}
+	if c.Calendar.ReminderMinutes <= 0 {
+		c.Calendar.ReminderMinutes = 30
+	}
+	if c.Calendar.AnnounceMinutes <= 0 {
+		c.Calendar.AnnounceMinutes = 5
+	}
+	if c.Calendar.ReminderMessage == "" {
+		c.Calendar.ReminderMessage = "Reminder: {{.Data.title}} starts at {{.Data.time}} SLT, in {{.Data.minutes}} minutes!"
+	}
+	if c.Calendar.AnnounceMessage == "" {
+		c.Calendar.AnnounceMessage = "{{.Data.title}} starts in {{.Data.minutes}} minutes, don't miss it!"
+	}
+	if c.Calendar.KeepHours <= 0 {
+		c.Calendar.KeepHours = 6
+	}
+	if c.Messages.Storage == "" {
+		c.Messages.Storage = "messages.json"
+	}
+	if c.Messages.MaxPerSender <= 0 {
+		c.Messages.MaxPerSender = 10
+	}
+	if c.Messages.ExpireDays <= 0 {
+		c.Messages.ExpireDays = 30
+	}
+	if c.Messages.DeliveryMessage == "" {
+		c.Messages.DeliveryMessage = "Message from {{.Data.from}} ({{.Data.time}} SLT): {{.Data.text}}"
+	}
+	if c.Visitors.Storage == "" {
+		c.Visitors.Storage = "visitors.json"
+	}
+	if c.Visitors.History <= 0 {
+		c.Visitors.History = 20
+	}
+	if c.Greeting.Storage == "" {
+		c.Greeting.Storage = "greetings.json"
+	}
+	if c.Greeting.CooldownHours <= 0 {
+		c.Greeting.CooldownHours = 24
+	}
+	if c.Greeting.MinDwell <= 0 {
+		c.Greeting.MinDwell = 10
+	}
+	if c.Schedules.Storage == "" {
+		c.Schedules.Storage = "schedules.json"
+	}
+	if c.LSL.MaxSkew <= 0 {
+		c.LSL.MaxSkew = 120
+	}
+	if c.HUD.ResponseChannel == 0 {
+		c.HUD.ResponseChannel = c.HUD.Channel + 1
+	}
+	if c.HUD.MaxSkew <= 0 {
+		c.HUD.MaxSkew = 120
+	}
+	if c.Intent.MinConfidence <= 0 {
+		c.Intent.MinConfidence = 0.5
+	}
+	if c.Intent.Prompt == "" {
+		c.Intent.Prompt = "Classify the intent of this message to a Second Life bot as one of: greeting, farewell, help, question, command, smalltalk. Answer with just the intent and your confidence from 0 to 1, for example: question 0.8\n\nMessage: {{.Message}}"
+	}
+	if c.Queue.Workers <= 0 {
+		c.Queue.Workers = 2
+	}
+	if c.Queue.MaxQueue <= 0 {
+		c.Queue.MaxQueue = 10
+	}
+	if c.Queue.BusyMessage == "" {
+		c.Queue.BusyMessage = "I'm a little busy right now, please ask me again in a moment!"
+	}
+	if c.Personas.Storage == "" {
+		c.Personas.Storage = "persona.json"
+	}
+	if c.Language.Default == "" {
+		c.Language.Default = "en"
+	}
+	if c.Language.Storage == "" {
+		c.Language.Storage = "languages.json"
+	}
+	if c.RateLimit.PerAvatarRate <= 0 {
+		c.RateLimit.PerAvatarRate = 6
+	}
+	if c.RateLimit.PerAvatarBurst <= 0 {
+		c.RateLimit.PerAvatarBurst = 3
+	}
+	if c.RateLimit.GlobalRate <= 0 {
+		c.RateLimit.GlobalRate = 30
+	}
+	if c.RateLimit.GlobalBurst <= 0 {
+		c.RateLimit.GlobalBurst = 5
+	}
+	if c.RateLimit.WarnMessage == "" {
+		c.RateLimit.WarnMessage = "Please slow down a little, I can only keep up with so much!"
+	}
+	if c.RateLimit.IgnoreAfter <= 0 {
+		c.RateLimit.IgnoreAfter = 10
+	}
+	if c.RateLimit.IgnoreMinutes <= 0 {
+		c.RateLimit.IgnoreMinutes = 10
+	}
+	if c.RateLimit.ExemptRole == "" {
+		c.RateLimit.ExemptRole = "owner"
+	}
+	if c.Moderation.IncomingAction == "" {
+		c.Moderation.IncomingAction = "drop"
+	}
+	if c.Moderation.OutgoingAction == "" {
+		c.Moderation.OutgoingAction = "replace"
+	}
+	if c.Moderation.SelfCheckPrompt == "" {
+		c.Moderation.SelfCheckPrompt = "You review messages a bot is about to say in public chat. Answer with the single word SAFE if the message is friendly and appropriate for a general audience, or UNSAFE followed by a short reason if it is hateful, sexual, harassing, threatening or shares personal information.\n\nMessage: {message}"
+	}
+	if c.Knowledge.Directory == "" {
+		c.Knowledge.Directory = "knowledge"
+	}
+	if c.Knowledge.Index == "" {
+		c.Knowledge.Index = "knowledge.json"
+	}
+	if c.Knowledge.ChunkChars <= 0 {
+		c.Knowledge.ChunkChars = 800
+	}
+	if c.Knowledge.TopK <= 0 {
+		c.Knowledge.TopK = 3
+	}
+	if c.Conversation.MaxTurns <= 0 {
+		c.Conversation.MaxTurns = 6
+	}
+	if c.Conversation.IdleExpiry <= 0 {
+		c.Conversation.IdleExpiry = 30
+	}
+	if c.Conversation.SummaryPrompt == "" {
+		c.Conversation.SummaryPrompt = "Summarize this conversation with {botname} in two or three sentences, keeping names, facts and anything the visitor asked to be remembered.\n\n{message}"
+	}
+}
+
 // GetIdleBehaviorMinInterval returns the minimum idle behavior interval
 func (c *Config) GetIdleBehaviorMinInterval() int {
 	return c.Bot.IdleBehaviorMinInterval
@@ -0,0 +1,222 @@
+package conversation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+)
+
+// Roles of the speakers in a conversation
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Turn is a single line of a conversation
+type Turn struct {
+	Role string    `json:"role"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+}
+
+// Conversation is the recent chat between the bot and one avatar
+type Conversation struct {
+	UUID        string    `json:"uuid"`
+	Avatar      string    `json:"avatar"`
+	Summary     string    `json:"summary,omitempty"`
+	Turns       []Turn    `json:"turns"`
+	LastActive  time.Time `json:"lastActive"`
+	summarizing bool
+	dropped     int // Turns trimmed off the front while a summary was in progress
+}
+
+// Store keeps conversations keyed by avatar UUID
+type Store struct {
+	config        config.ConversationConfig
+	conversations map[string]*Conversation
+	mutex         sync.Mutex
+}
+
+// NewStore creates an empty conversation store
+func NewStore(cfg *config.Config) *Store {
+	return &Store{
+		config:        cfg.Conversation,
+		conversations: make(map[string]*Conversation),
+	}
+}
+
+// IsEnabled returns whether conversation history is enabled
+func (s *Store) IsEnabled() bool {
+	return s.config.Enabled
+}
+
+// maxMessages is the number of turns kept, counting both sides of an exchange
+func (s *Store) maxMessages() int {
+	return s.config.MaxTurns * 2
+}
+
+// expired reports whether a conversation has been idle for too long
+func (s *Store) expired(conv *Conversation) bool {
+	return time.Since(conv.LastActive) > time.Duration(s.config.IdleExpiry)*time.Minute
+}
+
+// lookup returns a live conversation, dropping it if it has expired
+func (s *Store) lookup(uuid string) *Conversation {
+	key := strings.ToLower(uuid)
+	conv, exists := s.conversations[key]
+	if !exists {
+		return nil
+	}
+	if s.expired(conv) {
+		delete(s.conversations, key)
+		return nil
+	}
+	return conv
+}
+
+// Get returns a copy of the conversation with an avatar, or nil if there is none
+func (s *Store) Get(uuid string) *Conversation {
+	if !s.config.Enabled || uuid == "" {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv := s.lookup(uuid)
+	if conv == nil {
+		return nil
+	}
+	return conv.copy()
+}
+
+// Append records an exchange with an avatar. Without summarization the
+// oldest turns are dropped once the window is full; with it they are kept
+// for the summarizer, up to twice the window in case summaries can't run.
+func (s *Store) Append(uuid, avatar, question, answer string) {
+	if !s.config.Enabled || uuid == "" {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv := s.lookup(uuid)
+	if conv == nil {
+		conv = &Conversation{UUID: uuid}
+		s.conversations[strings.ToLower(uuid)] = conv
+	}
+
+	now := time.Now()
+	conv.Avatar = avatar
+	conv.LastActive = now
+	conv.Turns = append(conv.Turns,
+		Turn{Role: RoleUser, Text: question, Time: now},
+		Turn{Role: RoleAssistant, Text: answer, Time: now})
+
+	limit := s.maxMessages()
+	if s.config.Summarize {
+		limit *= 2
+	}
+	if excess := len(conv.Turns) - limit; excess > 0 {
+		conv.Turns = append([]Turn(nil), conv.Turns[excess:]...)
+		if conv.summarizing {
+			conv.dropped += excess
+		}
+	}
+}
+
+// Overflow returns the previous summary and the oldest turns that no longer
+// fit in the window, marking the conversation as being summarized. It returns
+// no turns if nothing needs summarizing or a summary is already in progress.
+func (s *Store) Overflow(uuid string) (string, []Turn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv := s.lookup(uuid)
+	if conv == nil || conv.summarizing || len(conv.Turns) <= s.maxMessages() {
+		return "", nil
+	}
+
+	conv.summarizing = true
+	count := len(conv.Turns) - s.maxMessages()
+	return conv.Summary, append([]Turn(nil), conv.Turns[:count]...)
+}
+
+// Compact replaces the oldest turns of a conversation with a summary. An
+// empty summary keeps the previous one, so failed summaries just trim.
+func (s *Store) Compact(uuid, summary string, count int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	conv := s.lookup(uuid)
+	if conv == nil {
+		return
+	}
+
+	conv.summarizing = false
+	if summary != "" {
+		conv.Summary = summary
+	}
+	// Some of the summarized turns may already have been trimmed
+	count -= conv.dropped
+	conv.dropped = 0
+	if count < 0 {
+		count = 0
+	}
+	if count > len(conv.Turns) {
+		count = len(conv.Turns)
+	}
+	conv.Turns = append([]Turn(nil), conv.Turns[count:]...)
+}
+
+// Clear forgets the conversation with an avatar
+func (s *Store) Clear(uuid string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := strings.ToLower(uuid)
+	_, exists := s.conversations[key]
+	delete(s.conversations, key)
+	return exists
+}
+
+// ClearAll forgets every conversation and returns how many there were
+func (s *Store) ClearAll() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	count := len(s.conversations)
+	s.conversations = make(map[string]*Conversation)
+	return count
+}
+
+// List returns copies of the live conversations, most recently active first
+func (s *Store) List() []*Conversation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]*Conversation, 0, len(s.conversations))
+	for key, conv := range s.conversations {
+		if s.expired(conv) {
+			delete(s.conversations, key)
+			continue
+		}
+		result = append(result, conv.copy())
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].LastActive.After(result[j].LastActive)
+	})
+	return result
+}
+
+// copy returns a snapshot of a conversation that is safe to use unlocked
+func (c *Conversation) copy() *Conversation {
+	snapshot := *c
+	snapshot.Turns = append([]Turn(nil), c.Turns...)
+	return &snapshot
+}
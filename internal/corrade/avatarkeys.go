@@ -0,0 +1,51 @@
+package corrade
+
+import (
+	"encoding/csv"
+	"strings"
+)
+
+// ResolveAvatarKeys looks up the UUIDs of avatars by name. Names that could
+// not be resolved are left out of the returned map.
+func (c *Client) ResolveAvatarKeys(names []string) (map[string]string, error) {
+	keys := make(map[string]string)
+	if len(names) == 0 {
+		return keys, nil
+	}
+
+	var avatars strings.Builder
+	w := csv.NewWriter(&avatars)
+	if err := w.Write(names); err != nil {
+		return nil, err
+	}
+	w.Flush()
+
+	params := map[string]string{
+		"avatars": strings.TrimSpace(avatars.String()),
+	}
+	response, err := c.sendCommand("batchavatarnametokey", params)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, err := parseResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(strings.NewReader(answers.Get("data")))
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil {
+		// No names were resolved
+		return keys, nil
+	}
+
+	// Data is a flat list of name,UUID pairs
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i+1] != "" && fields[i+1] != "00000000-0000-0000-0000-000000000000" {
+			keys[fields[i]] = fields[i+1]
+		}
+	}
+	return keys, nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -113,6 +114,28 @@ func (c *Client) SetupNotification(eventType, callbackURL string) error {
 	return nil
 }
 
+// SetupChannelNotification sets up a notification for local chat heard on
+// a channel other than 0
+func (c *Client) SetupChannelNotification(channel int, callbackURL string) error {
+	params := map[string]string{
+		"action":  "add",
+		"type":    "local",
+		"URL":     callbackURL,
+		"channel": strconv.Itoa(channel),
+	}
+	response, err := c.sendCommand("notify", params)
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(response, "success") {
+		return fmt.Errorf("failed to setup notification for channel %d: %s", channel, response)
+	}
+
+	log.Printf("Setup notification for channel %d to %s", channel, callbackURL)
+	return nil
+}
+
 // RequestAvatarData requests avatar data for all avatars in the region
 // This will trigger callbacks with avatar information
 func (c *Client) RequestAvatarData(region string, callbackURL string) error {
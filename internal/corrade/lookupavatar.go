@@ -23,6 +23,13 @@ func (c *Client) lookupByName(name string) (*types.AvatarInfo, error) {
    return nil, errors.New("no Matching Avatar")
 }
 
+// LookupAvatar finds a nearby avatar by name
+func (c *Client) LookupAvatar(name string) (*types.AvatarInfo, error) {
+   c.avatarsMutex.RLock()
+   defer c.avatarsMutex.RUnlock()
+   return c.lookupByName(name)
+}
+
 func (c *Client) GetBotName() string {
    return c.botName
 }
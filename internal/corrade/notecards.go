@@ -0,0 +1,125 @@
+package corrade
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// InventoryItem represents an entry returned by an inventory listing
+type InventoryItem struct {
+	Name string
+	UUID string
+	Type string
+}
+
+// parseResponse decodes a Corrade response and checks that the command succeeded
+func parseResponse(response string) (url.Values, error) {
+	answers, err := url.ParseQuery(strings.TrimSpace(response))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(answers.Get("success"), "True") {
+		reason := answers.Get("error")
+		if reason == "" {
+			reason = response
+		}
+		return nil, fmt.Errorf("corrade %s failed: %s", answers.Get("command"), reason)
+	}
+	return answers, nil
+}
+
+// ListNotecards lists the notecards in an inventory folder
+func (c *Client) ListNotecards(folder string) ([]InventoryItem, error) {
+	params := map[string]string{
+		"action": "ls",
+		"path":   folder,
+	}
+	response, err := c.sendCommand("inventory", params)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, err := parseResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(strings.NewReader(answers.Get("data")))
+	r.FieldsPerRecord = -1
+	fields, err := r.Read()
+	if err != nil {
+		// An empty folder has no data at all
+		return nil, nil
+	}
+
+	// Data is a flat list of key,value pairs, each item starting with "name"
+	var items []InventoryItem
+	var current *InventoryItem
+	for i := 0; i+1 < len(fields); i += 2 {
+		key, value := fields[i], fields[i+1]
+		switch key {
+		case "name":
+			items = append(items, InventoryItem{Name: value})
+			current = &items[len(items)-1]
+		case "item":
+			if current != nil {
+				current.UUID = value
+			}
+		case "type":
+			if current != nil {
+				current.Type = value
+			}
+		}
+	}
+
+	notecards := make([]InventoryItem, 0, len(items))
+	for _, item := range items {
+		if strings.EqualFold(item.Type, "Notecard") {
+			notecards = append(notecards, item)
+		}
+	}
+	return notecards, nil
+}
+
+// ReadNotecard downloads a notecard and returns its text
+func (c *Client) ReadNotecard(item string) (string, error) {
+	params := map[string]string{
+		"item": item,
+		"type": "Notecard",
+	}
+	response, err := c.sendCommand("download", params)
+	if err != nil {
+		return "", err
+	}
+
+	answers, err := parseResponse(response)
+	if err != nil {
+		return "", err
+	}
+
+	return notecardText(answers.Get("data")), nil
+}
+
+var notecardBodyRegex = regexp.MustCompile(`(?s)Text length \d+\n(.*)}\s*$`)
+
+// notecardText decodes downloaded notecard data and strips the asset wrapper
+func notecardText(data string) string {
+	text := data
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil && utf8.Valid(decoded) {
+		text = string(decoded)
+	}
+
+	// Raw notecard assets are wrapped in "Linden text version 2 { ... }"
+	if strings.HasPrefix(text, "Linden text version") {
+		if matches := notecardBodyRegex.FindStringSubmatch(text); len(matches) == 2 {
+			text = matches[1]
+		}
+	}
+
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,144 @@
+package greeting
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+)
+
+// Cases of visitor that can be greeted differently
+const (
+	CaseNew       = "new"
+	CaseReturning = "returning"
+)
+
+// Greeted records when an avatar was last greeted
+type Greeted struct {
+	Name string    `json:"name"`
+	At   time.Time `json:"at"`
+}
+
+// state is what is saved across restarts
+type state struct {
+	Greeted map[string]*Greeted `json:"greeted"` // UUID -> last greeting
+	OptOut  map[string]string   `json:"optOut"`  // UUID -> name of avatars who asked not to be greeted
+}
+
+// Manager remembers who has been greeted and who shouldn't be
+type Manager struct {
+	config config.GreetingConfig
+	quiet  map[string]bool // Lower-case names and UUIDs from the configuration
+	state  state
+	mutex  sync.RWMutex
+}
+
+// NewManager creates a greeting manager and loads who has been greeted
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config: cfg.Greeting,
+		quiet:  make(map[string]bool),
+		state:  state{Greeted: make(map[string]*Greeted), OptOut: make(map[string]string)},
+	}
+
+	for _, entry := range strings.Split(cfg.Greeting.Quiet, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			manager.quiet[entry] = true
+		}
+	}
+
+	if err := persistant.LoadState(cfg.Greeting.Storage, &manager.state); err != nil {
+		log.Printf("No greetings loaded from %s: %v", cfg.Greeting.Storage, err)
+	}
+	if manager.state.Greeted == nil {
+		manager.state.Greeted = make(map[string]*Greeted)
+	}
+	if manager.state.OptOut == nil {
+		manager.state.OptOut = make(map[string]string)
+	}
+
+	return manager
+}
+
+// Case returns which case of visitor an avatar is from how many visits they've made
+func Case(visits int) string {
+	if visits > 1 {
+		return CaseReturning
+	}
+	return CaseNew
+}
+
+// IsQuiet reports whether an avatar is on the quiet list or has opted out
+func (m *Manager) IsQuiet(uuid, name string) bool {
+	uuid = strings.ToLower(uuid)
+	if m.quiet[uuid] || m.quiet[strings.ToLower(name)] {
+		return true
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, optedOut := m.state.OptOut[uuid]
+	return optedOut
+}
+
+// RecentlyGreeted reports whether an avatar was greeted within the cooldown
+func (m *Manager) RecentlyGreeted(uuid string, now time.Time) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	greeted, exists := m.state.Greeted[strings.ToLower(uuid)]
+	return exists && now.Sub(greeted.At) < time.Duration(m.config.CooldownHours)*time.Hour
+}
+
+// MarkGreeted records that an avatar has been greeted, forgetting
+// greetings whose cooldown has passed
+func (m *Manager) MarkGreeted(uuid, name string, now time.Time) {
+	cooldown := time.Duration(m.config.CooldownHours) * time.Hour
+
+	m.mutex.Lock()
+	for greetedUUID, greeted := range m.state.Greeted {
+		if now.Sub(greeted.At) >= cooldown {
+			delete(m.state.Greeted, greetedUUID)
+		}
+	}
+	m.state.Greeted[strings.ToLower(uuid)] = &Greeted{Name: name, At: now}
+	m.mutex.Unlock()
+
+	m.save()
+}
+
+// OptOut stops an avatar being greeted
+func (m *Manager) OptOut(uuid, name string) {
+	m.mutex.Lock()
+	m.state.OptOut[strings.ToLower(uuid)] = name
+	m.mutex.Unlock()
+
+	m.save()
+}
+
+// OptIn lets an avatar who opted out be greeted again
+func (m *Manager) OptIn(uuid string) bool {
+	m.mutex.Lock()
+	_, optedOut := m.state.OptOut[strings.ToLower(uuid)]
+	delete(m.state.OptOut, strings.ToLower(uuid))
+	m.mutex.Unlock()
+
+	if optedOut {
+		m.save()
+	}
+	return optedOut
+}
+
+// save writes the greeted avatars and opt-outs to disk
+func (m *Manager) save() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if err := persistant.SaveState(m.config.Storage, m.state); err != nil {
+		log.Printf("Failed to save greetings: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package hud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"slbot/internal/config"
+	"slbot/internal/signature"
+)
+
+// Reserved fields of a request; any other field is a command argument
+const (
+	FieldCommand   = "cmd"  // Command name, e.g. "play macro"
+	FieldText      = "text" // A whole chat line to run instead of cmd, e.g. "play macro dance"
+	FieldID        = "id"   // Echoed back in the response
+	FieldTimestamp = "ts"   // Unix time the request was made
+	FieldSignature = "sig"  // HMAC-SHA256 of the other fields, hex or base64
+)
+
+// Request is a signed command from a HUD or script
+type Request struct {
+	Fields map[string]string
+	JSON   bool // The request was JSON, so the response is too
+}
+
+// Command returns the command name
+func (r *Request) Command() string {
+	return strings.TrimSpace(r.Fields[FieldCommand])
+}
+
+// Text returns the chat line to run, if the request carries one
+func (r *Request) Text() string {
+	return strings.TrimSpace(r.Fields[FieldText])
+}
+
+// Args returns the command arguments
+func (r *Request) Args() map[string]string {
+	args := make(map[string]string)
+	for key, value := range r.Fields {
+		switch key {
+		case FieldCommand, FieldText, FieldID, FieldTimestamp, FieldSignature:
+		default:
+			args[key] = value
+		}
+	}
+	return args
+}
+
+// Looks reports whether a chat message is in the protocol format: a JSON
+// object or key=value pairs, with a signature and a timestamp
+func Looks(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "{") {
+		return strings.HasSuffix(text, "}") &&
+			strings.Contains(text, `"`+FieldSignature+`"`) &&
+			strings.Contains(text, `"`+FieldTimestamp+`"`)
+	}
+
+	seen := make(map[string]bool)
+	for _, pair := range strings.Split(text, "&") {
+		key, _, found := strings.Cut(pair, "=")
+		if !found || !isKey(key) {
+			return false
+		}
+		seen[key] = true
+	}
+	return seen[FieldSignature] && seen[FieldTimestamp]
+}
+
+// isKey reports whether a field name is a bare word, so chat that merely
+// mentions "sig=" isn't taken for a request
+func isKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads a request as a JSON object of strings and numbers, or as
+// key=value pairs separated by & with URL escaping
+func Parse(text string) (*Request, error) {
+	text = strings.TrimSpace(text)
+	request := &Request{Fields: make(map[string]string)}
+
+	if strings.HasPrefix(text, "{") {
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &raw); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %v", err)
+		}
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				request.Fields[key] = v
+			case float64:
+				request.Fields[key] = strconv.FormatFloat(v, 'f', -1, 64)
+			case bool:
+				request.Fields[key] = strconv.FormatBool(v)
+			default:
+				return nil, fmt.Errorf("field %s must be a string or number", key)
+			}
+		}
+		request.JSON = true
+		return request, nil
+	}
+
+	values, err := url.ParseQuery(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key=value request: %v", err)
+	}
+	for key := range values {
+		request.Fields[key] = values.Get(key)
+	}
+	return request, nil
+}
+
+// Canonical returns the text that is signed: the fields other than sig
+// sorted by name and joined as key=value&key=value, with values escaped as
+// LSL's llEscapeURL does so no value can pass for other fields
+func Canonical(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if key != FieldSignature {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + escape(fields[key])
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape percent-encodes every byte of a value other than letters and
+// digits, like llEscapeURL
+func escape(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		if b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+// Verifier checks request signatures and rejects replays
+type Verifier struct {
+	secret string
+	guard  *signature.Guard
+}
+
+// NewVerifier creates a verifier for the HUD settings
+func NewVerifier(cfg config.HUDConfig) *Verifier {
+	return &Verifier{
+		secret: cfg.Secret,
+		guard:  signature.NewGuard(time.Duration(cfg.MaxSkew) * time.Second),
+	}
+}
+
+// Verify checks that a request is signed with the shared secret, is recent,
+// and has not been seen before
+func (v *Verifier) Verify(request *Request, now time.Time) error {
+	sig := request.Fields[FieldSignature]
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+	if !signature.Check(v.secret, Canonical(request.Fields), sig) {
+		return fmt.Errorf("bad signature")
+	}
+	return v.guard.Check(request.Fields[FieldTimestamp], sig, now)
+}
+
+// Response formats a reply in the same format as the request
+func Response(request *Request, ok bool, reply string) string {
+	status := "ok"
+	if !ok {
+		status = "error"
+	}
+
+	if request != nil && request.JSON {
+		data, _ := json.Marshal(map[string]string{
+			FieldID:  request.Fields[FieldID],
+			"status": status,
+			"reply":  reply,
+		})
+		return string(data)
+	}
+
+	values := url.Values{}
+	if request != nil && request.Fields[FieldID] != "" {
+		values.Set(FieldID, request.Fields[FieldID])
+	}
+	values.Set("status", status)
+	values.Set("reply", reply)
+	return values.Encode()
+}
+
+// FitResponse formats a reply like Response, shortening the reply text
+// rather than the encoded response so the response stays parseable and
+// is at most limit bytes. It fails if even an empty reply doesn't fit.
+func FitResponse(request *Request, ok bool, reply string, limit int) (string, error) {
+	response := Response(request, ok, reply)
+	text, keep := reply, len(reply)
+	for len(response) > limit {
+		if keep == 0 {
+			return "", fmt.Errorf("the response needs %d bytes, more than the %d allowed", len(response), limit)
+		}
+		// Encoding never shrinks text, so cutting the overflow is never too much
+		keep = max(0, keep-(len(response)-limit))
+		for keep > 0 && !utf8.RuneStart(text[keep]) {
+			keep--
+		}
+		reply = text[:keep] + "..."
+		response = Response(request, ok, reply)
+	}
+	return response, nil
+}
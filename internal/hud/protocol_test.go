@@ -0,0 +1,275 @@
+package hud
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"slbot/internal/config"
+	"slbot/internal/signature"
+)
+
+const testSecret = "change-me"
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   string
+	}{
+		{
+			name:   "sorted with the signature left out",
+			fields: map[string]string{"ts": "1700000000", "cmd": "play macro", "name": "dance", "id": "7", "sig": "abc"},
+			want:   "cmd=play%20macro&id=7&name=dance&ts=1700000000",
+		},
+		{
+			name:   "separators in values",
+			fields: map[string]string{"text": "a&b=c"},
+			want:   "text=a%26b%3Dc",
+		},
+		{
+			name:   "percent",
+			fields: map[string]string{"text": "100%"},
+			want:   "text=100%25",
+		},
+		{
+			name:   "non-ASCII as UTF-8 bytes",
+			fields: map[string]string{"text": "café ☕"},
+			want:   "text=caf%C3%A9%20%E2%98%95",
+		},
+		{
+			name:   "punctuation llEscapeURL escapes",
+			fields: map[string]string{"text": "-_.~+"},
+			want:   "text=%2D%5F%2E%7E%2B",
+		},
+		{
+			name:   "empty value",
+			fields: map[string]string{"text": ""},
+			want:   "text=",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Canonical(test.fields); got != test.want {
+				t.Errorf("Canonical = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalIsUnambiguous(t *testing.T) {
+	// A value can't pass for further fields
+	smuggled := Canonical(map[string]string{"cmd": "say&text=hi"})
+	separate := Canonical(map[string]string{"cmd": "say", "text": "hi"})
+	if smuggled == separate {
+		t.Errorf("both canonicalise to %q", smuggled)
+	}
+}
+
+// signedFields returns a request's fields signed with a secret at a time
+func signedFields(secret string, now time.Time, fields map[string]string) map[string]string {
+	signed := map[string]string{FieldTimestamp: strconv.FormatInt(now.Unix(), 10)}
+	for key, value := range fields {
+		signed[key] = value
+	}
+	signed[FieldSignature] = signature.Hex(secret, Canonical(signed))
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	command := map[string]string{FieldCommand: "play macro", "name": "dance", FieldID: "7"}
+
+	tests := []struct {
+		name    string
+		fields  func() map[string]string
+		wantErr string
+	}{
+		{
+			name:   "valid hex signature",
+			fields: func() map[string]string { return signedFields(testSecret, now, command) },
+		},
+		{
+			name: "valid base64 signature",
+			fields: func() map[string]string {
+				fields := signedFields(testSecret, now, command)
+				raw, _ := hex.DecodeString(fields[FieldSignature])
+				fields[FieldSignature] = base64.StdEncoding.EncodeToString(raw)
+				return fields
+			},
+		},
+		{
+			name:   "within the skew",
+			fields: func() map[string]string { return signedFields(testSecret, now.Add(-100*time.Second), command) },
+		},
+		{
+			name: "tampered field",
+			fields: func() map[string]string {
+				fields := signedFields(testSecret, now, command)
+				fields["name"] = "strip"
+				return fields
+			},
+			wantErr: "bad signature",
+		},
+		{
+			name: "added field",
+			fields: func() map[string]string {
+				fields := signedFields(testSecret, now, command)
+				fields["avatar"] = "Jane Doe"
+				return fields
+			},
+			wantErr: "bad signature",
+		},
+		{
+			name: "tampered timestamp",
+			fields: func() map[string]string {
+				fields := signedFields(testSecret, now, command)
+				fields[FieldTimestamp] = strconv.FormatInt(now.Unix()+1, 10)
+				return fields
+			},
+			wantErr: "bad signature",
+		},
+		{
+			name:    "wrong secret",
+			fields:  func() map[string]string { return signedFields("guessed", now, command) },
+			wantErr: "bad signature",
+		},
+		{
+			name: "missing signature",
+			fields: func() map[string]string {
+				fields := signedFields(testSecret, now, command)
+				delete(fields, FieldSignature)
+				return fields
+			},
+			wantErr: "missing signature",
+		},
+		{
+			name:    "too old",
+			fields:  func() map[string]string { return signedFields(testSecret, now.Add(-121*time.Second), command) },
+			wantErr: "request expired",
+		},
+		{
+			name:    "from the future",
+			fields:  func() map[string]string { return signedFields(testSecret, now.Add(121*time.Second), command) },
+			wantErr: "request expired",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verifier := NewVerifier(config.HUDConfig{Secret: testSecret, MaxSkew: 120})
+			err := verifier.Verify(&Request{Fields: test.fields()}, now)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("Verify returned an error: %v", err)
+			case test.wantErr != "" && (err == nil || err.Error() != test.wantErr):
+				t.Errorf("Verify = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplays(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	verifier := NewVerifier(config.HUDConfig{Secret: testSecret, MaxSkew: 120})
+	fields := signedFields(testSecret, now, map[string]string{FieldCommand: "stand"})
+
+	if err := verifier.Verify(&Request{Fields: fields}, now); err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	if err := verifier.Verify(&Request{Fields: fields}, now.Add(time.Second)); err == nil {
+		t.Error("replayed request accepted")
+	}
+}
+
+func TestParseAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fields := signedFields(testSecret, now, map[string]string{FieldText: "tell Jane \"see you at 8 & bring a friend\" ☕"})
+
+	values := url.Values{}
+	for key, value := range fields {
+		values.Set(key, value)
+	}
+	encoded, _ := json.Marshal(fields)
+
+	for _, text := range []string{values.Encode(), string(encoded)} {
+		if !Looks(text) {
+			t.Errorf("%q doesn't look like a request", text)
+			continue
+		}
+		request, err := Parse(text)
+		if err != nil {
+			t.Errorf("Parse(%q) returned an error: %v", text, err)
+			continue
+		}
+		verifier := NewVerifier(config.HUDConfig{Secret: testSecret, MaxSkew: 120})
+		if err := verifier.Verify(request, now); err != nil {
+			t.Errorf("Verify(%q) returned an error: %v", text, err)
+		}
+	}
+}
+
+func TestFitResponse(t *testing.T) {
+	long := strings.Repeat("é ☕ & ", 200)
+
+	tests := []struct {
+		name    string
+		request *Request
+		reply   string
+		limit   int
+		wantErr bool
+	}{
+		{name: "short key=value", request: &Request{Fields: map[string]string{FieldID: "7"}}, reply: "Done.", limit: 100},
+		{name: "long key=value", request: &Request{Fields: map[string]string{FieldID: "7"}}, reply: long, limit: 300},
+		{name: "long JSON", request: &Request{Fields: map[string]string{FieldID: "7"}, JSON: true}, reply: long, limit: 300},
+		{name: "rejected request", reply: long, limit: 120},
+		{name: "envelope too big", request: &Request{Fields: map[string]string{FieldID: strings.Repeat("x", 50)}}, reply: "Done.", limit: 40, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := FitResponse(test.request, true, test.reply, test.limit)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("FitResponse = %q, want an error", response)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FitResponse returned an error: %v", err)
+			}
+			if len(response) > test.limit {
+				t.Errorf("response is %d bytes, over the limit of %d", len(response), test.limit)
+			}
+
+			var reply string
+			if test.request != nil && test.request.JSON {
+				var decoded map[string]string
+				if err := json.Unmarshal([]byte(response), &decoded); err != nil {
+					t.Fatalf("response %q isn't JSON: %v", response, err)
+				}
+				reply = decoded["reply"]
+			} else {
+				values, err := url.ParseQuery(response)
+				if err != nil {
+					t.Fatalf("response %q isn't key=value: %v", response, err)
+				}
+				reply = values.Get("reply")
+			}
+
+			if !utf8.ValidString(reply) {
+				t.Errorf("reply %q isn't valid UTF-8", reply)
+			}
+			if reply != test.reply && !(strings.HasSuffix(reply, "...") && strings.HasPrefix(test.reply, strings.TrimSuffix(reply, "..."))) {
+				t.Errorf("reply %q isn't the start of the original", reply)
+			}
+		})
+	}
+}
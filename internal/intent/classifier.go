@@ -0,0 +1,244 @@
+package intent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"slbot/internal/config"
+)
+
+// Intents a message can have
+const (
+	Greeting  = "greeting"
+	Farewell  = "farewell"
+	Help      = "help"
+	Question  = "question"
+	Command   = "command"
+	SmallTalk = "smalltalk"
+	General   = "general" // Nothing recognised
+)
+
+// Intents lists the intents the classifier can return, other than General
+var Intents = []string{Greeting, Farewell, Help, Question, Command, SmallTalk}
+
+// Sources of a classification
+const (
+	SourceRules = "rules"
+	SourceLLM   = "llm"
+)
+
+// defaultRules are the built-in phrases for each intent and their weights
+var defaultRules = []config.IntentRule{
+	{Intent: Greeting, Weight: 1, Pattern: "hi"},
+	{Intent: Greeting, Weight: 1, Pattern: "hello"},
+	{Intent: Greeting, Weight: 1, Pattern: "hey"},
+	{Intent: Greeting, Weight: 1, Pattern: "hiya"},
+	{Intent: Greeting, Weight: 1, Pattern: "howdy"},
+	{Intent: Greeting, Weight: 1, Pattern: "greetings"},
+	{Intent: Greeting, Weight: 1, Pattern: "good morning"},
+	{Intent: Greeting, Weight: 1, Pattern: "good afternoon"},
+	{Intent: Greeting, Weight: 1, Pattern: "good evening"},
+	{Intent: Greeting, Weight: 0.8, Pattern: "welcome back"},
+	{Intent: Greeting, Weight: 0.6, Pattern: "yo"},
+	{Intent: Farewell, Weight: 1, Pattern: "bye"},
+	{Intent: Farewell, Weight: 1, Pattern: "goodbye"},
+	{Intent: Farewell, Weight: 1, Pattern: "good night"},
+	{Intent: Farewell, Weight: 1, Pattern: "see you"},
+	{Intent: Farewell, Weight: 1, Pattern: "see ya"},
+	{Intent: Farewell, Weight: 1, Pattern: "cya"},
+	{Intent: Farewell, Weight: 1, Pattern: "gtg"},
+	{Intent: Farewell, Weight: 1, Pattern: "got to go"},
+	{Intent: Farewell, Weight: 1, Pattern: "gotta go"},
+	{Intent: Farewell, Weight: 0.8, Pattern: "take care"},
+	{Intent: Farewell, Weight: 0.6, Pattern: "night"},
+	{Intent: Farewell, Weight: 0.4, Pattern: "later"},
+	{Intent: Help, Weight: 1.2, Pattern: "help"},
+	{Intent: Help, Weight: 2, Pattern: "what can you do"},
+	{Intent: Help, Weight: 1, Pattern: "commands"},
+	{Intent: Help, Weight: 0.6, Pattern: "how do i"},
+	{Intent: Question, Weight: 0.8, Pattern: `\?`, Regex: true},
+	{Intent: Question, Weight: 0.5, Pattern: "what"},
+	{Intent: Question, Weight: 0.5, Pattern: "where"},
+	{Intent: Question, Weight: 0.5, Pattern: "when"},
+	{Intent: Question, Weight: 0.5, Pattern: "who"},
+	{Intent: Question, Weight: 0.5, Pattern: "why"},
+	{Intent: Question, Weight: 0.5, Pattern: "how"},
+	{Intent: Question, Weight: 0.5, Pattern: "which"},
+	{Intent: Question, Weight: 0.5, Pattern: "is there"},
+	{Intent: Question, Weight: 0.5, Pattern: "are there"},
+	{Intent: Question, Weight: 0.4, Pattern: "do you"},
+	{Intent: Question, Weight: 0.4, Pattern: "does"},
+	{Intent: Command, Weight: 0.7, Pattern: "can you"},
+	{Intent: Command, Weight: 0.7, Pattern: "could you"},
+	{Intent: Command, Weight: 0.7, Pattern: "would you"},
+	{Intent: Command, Weight: 0.5, Pattern: "please"},
+	{Intent: Command, Weight: 0.8, Pattern: "show me"},
+	{Intent: Command, Weight: 0.8, Pattern: "take me"},
+	{Intent: Command, Weight: 0.8, Pattern: "give me"},
+	{Intent: Command, Weight: 0.6, Pattern: "tell me"},
+	{Intent: Command, Weight: 0.8, Pattern: "come here"},
+	{Intent: Command, Weight: 0.6, Pattern: "dance"},
+	{Intent: SmallTalk, Weight: 2, Pattern: "how are you"},
+	{Intent: SmallTalk, Weight: 2, Pattern: "how's it going"},
+	{Intent: SmallTalk, Weight: 1.2, Pattern: "what's up"},
+	{Intent: SmallTalk, Weight: 1.2, Pattern: "wassup"},
+	{Intent: SmallTalk, Weight: 1, Pattern: "thanks"},
+	{Intent: SmallTalk, Weight: 1, Pattern: "thank you"},
+	{Intent: SmallTalk, Weight: 0.8, Pattern: "lol"},
+	{Intent: SmallTalk, Weight: 0.8, Pattern: "haha"},
+	{Intent: SmallTalk, Weight: 0.6, Pattern: "nice"},
+	{Intent: SmallTalk, Weight: 0.6, Pattern: "cool"},
+	{Intent: SmallTalk, Weight: 0.6, Pattern: "bored"},
+	{Intent: SmallTalk, Weight: 0.6, Pattern: "weather"},
+}
+
+// Result is the intent of a message
+type Result struct {
+	Intent     string  `json:"intent"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source"`
+}
+
+// String formats a result for logs, e.g. "question (0.72)"
+func (r Result) String() string {
+	return fmt.Sprintf("%s (%.2f)", r.Intent, r.Confidence)
+}
+
+// Ask asks the model to classify a message, returning its answer
+type Ask func(ctx context.Context, text string) (string, error)
+
+// rule is a compiled intent rule
+type rule struct {
+	intent  string
+	weight  float64
+	pattern *regexp.Regexp
+}
+
+// Classifier works out what a message is trying to do
+type Classifier struct {
+	config config.IntentConfig
+	rules  []rule
+	ask    Ask
+}
+
+// NewClassifier compiles the built-in rules and those from the config.
+// Invalid rules are logged and skipped.
+func NewClassifier(cfg *config.Config) *Classifier {
+	c := &Classifier{config: cfg.Intent}
+
+	for _, r := range append(append([]config.IntentRule{}, defaultRules...), cfg.Intent.Rules...) {
+		if !known(r.Intent) {
+			log.Printf("Ignoring intent rule %q for unknown intent '%s'", r.Pattern, r.Intent)
+			continue
+		}
+
+		pattern := strings.TrimSpace(r.Pattern)
+		if !r.Regex {
+			// Match whole words only, so "hi" doesn't match "this"
+			pattern = `(?i)(?:^|[^\p{L}\p{N}])` + regexp.QuoteMeta(strings.ToLower(pattern)) + `(?:$|[^\p{L}\p{N}])`
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Ignoring invalid intent pattern %q: %v", r.Pattern, err)
+			continue
+		}
+
+		weight := r.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		c.rules = append(c.rules, rule{intent: r.Intent, weight: weight, pattern: compiled})
+	}
+
+	return c
+}
+
+// known reports whether an intent name is one the classifier returns
+func known(name string) bool {
+	for _, intent := range Intents {
+		if intent == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetAsk sets the function used to ask the model when the rules aren't sure
+func (c *Classifier) SetAsk(ask Ask) {
+	c.ask = ask
+}
+
+// Classify returns the intent of a message. The model is asked when it is
+// enabled and the rules are less confident than the configured minimum.
+func (c *Classifier) Classify(ctx context.Context, text string) Result {
+	result := c.classifyRules(text)
+	if !c.config.UseLLM || c.ask == nil || result.Confidence >= c.config.MinConfidence {
+		return result
+	}
+
+	answer, err := c.ask(ctx, text)
+	if err != nil {
+		log.Printf("Intent classification by the model failed: %v", err)
+		return result
+	}
+	if llmResult, ok := parseAnswer(answer); ok {
+		return llmResult
+	}
+	log.Printf("Could not understand intent classification %q", answer)
+	return result
+}
+
+// classifyRules scores a message against the weighted rules. Confidence
+// grows with the winning score and shrinks when other intents score too.
+func (c *Classifier) classifyRules(text string) Result {
+	scores := make(map[string]float64)
+	total := 0.0
+	for _, r := range c.rules {
+		if r.pattern.MatchString(text) {
+			scores[r.intent] += r.weight
+			total += r.weight
+		}
+	}
+
+	best, bestScore := General, 0.0
+	for _, intent := range Intents {
+		if scores[intent] > bestScore {
+			best, bestScore = intent, scores[intent]
+		}
+	}
+	if bestScore == 0 {
+		return Result{Intent: General, Source: SourceRules}
+	}
+
+	confidence := bestScore / total * (1 - math.Exp(-1.5*bestScore))
+	return Result{Intent: best, Confidence: math.Round(confidence*100) / 100, Source: SourceRules}
+}
+
+// parseAnswer reads an answer like "question 0.8" from the model
+func parseAnswer(answer string) (Result, bool) {
+	fields := strings.Fields(strings.ToLower(strings.NewReplacer(",", " ", ":", " ", "_", "", "-", "").Replace(answer)))
+	for i, field := range fields {
+		field = strings.Trim(field, ".\"'")
+		if field == "small" && i+1 < len(fields) && strings.HasPrefix(fields[i+1], "talk") {
+			field = SmallTalk
+		}
+		if !known(field) {
+			continue
+		}
+
+		result := Result{Intent: field, Confidence: 0.7, Source: SourceLLM}
+		for _, rest := range fields[i+1:] {
+			if value, err := strconv.ParseFloat(strings.Trim(rest, ".()"), 64); err == nil && value >= 0 && value <= 1 {
+				result.Confidence = value
+				break
+			}
+		}
+		return result, true
+	}
+	return Result{}, false
+}
@@ -0,0 +1,398 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/llm"
+	"slbot/internal/persistant"
+)
+
+// embedBatchSize is how many passages are sent to the embedding endpoint at once
+const embedBatchSize = 16
+
+// Chunk is an indexed passage of a document
+type Chunk struct {
+	File    string    `json:"file"`
+	Heading string    `json:"heading,omitempty"`
+	Text    string    `json:"text"`
+	Vector  []float64 `json:"vector"`
+}
+
+// FileInfo records the version of a document that was indexed
+type FileInfo struct {
+	ModTime time.Time `json:"modTime"`
+	Size    int64     `json:"size"`
+}
+
+// same reports whether two file versions match
+func (f FileInfo) same(other FileInfo) bool {
+	return f.Size == other.Size && f.ModTime.Equal(other.ModTime)
+}
+
+// Index is the file-backed vector index
+type Index struct {
+	Model     string              `json:"model"`
+	Files     map[string]FileInfo `json:"files"`
+	Chunks    []Chunk             `json:"chunks"`
+	IndexedAt time.Time           `json:"indexedAt"`
+}
+
+// Result is a passage matching a search
+type Result struct {
+	File    string  `json:"file"`
+	Heading string  `json:"heading,omitempty"`
+	Text    string  `json:"text"`
+	Score   float64 `json:"score"`
+}
+
+// Manager indexes a folder of documents and retrieves passages by similarity
+type Manager struct {
+	config   config.KnowledgeConfig
+	model    string
+	embedder llm.Embedder
+	index    Index
+	mutex    sync.RWMutex
+	indexing sync.Mutex
+}
+
+// NewManager creates a knowledge base manager and loads the saved index.
+// The embedder may be nil if the LLM provider cannot compute embeddings.
+func NewManager(cfg *config.Config, embedder llm.Embedder) *Manager {
+	model := cfg.Llama.EmbedModel
+	if model == "" {
+		model = cfg.Llama.Model
+	}
+
+	manager := &Manager{
+		config:   cfg.Knowledge,
+		model:    model,
+		embedder: embedder,
+		index:    Index{Files: make(map[string]FileInfo)},
+	}
+
+	if !manager.config.Enabled {
+		return manager
+	}
+
+	if embedder == nil {
+		log.Printf("Knowledge base disabled: the LLM provider cannot compute embeddings")
+		manager.config.Enabled = false
+		return manager
+	}
+
+	if err := persistant.LoadState(manager.config.Index, &manager.index); err != nil {
+		log.Printf("No knowledge index loaded from %s: %v", manager.config.Index, err)
+	} else if manager.index.Model != model {
+		log.Printf("Knowledge index was built with model %s, it will be rebuilt with %s", manager.index.Model, model)
+		manager.index = Index{Files: make(map[string]FileInfo)}
+	} else {
+		log.Printf("Loaded knowledge index with %d passages", len(manager.index.Chunks))
+	}
+	if manager.index.Files == nil {
+		manager.index.Files = make(map[string]FileInfo)
+	}
+
+	return manager
+}
+
+// IsEnabled returns whether the knowledge base is enabled
+func (m *Manager) IsEnabled() bool {
+	return m.config.Enabled
+}
+
+// Stats returns the number of indexed documents and passages and when the index was last built
+func (m *Manager) Stats() (int, int, time.Time) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.index.Files), len(m.index.Chunks), m.index.IndexedAt
+}
+
+// Reindex brings the index up to date with the document folder. Only new or
+// changed documents are embedded again. It returns the number of documents
+// that were (re)indexed.
+func (m *Manager) Reindex(ctx context.Context) (int, error) {
+	if !m.config.Enabled {
+		return 0, fmt.Errorf("knowledge base is disabled")
+	}
+
+	// Only one reindex at a time
+	m.indexing.Lock()
+	defer m.indexing.Unlock()
+
+	files, err := m.scan()
+	if err != nil {
+		return 0, err
+	}
+
+	m.mutex.RLock()
+	chunks := make([]Chunk, 0, len(m.index.Chunks))
+	for _, chunk := range m.index.Chunks {
+		if info, exists := files[chunk.File]; exists && info.same(m.index.Files[chunk.File]) {
+			chunks = append(chunks, chunk)
+		}
+	}
+	changed := make([]string, 0)
+	for name, info := range files {
+		if indexed, exists := m.index.Files[name]; !exists || !indexed.same(info) {
+			changed = append(changed, name)
+		}
+	}
+	removed := len(m.index.Files) - (len(files) - len(changed))
+	m.mutex.RUnlock()
+
+	sort.Strings(changed)
+	for _, name := range changed {
+		fileChunks, err := m.indexFile(ctx, name)
+		if err != nil {
+			return 0, fmt.Errorf("indexing %s: %v", name, err)
+		}
+		chunks = append(chunks, fileChunks...)
+	}
+
+	m.mutex.Lock()
+	m.index = Index{
+		Model:     m.model,
+		Files:     files,
+		Chunks:    chunks,
+		IndexedAt: time.Now(),
+	}
+	m.mutex.Unlock()
+
+	if len(changed) > 0 || removed > 0 {
+		m.save()
+	}
+
+	log.Printf("Knowledge base: %d documents, %d passages (%d reindexed)", len(files), len(chunks), len(changed))
+	return len(changed), nil
+}
+
+// scan lists the documents in the knowledge folder
+func (m *Manager) scan() (map[string]FileInfo, error) {
+	files := make(map[string]FileInfo)
+	err := filepath.WalkDir(m.config.Directory, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".md", ".markdown", ".txt":
+		default:
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(m.config.Directory, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = FileInfo{ModTime: info.ModTime().UTC(), Size: info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+// indexFile splits a document into passages and embeds them
+func (m *Manager) indexFile(ctx context.Context, name string) ([]Chunk, error) {
+	data, err := os.ReadFile(filepath.Join(m.config.Directory, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := splitDocument(name, string(data), m.config.ChunkChars)
+	for start := 0; start < len(chunks); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		texts := make([]string, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			texts = append(texts, chunk.embedText())
+		}
+
+		vectors, err := m.embedder.Embed(ctx, texts)
+		if err != nil {
+			return nil, err
+		}
+		for i, vector := range vectors {
+			chunks[start+i].Vector = normalize(vector)
+		}
+	}
+	return chunks, nil
+}
+
+// Search returns the passages most similar to a query
+func (m *Manager) Search(ctx context.Context, query string, limit int) ([]Result, error) {
+	if !m.config.Enabled {
+		return nil, fmt.Errorf("knowledge base is disabled")
+	}
+	if limit <= 0 {
+		limit = m.config.TopK
+	}
+
+	m.mutex.RLock()
+	empty := len(m.index.Chunks) == 0
+	m.mutex.RUnlock()
+	if empty {
+		return nil, nil
+	}
+
+	vectors, err := m.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	queryVector := normalize(vectors[0])
+
+	m.mutex.RLock()
+	results := make([]Result, 0)
+	for _, chunk := range m.index.Chunks {
+		score := dot(queryVector, chunk.Vector)
+		if score <= 0 || score < m.config.MinScore {
+			continue
+		}
+		results = append(results, Result{
+			File:    chunk.File,
+			Heading: chunk.Heading,
+			Text:    chunk.Text,
+			Score:   score,
+		})
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// PromptContext returns the passages relevant to a message, formatted for the prompt
+func (m *Manager) PromptContext(ctx context.Context, query string) string {
+	if !m.config.Enabled {
+		return ""
+	}
+
+	results, err := m.Search(ctx, query, m.config.TopK)
+	if err != nil {
+		log.Printf("Knowledge search failed: %v", err)
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, result := range results {
+		source := result.File
+		if result.Heading != "" {
+			source += " - " + result.Heading
+		}
+		sb.WriteString(fmt.Sprintf("[%s]\n%s\n\n", source, result.Text))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// save writes the index to disk
+func (m *Manager) save() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if err := persistant.SaveState(m.config.Index, &m.index); err != nil {
+		log.Printf("Failed to save knowledge index: %v", err)
+	}
+}
+
+// embedText is the text embedded for a passage, with its source for context
+func (c *Chunk) embedText() string {
+	if c.Heading != "" {
+		return c.Heading + "\n" + c.Text
+	}
+	return c.Text
+}
+
+// splitDocument splits a document into passages of about maxChars,
+// breaking at paragraphs and remembering the latest markdown heading
+func splitDocument(name, text string, maxChars int) []Chunk {
+	chunks := make([]Chunk, 0)
+	heading := ""
+	var current strings.Builder
+
+	flush := func() {
+		if passage := strings.TrimSpace(current.String()); passage != "" {
+			chunks = append(chunks, Chunk{File: name, Heading: heading, Text: passage})
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+
+		// A heading starts a new passage
+		if strings.HasPrefix(paragraph, "#") {
+			flush()
+			lines := strings.SplitN(paragraph, "\n", 2)
+			heading = strings.TrimSpace(strings.TrimLeft(lines[0], "#"))
+			if len(lines) == 1 {
+				continue
+			}
+			paragraph = strings.TrimSpace(lines[1])
+		}
+
+		if current.Len() > 0 && current.Len()+len(paragraph) > maxChars {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(paragraph)
+	}
+	flush()
+
+	return chunks
+}
+
+// normalize scales a vector to unit length so similarity is a dot product
+func normalize(vector []float64) []float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += v * v
+	}
+	if sum == 0 {
+		return vector
+	}
+
+	norm := math.Sqrt(sum)
+	result := make([]float64, len(vector))
+	for i, v := range vector {
+		result[i] = v / norm
+	}
+	return result
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
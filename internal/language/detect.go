@@ -0,0 +1,145 @@
+package language
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// names maps language codes to the names used in prompts and commands
+var names = map[string]string{
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"de": "German",
+	"it": "Italian",
+	"pt": "Portuguese",
+	"nl": "Dutch",
+	"tr": "Turkish",
+	"pl": "Polish",
+	"ru": "Russian",
+	"ja": "Japanese",
+	"zh": "Chinese",
+	"ko": "Korean",
+	"ar": "Arabic",
+	"el": "Greek",
+	"he": "Hebrew",
+	"th": "Thai",
+}
+
+// stopwords are common short words that give away a language written in Latin script
+var stopwords = map[string][]string{
+	"en": {"the", "and", "you", "is", "are", "what", "how", "where", "this", "that", "with", "have", "it", "my", "your", "can", "do", "i", "to", "of", "hello", "thanks"},
+	"es": {"el", "la", "los", "las", "que", "es", "y", "por", "para", "con", "como", "donde", "qué", "cómo", "dónde", "hola", "gracias", "tu", "yo", "un", "una", "muy", "está", "pero"},
+	"fr": {"le", "la", "les", "et", "est", "que", "qui", "je", "tu", "vous", "avec", "pour", "dans", "bonjour", "salut", "merci", "où", "comment", "une", "pas", "c'est", "oui"},
+	"de": {"der", "die", "das", "und", "ist", "ich", "du", "sie", "nicht", "mit", "wie", "wo", "was", "hallo", "danke", "ein", "eine", "bist", "auf", "für", "ja", "gibt", "geht", "dir", "mir", "gut", "wir", "ihr"},
+	"it": {"il", "lo", "la", "gli", "che", "è", "e", "sono", "ciao", "grazie", "come", "dove", "cosa", "per", "con", "non", "una", "sei", "anche", "questo"},
+	"pt": {"o", "a", "os", "as", "que", "é", "e", "não", "com", "para", "olá", "obrigado", "obrigada", "como", "onde", "você", "um", "uma", "tudo", "bem", "isso"},
+	"nl": {"de", "het", "een", "en", "is", "ik", "je", "jij", "niet", "met", "hoe", "waar", "wat", "hallo", "dank", "bedankt", "van", "op", "dat", "zijn"},
+	"tr": {"bir", "ve", "bu", "ne", "nasıl", "nerede", "merhaba", "teşekkürler", "sen", "ben", "mi", "mı", "var", "yok", "evet", "hayır", "çok"},
+	"pl": {"i", "jest", "nie", "się", "to", "jak", "gdzie", "co", "cześć", "dziękuję", "ty", "ja", "na", "czy", "tak", "dzień", "dobry"},
+}
+
+// Name returns the English name of a language code, or the code itself if it is unknown
+func Name(code string) string {
+	if name, exists := names[code]; exists {
+		return name
+	}
+	return code
+}
+
+// Languages returns the codes of the languages that can be detected, sorted
+func Languages() []string {
+	codes := make([]string, 0, len(names))
+	for code := range names {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Lookup finds the code for a language given its code or English name
+func Lookup(language string) (string, bool) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if _, exists := names[language]; exists {
+		return language, true
+	}
+	for code, name := range names {
+		if strings.ToLower(name) == language {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// Detect guesses the language of a message. Scripts other than Latin are
+// recognised from a single character; Latin-script languages need at least
+// two common words and a clear lead over the next best guess, so short
+// messages such as "hi" or "ok" are reported as not confident.
+func Detect(text string) (string, bool) {
+	if code := detectScript(text); code != "" {
+		return code, true
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make(map[string]int)
+	for _, word := range words {
+		for code, list := range stopwords {
+			for _, stopword := range list {
+				if word == stopword {
+					scores[code]++
+					break
+				}
+			}
+		}
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for code, score := range scores {
+		switch {
+		case score > bestScore:
+			best, secondScore, bestScore = code, bestScore, score
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+
+	if bestScore >= 2 && bestScore > secondScore {
+		return best, true
+	}
+	return best, false
+}
+
+// detectScript recognises languages from their writing system
+func detectScript(text string) string {
+	han := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			return "ja"
+		case unicode.Is(unicode.Hangul, r):
+			return "ko"
+		case unicode.Is(unicode.Cyrillic, r):
+			return "ru"
+		case unicode.Is(unicode.Arabic, r):
+			return "ar"
+		case unicode.Is(unicode.Greek, r):
+			return "el"
+		case unicode.Is(unicode.Hebrew, r):
+			return "he"
+		case unicode.Is(unicode.Thai, r):
+			return "th"
+		case unicode.Is(unicode.Han, r):
+			han = true
+		}
+	}
+
+	// Japanese also uses Han characters, so only decide once there's no kana
+	if han {
+		return "zh"
+	}
+	return ""
+}
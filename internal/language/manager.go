@@ -0,0 +1,156 @@
+package language
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+)
+
+// Preference is the language an avatar is spoken to in
+type Preference struct {
+	Avatar   string    `json:"avatar"`
+	Language string    `json:"language"`
+	Explicit bool      `json:"explicit"` // Chosen by the avatar rather than detected
+	Updated  time.Time `json:"updated"`
+}
+
+// Manager detects the language of messages and remembers each avatar's language
+type Manager struct {
+	config      config.LanguageConfig
+	preferences map[string]*Preference
+	mutex       sync.RWMutex
+}
+
+// NewManager creates a language manager and loads the saved preferences
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config:      cfg.Language,
+		preferences: make(map[string]*Preference),
+	}
+
+	if !manager.config.Enabled {
+		return manager
+	}
+
+	if err := persistant.LoadState(manager.config.Storage, &manager.preferences); err != nil {
+		log.Printf("No language preferences loaded from %s: %v", manager.config.Storage, err)
+	}
+	if manager.preferences == nil {
+		manager.preferences = make(map[string]*Preference)
+	}
+
+	return manager
+}
+
+// IsEnabled returns whether replies are given in the visitor's language
+func (m *Manager) IsEnabled() bool {
+	return m.config.Enabled
+}
+
+// Default returns the language used when nothing else is known
+func (m *Manager) Default() string {
+	return m.config.Default
+}
+
+// Resolve decides which language to answer a message in. A language the
+// avatar chose is always used; otherwise a confidently detected language
+// is used and remembered, falling back to the last one seen.
+func (m *Manager) Resolve(uuid, avatar, text string) string {
+	if !m.config.Enabled {
+		return m.config.Default
+	}
+
+	key := strings.ToLower(uuid)
+
+	m.mutex.Lock()
+	preference := m.preferences[key]
+	if preference != nil && preference.Explicit {
+		m.mutex.Unlock()
+		return preference.Language
+	}
+
+	detected, confident := Detect(text)
+	if !confident {
+		m.mutex.Unlock()
+		if preference != nil {
+			return preference.Language
+		}
+		return m.config.Default
+	}
+
+	changed := preference == nil || preference.Language != detected
+	if changed {
+		m.preferences[key] = &Preference{Avatar: avatar, Language: detected, Updated: time.Now()}
+	}
+	m.mutex.Unlock()
+
+	if changed {
+		m.save()
+	}
+	return detected
+}
+
+// Get returns the language remembered for an avatar, or the default
+func (m *Manager) Get(uuid string) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if preference, exists := m.preferences[strings.ToLower(uuid)]; exists {
+		return preference.Language
+	}
+	return m.config.Default
+}
+
+// Set records the language an avatar asked to be spoken to in
+func (m *Manager) Set(uuid, avatar, code string) {
+	m.mutex.Lock()
+	m.preferences[strings.ToLower(uuid)] = &Preference{
+		Avatar:   avatar,
+		Language: code,
+		Explicit: true,
+		Updated:  time.Now(),
+	}
+	m.mutex.Unlock()
+
+	m.save()
+}
+
+// Clear forgets an avatar's language so it is detected again
+func (m *Manager) Clear(uuid string) bool {
+	m.mutex.Lock()
+	key := strings.ToLower(uuid)
+	_, exists := m.preferences[key]
+	delete(m.preferences, key)
+	m.mutex.Unlock()
+
+	if exists {
+		m.save()
+	}
+	return exists
+}
+
+// Preferences returns a copy of the remembered languages keyed by avatar UUID
+func (m *Manager) Preferences() map[string]Preference {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	preferences := make(map[string]Preference, len(m.preferences))
+	for uuid, preference := range m.preferences {
+		preferences[uuid] = *preference
+	}
+	return preferences
+}
+
+// save writes the preferences to disk
+func (m *Manager) save() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if err := persistant.SaveState(m.config.Storage, m.preferences); err != nil {
+		log.Printf("Failed to save language preferences: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"slbot/internal/config"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is one message of a chat with the model
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`  // Tools the assistant wants to call
+	ToolCallID string     `json:"toolCallId,omitempty"` // The call a tool result answers
+	ToolName   string     `json:"toolName,omitempty"`   // The tool a tool result came from
+	Said       string     `json:"-"`                    // The avatar's own words when Content is a rendered prompt; never sent to models
+}
+
+// Tool describes a function the model may call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON schema of the arguments object
+}
+
+// ToolCall is a request from the model to call a tool
+type ToolCall struct {
+	ID        string                 `json:"id,omitempty"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// Provider is a chat model backend
+type Provider interface {
+	// Name identifies the provider and model, for logs and the dashboard
+	Name() string
+
+	// Chat sends messages to the model and returns its reply. If onDelta is
+	// set the reply is streamed and each new piece of text is passed to it.
+	Chat(ctx context.Context, messages []Message, onDelta func(string)) (string, error)
+
+	// TestConnection checks that the backend is reachable and usable
+	TestConnection(ctx context.Context) error
+}
+
+// ToolCaller is implemented by providers whose models can call tools
+type ToolCaller interface {
+	// ChatWithTools sends messages and tool descriptions to the model and
+	// returns its reply, which either answers or asks for tool calls
+	ChatWithTools(ctx context.Context, messages []Message, tools []Tool) (Message, error)
+}
+
+// Embedder is implemented by providers that can compute text embeddings
+type Embedder interface {
+	// Embed returns one embedding vector per input text
+	Embed(ctx context.Context, texts []string) ([][]float64, error)
+}
+
+// New creates the provider selected in the configuration
+func New(cfg config.LlamaConfig, httpClient *http.Client) (Provider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "ollama":
+		return NewOllama(cfg, httpClient), nil
+	case "openai":
+		return NewOpenAI(cfg, httpClient), nil
+	case "scripted":
+		return NewScripted(cfg.Script), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider '%s'", cfg.Provider)
+	}
+}
+
+// embedModel returns the model used for embeddings
+func embedModel(cfg config.LlamaConfig) string {
+	if cfg.EmbedModel != "" {
+		return cfg.EmbedModel
+	}
+	return cfg.Model
+}
+
+// lastSaid returns what the avatar said in the most recent user message,
+// or "" if it was a prompt of the bot's own such as moderation or a summary
+func lastSaid(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Said
+		}
+	}
+	return ""
+}
@@ -0,0 +1,328 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"slbot/internal/config"
+)
+
+// Ollama talks to an Ollama server through its /api/chat endpoint
+type Ollama struct {
+	config     config.LlamaConfig
+	httpClient *http.Client
+}
+
+// ollamaOptions holds model parameters for an Ollama chat request
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumCtx      int      `json:"num_ctx,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// ollamaMessage is a chat message as Ollama encodes it
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// ollamaToolCall is a tool call as Ollama encodes it
+type ollamaToolCall struct {
+	Function struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"function"`
+}
+
+// ollamaTool describes a tool to Ollama
+type ollamaTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+// ollamaRequest is the body of an Ollama chat request
+type ollamaRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Tools     []ollamaTool    `json:"tools,omitempty"`
+	Stream    bool            `json:"stream"`
+	Options   *ollamaOptions  `json:"options,omitempty"`
+	KeepAlive string          `json:"keep_alive,omitempty"`
+}
+
+// ollamaResponse is a response, or one streamed chunk, from the Ollama chat API
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// toOllamaMessages converts messages to Ollama's encoding
+func toOllamaMessages(messages []Message) []ollamaMessage {
+	result := make([]ollamaMessage, 0, len(messages))
+	for _, message := range messages {
+		m := ollamaMessage{Role: message.Role, Content: message.Content, ToolName: message.ToolName}
+		for _, call := range message.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = call.Arguments
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// NewOllama creates an Ollama provider
+func NewOllama(cfg config.LlamaConfig, httpClient *http.Client) *Ollama {
+	return &Ollama{config: cfg, httpClient: httpClient}
+}
+
+// Name returns the provider and model name
+func (o *Ollama) Name() string {
+	return "ollama/" + o.config.Model
+}
+
+// options returns the model options set in the configuration
+func (o *Ollama) options() *ollamaOptions {
+	cfg := o.config
+	if cfg.Temperature == nil && cfg.TopP == nil && cfg.NumCtx == 0 && cfg.NumPredict == 0 && len(cfg.Stop) == 0 {
+		return nil
+	}
+
+	return &ollamaOptions{
+		Temperature: cfg.Temperature,
+		TopP:        cfg.TopP,
+		NumCtx:      cfg.NumCtx,
+		NumPredict:  cfg.NumPredict,
+		Stop:        cfg.Stop,
+	}
+}
+
+// post sends a chat request to Ollama and returns the open response
+func (o *Ollama) post(ctx context.Context, req ollamaRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.config.URL+"/api/chat", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("ollama returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// complete sends a non-streamed chat request and returns the reply message
+func (o *Ollama) complete(ctx context.Context, req ollamaRequest) (ollamaMessage, error) {
+	resp, err := o.post(ctx, req)
+	if err != nil {
+		return ollamaMessage{}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return ollamaMessage{}, err
+	}
+	if chatResp.Error != "" {
+		return ollamaMessage{}, fmt.Errorf("ollama error: %s", chatResp.Error)
+	}
+	return chatResp.Message, nil
+}
+
+// Chat sends messages to Ollama and returns the reply
+func (o *Ollama) Chat(ctx context.Context, messages []Message, onDelta func(string)) (string, error) {
+	req := ollamaRequest{
+		Model:     o.config.Model,
+		Messages:  toOllamaMessages(messages),
+		Stream:    onDelta != nil,
+		Options:   o.options(),
+		KeepAlive: o.config.KeepAlive,
+	}
+
+	if onDelta == nil {
+		reply, err := o.complete(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(reply.Content), nil
+	}
+
+	resp, err := o.post(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Streamed responses are one JSON object per line
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return full.String(), err
+		}
+		if chunk.Error != "" {
+			return full.String(), fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		full.WriteString(chunk.Message.Content)
+		onDelta(chunk.Message.Content)
+		if chunk.Done {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), err
+	}
+
+	return strings.TrimSpace(full.String()), nil
+}
+
+// ChatWithTools sends messages and tools to Ollama and returns the reply,
+// which may ask for tool calls
+func (o *Ollama) ChatWithTools(ctx context.Context, messages []Message, tools []Tool) (Message, error) {
+	req := ollamaRequest{
+		Model:     o.config.Model,
+		Messages:  toOllamaMessages(messages),
+		Stream:    false,
+		Options:   o.options(),
+		KeepAlive: o.config.KeepAlive,
+	}
+	for _, tool := range tools {
+		t := ollamaTool{Type: "function"}
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		req.Tools = append(req.Tools, t)
+	}
+
+	reply, err := o.complete(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	message := Message{Role: RoleAssistant, Content: strings.TrimSpace(reply.Content)}
+	for _, call := range reply.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return message, nil
+}
+
+// Embed computes embeddings with Ollama's /api/embed endpoint
+func (o *Ollama) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	req := map[string]interface{}{
+		"model": embedModel(o.config),
+		"input": texts,
+	}
+	if o.config.KeepAlive != "" {
+		req["keep_alive"] = o.config.KeepAlive
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", o.config.URL+"/api/embed", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("ollama returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var embedResp struct {
+		Embeddings [][]float64 `json:"embeddings"`
+		Error      string      `json:"error,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, err
+	}
+	if embedResp.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", embedResp.Error)
+	}
+	if len(embedResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(embedResp.Embeddings), len(texts))
+	}
+	return embedResp.Embeddings, nil
+}
+
+// TestConnection checks that Ollama is running and has the configured model
+func (o *Ollama) TestConnection(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", o.config.URL+"/api/tags", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama returned %s", resp.Status)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return err
+	}
+
+	for _, model := range tags.Models {
+		if model.Name == o.config.Model || strings.TrimSuffix(model.Name, ":latest") == o.config.Model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model '%s' is not available in ollama", o.config.Model)
+}
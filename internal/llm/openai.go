@@ -0,0 +1,329 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"slbot/internal/config"
+)
+
+// OpenAI talks to any server implementing the OpenAI chat completions API,
+// such as llama.cpp server, vLLM or LocalAI. The configured URL is the API
+// base, e.g. http://localhost:8080/v1.
+type OpenAI struct {
+	config     config.LlamaConfig
+	httpClient *http.Client
+}
+
+// openAIMessage is a chat message as the OpenAI API encodes it
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+// openAIToolCall is a tool call as the OpenAI API encodes it
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"` // JSON-encoded arguments object
+	} `json:"function"`
+}
+
+// openAITool describes a tool to the OpenAI API
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+// openAIRequest is the body of a chat completions request
+type openAIRequest struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Tools       []openAITool    `json:"tools,omitempty"`
+	Stream      bool            `json:"stream"`
+	Temperature *float64        `json:"temperature,omitempty"`
+	TopP        *float64        `json:"top_p,omitempty"`
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Stop        []string        `json:"stop,omitempty"`
+}
+
+// openAIResponse is a chat completion, or one streamed chunk of it
+type openAIResponse struct {
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+		Delta   openAIMessage `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// toOpenAIMessages converts messages to the OpenAI encoding
+func toOpenAIMessages(messages []Message) []openAIMessage {
+	result := make([]openAIMessage, 0, len(messages))
+	for _, message := range messages {
+		m := openAIMessage{Role: message.Role, Content: message.Content, ToolCallID: message.ToolCallID}
+		for _, call := range message.ToolCalls {
+			tc := openAIToolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Name
+			arguments, _ := json.Marshal(call.Arguments)
+			tc.Function.Arguments = string(arguments)
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// NewOpenAI creates an OpenAI-compatible provider
+func NewOpenAI(cfg config.LlamaConfig, httpClient *http.Client) *OpenAI {
+	return &OpenAI{config: cfg, httpClient: httpClient}
+}
+
+// Name returns the provider and model name
+func (o *OpenAI) Name() string {
+	return "openai/" + o.config.Model
+}
+
+// newRequest creates a request to the API with authentication set
+func (o *OpenAI) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(o.config.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.config.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.config.APIKey)
+	}
+	return httpReq, nil
+}
+
+// request returns a chat completions request with the configured options
+func (o *OpenAI) request(messages []Message, stream bool) openAIRequest {
+	return openAIRequest{
+		Model:       o.config.Model,
+		Messages:    toOpenAIMessages(messages),
+		Stream:      stream,
+		Temperature: o.config.Temperature,
+		TopP:        o.config.TopP,
+		MaxTokens:   o.config.NumPredict,
+		Stop:        o.config.Stop,
+	}
+}
+
+// post sends a chat completions request and returns the open response
+func (o *OpenAI) post(ctx context.Context, req openAIRequest) (*http.Response, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := o.newRequest(ctx, "POST", "/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("openai endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// complete sends a non-streamed request and returns the reply message
+func (o *OpenAI) complete(ctx context.Context, req openAIRequest) (openAIMessage, error) {
+	resp, err := o.post(ctx, req)
+	if err != nil {
+		return openAIMessage{}, err
+	}
+	defer resp.Body.Close()
+
+	var chatResp openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return openAIMessage{}, err
+	}
+	if chatResp.Error != nil {
+		return openAIMessage{}, fmt.Errorf("openai endpoint error: %s", chatResp.Error.Message)
+	}
+	if len(chatResp.Choices) == 0 {
+		return openAIMessage{}, fmt.Errorf("openai endpoint returned no choices")
+	}
+	return chatResp.Choices[0].Message, nil
+}
+
+// Chat sends messages to the chat completions endpoint and returns the reply
+func (o *OpenAI) Chat(ctx context.Context, messages []Message, onDelta func(string)) (string, error) {
+	req := o.request(messages, onDelta != nil)
+
+	if onDelta == nil {
+		reply, err := o.complete(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(reply.Content), nil
+	}
+
+	resp, err := o.post(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Streamed responses are server-sent events ending with "data: [DONE]"
+	var full strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return full.String(), err
+		}
+		if chunk.Error != nil {
+			return full.String(), fmt.Errorf("openai endpoint error: %s", chunk.Error.Message)
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		full.WriteString(delta)
+		onDelta(delta)
+	}
+	if err := scanner.Err(); err != nil {
+		return full.String(), err
+	}
+
+	return strings.TrimSpace(full.String()), nil
+}
+
+// ChatWithTools sends messages and tools to the endpoint and returns the
+// reply, which may ask for tool calls
+func (o *OpenAI) ChatWithTools(ctx context.Context, messages []Message, tools []Tool) (Message, error) {
+	req := o.request(messages, false)
+	for _, tool := range tools {
+		t := openAITool{Type: "function"}
+		t.Function.Name = tool.Name
+		t.Function.Description = tool.Description
+		t.Function.Parameters = tool.Parameters
+		req.Tools = append(req.Tools, t)
+	}
+
+	reply, err := o.complete(ctx, req)
+	if err != nil {
+		return Message{}, err
+	}
+
+	message := Message{Role: RoleAssistant, Content: strings.TrimSpace(reply.Content)}
+	for _, call := range reply.ToolCalls {
+		arguments := make(map[string]interface{})
+		if call.Function.Arguments != "" {
+			if err := json.Unmarshal([]byte(call.Function.Arguments), &arguments); err != nil {
+				return Message{}, fmt.Errorf("invalid arguments for tool %s: %v", call.Function.Name, err)
+			}
+		}
+		message.ToolCalls = append(message.ToolCalls, ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: arguments,
+		})
+	}
+	return message, nil
+}
+
+// Embed computes embeddings with the /embeddings endpoint
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	req := map[string]interface{}{
+		"model": embedModel(o.config),
+		"input": texts,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := o.newRequest(ctx, "POST", "/embeddings", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("openai endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var embedResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return nil, err
+	}
+	if len(embedResp.Data) != len(texts) {
+		return nil, fmt.Errorf("openai endpoint returned %d embeddings for %d texts", len(embedResp.Data), len(texts))
+	}
+
+	vectors := make([][]float64, len(texts))
+	for _, item := range embedResp.Data {
+		if item.Index >= 0 && item.Index < len(vectors) {
+			vectors[item.Index] = item.Embedding
+		}
+	}
+	return vectors, nil
+}
+
+// TestConnection checks that the endpoint answers its model listing
+func (o *OpenAI) TestConnection(ctx context.Context) error {
+	httpReq, err := o.newRequest(ctx, "GET", "/models", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := o.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("openai endpoint returned %s", resp.Status)
+	}
+	return nil
+}
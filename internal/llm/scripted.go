@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"slbot/internal/config"
+)
+
+// Scripted answers from a fixed script instead of a model, so the bot can be
+// exercised without a model server and always gives the same replies
+type Scripted struct {
+	replies  []config.ScriptedReply
+	fallback []string
+	next     int
+	mutex    sync.Mutex
+}
+
+// NewScripted creates a scripted provider. Replies with a match are used when
+// what the avatar said contains the match text; the others are used in turn.
+func NewScripted(replies []config.ScriptedReply) *Scripted {
+	s := &Scripted{}
+	for _, reply := range replies {
+		if reply.Match == "" {
+			s.fallback = append(s.fallback, strings.TrimSpace(reply.Text))
+		} else {
+			s.replies = append(s.replies, reply)
+		}
+	}
+	return s
+}
+
+// Name returns the provider name
+func (s *Scripted) Name() string {
+	return "scripted"
+}
+
+// Chat returns the scripted reply to what the avatar last said
+func (s *Scripted) Chat(ctx context.Context, messages []Message, onDelta func(string)) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	reply := s.reply(strings.ToLower(lastSaid(messages)))
+	if onDelta != nil {
+		for _, word := range strings.SplitAfter(reply, " ") {
+			onDelta(word)
+		}
+	}
+	return reply, nil
+}
+
+// reply picks the reply for a message
+func (s *Scripted) reply(message string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, reply := range s.replies {
+		if message != "" && strings.Contains(message, strings.ToLower(reply.Match)) {
+			return strings.TrimSpace(reply.Text)
+		}
+	}
+
+	if len(s.fallback) == 0 {
+		return "I have nothing scripted for that."
+	}
+	reply := s.fallback[s.next%len(s.fallback)]
+	s.next++
+	return reply
+}
+
+// ChatWithTools returns a scripted tool call for what the avatar last said, or
+// the scripted reply once the tool result has come back
+func (s *Scripted) ChatWithTools(ctx context.Context, messages []Message, tools []Tool) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+
+	if len(messages) > 0 && messages[len(messages)-1].Role == RoleTool {
+		result := messages[len(messages)-1]
+		for _, reply := range s.replies {
+			if reply.Tool == result.ToolName {
+				return Message{Role: RoleAssistant, Content: strings.TrimSpace(reply.Text)}, nil
+			}
+		}
+		return Message{Role: RoleAssistant, Content: result.Content}, nil
+	}
+
+	message := strings.ToLower(lastSaid(messages))
+	for _, reply := range s.replies {
+		if reply.Tool == "" || message == "" || !strings.Contains(message, strings.ToLower(reply.Match)) {
+			continue
+		}
+
+		arguments := make(map[string]interface{})
+		if reply.Args != "" {
+			if err := json.Unmarshal([]byte(reply.Args), &arguments); err != nil {
+				return Message{}, fmt.Errorf("invalid scripted arguments for tool %s: %v", reply.Tool, err)
+			}
+		}
+		return Message{
+			Role:      RoleAssistant,
+			ToolCalls: []ToolCall{{ID: "call-" + reply.Tool, Name: reply.Tool, Arguments: arguments}},
+		}, nil
+	}
+
+	return Message{Role: RoleAssistant, Content: s.reply(message)}, nil
+}
+
+// scriptedDimensions is the size of the scripted provider's embeddings
+const scriptedDimensions = 256
+
+// Embed returns word-hashing vectors, so texts sharing words are similar
+func (s *Scripted) Embed(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, len(texts))
+	for i, text := range texts {
+		vector := make([]float64, scriptedDimensions)
+		for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%scriptedDimensions]++
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// TestConnection always succeeds
+func (s *Scripted) TestConnection(ctx context.Context) error {
+	return nil
+}
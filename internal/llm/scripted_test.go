@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"slbot/internal/config"
+)
+
+func scriptedForTest() *Scripted {
+	return NewScripted([]config.ScriptedReply{
+		{Match: "hello", Text: " Hi there! "},
+		{Match: "weather", Tool: "get_weather", Args: `{"region":"Ahern"}`, Text: "It's sunny."},
+		{Text: "first fallback"},
+		{Text: "second fallback"},
+	})
+}
+
+func TestScriptedChat(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		want     []string // Replies to the same messages asked in turn
+	}{
+		{
+			name:     "matches what the avatar said",
+			messages: []Message{{Role: RoleUser, Content: "You are a helpful bot. Reply to: HELLO bot", Said: "HELLO bot"}},
+			want:     []string{"Hi there!", "Hi there!"},
+		},
+		{
+			name:     "ignores the rendered prompt",
+			messages: []Message{{Role: RoleUser, Content: "Say hello to the avatar", Said: "how are you"}},
+			want:     []string{"first fallback", "second fallback", "first fallback"},
+		},
+		{
+			name:     "bot prompts without the avatar's words use the fallback",
+			messages: []Message{{Role: RoleUser, Content: "Is this message hello safe? Answer yes or no."}},
+			want:     []string{"first fallback"},
+		},
+		{
+			name: "uses the most recent user message",
+			messages: []Message{
+				{Role: RoleUser, Content: "hello", Said: "hello"},
+				{Role: RoleAssistant, Content: "Hi there!"},
+				{Role: RoleUser, Content: "bye", Said: "bye"},
+			},
+			want: []string{"first fallback"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := scriptedForTest()
+			for i, want := range test.want {
+				got, err := s.Chat(context.Background(), test.messages, nil)
+				if err != nil {
+					t.Fatalf("Chat returned an error: %v", err)
+				}
+				if got != want {
+					t.Errorf("reply %d = %q, want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScriptedReplyWithoutFallback(t *testing.T) {
+	s := NewScripted([]config.ScriptedReply{{Match: "hello", Text: "Hi"}})
+	if got := s.reply("goodbye"); got != "I have nothing scripted for that." {
+		t.Errorf("reply = %q", got)
+	}
+	if got := s.reply(""); got != "I have nothing scripted for that." {
+		t.Errorf("reply to nothing = %q", got)
+	}
+}
+
+func TestScriptedChatWithTools(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		tool     string
+		args     map[string]interface{}
+		content  string
+	}{
+		{
+			name:     "calls the matching tool",
+			messages: []Message{{Role: RoleUser, Content: "Reply to: what's the weather?", Said: "What's the Weather?"}},
+			tool:     "get_weather",
+			args:     map[string]interface{}{"region": "Ahern"},
+		},
+		{
+			name:     "doesn't call tools for words only in the prompt",
+			messages: []Message{{Role: RoleUser, Content: "Mention the weather if asked", Said: "hello"}},
+			content:  "Hi there!",
+		},
+		{
+			name: "replies once the tool result is back",
+			messages: []Message{
+				{Role: RoleUser, Content: "weather?", Said: "weather?"},
+				{Role: RoleAssistant, ToolCalls: []ToolCall{{ID: "call-get_weather", Name: "get_weather"}}},
+				{Role: RoleTool, Content: "sunny", ToolCallID: "call-get_weather", ToolName: "get_weather"},
+			},
+			content: "It's sunny.",
+		},
+		{
+			name: "passes unscripted tool results through",
+			messages: []Message{
+				{Role: RoleUser, Content: "time?", Said: "time?"},
+				{Role: RoleTool, Content: "12:00 SLT", ToolCallID: "call-get_time", ToolName: "get_time"},
+			},
+			content: "12:00 SLT",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reply, err := scriptedForTest().ChatWithTools(context.Background(), test.messages, nil)
+			if err != nil {
+				t.Fatalf("ChatWithTools returned an error: %v", err)
+			}
+			if reply.Role != RoleAssistant {
+				t.Errorf("role = %q, want %q", reply.Role, RoleAssistant)
+			}
+			if test.tool == "" {
+				if len(reply.ToolCalls) != 0 {
+					t.Errorf("unexpected tool calls %v", reply.ToolCalls)
+				}
+				if reply.Content != test.content {
+					t.Errorf("content = %q, want %q", reply.Content, test.content)
+				}
+				return
+			}
+
+			if len(reply.ToolCalls) != 1 {
+				t.Fatalf("got %d tool calls, want 1", len(reply.ToolCalls))
+			}
+			call := reply.ToolCalls[0]
+			if call.Name != test.tool {
+				t.Errorf("tool = %q, want %q", call.Name, test.tool)
+			}
+			for key, want := range test.args {
+				if call.Arguments[key] != want {
+					t.Errorf("argument %s = %v, want %v", key, call.Arguments[key], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package lsl
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+	"slbot/internal/signature"
+)
+
+// Headers of a signed request. Second Life adds X-SecondLife-Object-Key to
+// every llHTTPRequest; the script adds the timestamp and signature.
+const (
+	HeaderObjectKey = "X-SecondLife-Object-Key"
+	HeaderTimestamp = "X-Timestamp"
+	HeaderSignature = "X-Signature"
+)
+
+// Object is an in-world object trusted to send events
+type Object struct {
+	UUID         string    `json:"uuid"`
+	Name         string    `json:"name"`
+	Secret       string    `json:"secret,omitempty"`
+	Events       []string  `json:"events,omitempty"` // Events it may send; empty for any
+	FromConfig   bool      `json:"fromConfig"`
+	RegisteredBy string    `json:"registeredBy,omitempty"`
+	LastEvent    time.Time `json:"lastEvent"`
+}
+
+// allows reports whether the object may send an event
+func (o *Object) allows(event string) bool {
+	if len(o.Events) == 0 {
+		return true
+	}
+	for _, allowed := range o.Events {
+		if strings.EqualFold(allowed, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds the objects trusted to send events
+type Registry struct {
+	config  config.LSLConfig
+	objects map[string]*Object
+	guard   *signature.Guard
+	mutex   sync.RWMutex
+}
+
+// NewRegistry creates a registry from the configured objects and those
+// registered at runtime
+func NewRegistry(cfg *config.Config) *Registry {
+	registry := &Registry{
+		config:  cfg.LSL,
+		objects: make(map[string]*Object),
+		guard:   signature.NewGuard(time.Duration(cfg.LSL.MaxSkew) * time.Second),
+	}
+
+	registered := make(map[string]*Object)
+	if err := persistant.LoadState(cfg.LSL.Storage, &registered); err != nil && cfg.LSL.Enabled {
+		log.Printf("No registered LSL objects loaded from %s: %v", cfg.LSL.Storage, err)
+	}
+	for uuid, object := range registered {
+		registry.objects[strings.ToLower(uuid)] = object
+	}
+
+	for _, object := range cfg.LSL.Objects {
+		var events []string
+		for _, event := range strings.Split(object.Events, ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				events = append(events, event)
+			}
+		}
+		registry.objects[strings.ToLower(object.UUID)] = &Object{
+			UUID:       strings.ToLower(object.UUID),
+			Name:       strings.TrimSpace(object.Name),
+			Secret:     object.Secret,
+			Events:     events,
+			FromConfig: true,
+		}
+	}
+
+	return registry
+}
+
+// IsEnabled returns whether scripts may send events
+func (r *Registry) IsEnabled() bool {
+	return r.config.Enabled
+}
+
+// Authenticate checks a signed event request. The signature is the
+// HMAC-SHA256 of the event name, the timestamp and the body, each but the
+// last followed by a newline, using the object's secret. Signing the name
+// stops a captured request being replayed to another event.
+func (r *Registry) Authenticate(objectKey, event, timestamp, sig string, body []byte) (*Object, error) {
+	r.mutex.RLock()
+	object, exists := r.objects[strings.ToLower(strings.TrimSpace(objectKey))]
+	r.mutex.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("object %s is not trusted", objectKey)
+	}
+	if !object.allows(event) {
+		return nil, fmt.Errorf("object %s may not send %s", object.Name, event)
+	}
+	if sig == "" || !signature.Check(object.Secret, event+"\n"+timestamp+"\n"+string(body), sig) {
+		return nil, fmt.Errorf("bad signature")
+	}
+	if err := r.guard.Check(timestamp, sig, time.Now()); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	object.LastEvent = time.Now()
+	found := *object
+	r.mutex.Unlock()
+
+	return &found, nil
+}
+
+// Register trusts an object, replacing an earlier registration
+func (r *Registry) Register(object Object, registeredBy string) error {
+	object.UUID = strings.ToLower(strings.TrimSpace(object.UUID))
+	if object.UUID == "" || object.Secret == "" {
+		return fmt.Errorf("an object needs a UUID and a secret")
+	}
+
+	r.mutex.Lock()
+	if existing, exists := r.objects[object.UUID]; exists && existing.FromConfig {
+		r.mutex.Unlock()
+		return fmt.Errorf("object %s is set in the configuration file", object.UUID)
+	}
+	object.FromConfig = false
+	object.RegisteredBy = registeredBy
+	r.objects[object.UUID] = &object
+	r.mutex.Unlock()
+
+	log.Printf("%s registered LSL object %s (%s)", registeredBy, object.Name, object.UUID)
+	return r.save()
+}
+
+// Remove stops trusting an object registered at runtime
+func (r *Registry) Remove(uuid, removedBy string) error {
+	uuid = strings.ToLower(strings.TrimSpace(uuid))
+
+	r.mutex.Lock()
+	object, exists := r.objects[uuid]
+	switch {
+	case !exists:
+		r.mutex.Unlock()
+		return fmt.Errorf("object %s is not registered", uuid)
+	case object.FromConfig:
+		r.mutex.Unlock()
+		return fmt.Errorf("object %s is set in the configuration file", uuid)
+	}
+	delete(r.objects, uuid)
+	r.mutex.Unlock()
+
+	log.Printf("%s removed LSL object %s (%s)", removedBy, object.Name, uuid)
+	return r.save()
+}
+
+// Objects returns the trusted objects without their secrets, sorted by name
+func (r *Registry) Objects() []Object {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	objects := make([]Object, 0, len(r.objects))
+	for _, object := range r.objects {
+		listed := *object
+		listed.Secret = ""
+		objects = append(objects, listed)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Name < objects[j].Name })
+	return objects
+}
+
+// save writes the objects registered at runtime to disk
+func (r *Registry) save() error {
+	r.mutex.RLock()
+	registered := make(map[string]*Object)
+	for uuid, object := range r.objects {
+		if !object.FromConfig {
+			registered[uuid] = object
+		}
+	}
+	r.mutex.RUnlock()
+
+	return persistant.SaveState(r.config.Storage, registered)
+}
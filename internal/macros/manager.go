@@ -27,9 +27,13 @@ type Manager struct {
 	macros        map[string]*types.Macro
 	recording     *types.MacroRecording
 	isPlaying     bool
+	onFinished    FinishedFunc
 	mutex         sync.RWMutex
 }
 
+// FinishedFunc is told when a macro has finished playing
+type FinishedFunc func(name, requestedBy string, elapsed time.Duration)
+
 // NewManager creates a new macro manager
 func NewManager(cfg *config.Config, corradeClient *corrade.Client) *Manager {
 	manager := &Manager{
@@ -53,25 +57,17 @@ func NewManager(cfg *config.Config, corradeClient *corrade.Client) *Manager {
 	return manager
 }
 
-// IsOwner checks if the given username is an owner
-func (m *Manager) IsOwner(username string) bool {
-	for _, owner := range m.config.Bot.Owners {
-		if strings.EqualFold(owner, username) {
-			return true
-		}
-	}
-	return false
+// SetOnFinished sets the function told when a macro finishes playing
+func (m *Manager) SetOnFinished(finished FinishedFunc) {
+	m.onFinished = finished
 }
 
-// StartRecording begins recording a new macro
+// StartRecording begins recording a new macro. Callers are responsible for
+// checking that recordedBy holds the role needed to record macros.
 func (m *Manager) StartRecording(name, recordedBy string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if !m.IsOwner(recordedBy) {
-		return fmt.Errorf("access denied: %s is not an owner", recordedBy)
-	}
-
 	if m.recording != nil && m.recording.IsRecording {
 		return fmt.Errorf("already recording macro: %s", m.recording.Name)
 	}
@@ -186,11 +182,6 @@ func (m *Manager) RecordAction(actionType string, data map[string]interface{}) {
 func (m *Manager) PlayMacro(name, requestedBy string) error {
 	m.mutex.Lock()
 
-	if !m.IsOwner(requestedBy) && requestedBy != "AutoGreet" {
-		m.mutex.Unlock()
-		return fmt.Errorf("access denied: %s is not an owner", requestedBy)
-	}
-
 	if m.isPlaying {
 		m.mutex.Unlock()
 		return fmt.Errorf("already playing a macro")
@@ -243,6 +234,9 @@ func (m *Manager) PlayMacro(name, requestedBy string) error {
 		}
 
 		log.Printf("Completed macro '%s' in %v", name, time.Since(startTime))
+		if m.onFinished != nil {
+			m.onFinished(name, requestedBy, time.Since(startTime))
+		}
 	}()
 
 	return nil
@@ -354,10 +348,6 @@ func (m *Manager) SetIdleBehavior(name, requestedBy string, isIdleBehavior bool)
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if !m.IsOwner(requestedBy) {
-		return fmt.Errorf("access denied: %s is not an owner", requestedBy)
-	}
-
 	macro, exists := m.macros[name]
 	if !exists {
 		return fmt.Errorf("macro '%s' not found", name)
@@ -384,10 +374,6 @@ func (m *Manager) SetAutoGreet(name, requestedBy string, isAutoGreet bool) error
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if !m.IsOwner(requestedBy) {
-		return fmt.Errorf("access denied: %s is not an owner", requestedBy)
-	}
-
 	macro, exists := m.macros[name]
 	if !exists {
 		return fmt.Errorf("macro '%s' not found", name)
@@ -524,6 +510,9 @@ func (m *Manager) PlayAutoGreetMacro(macroName, avatarName string) error {
 		}
 
 		log.Printf("Completed auto-greet macro '%s' for %s in %v", macroName, avatarName, time.Since(startTime))
+		if m.onFinished != nil {
+			m.onFinished(macroName, "autogreet:"+avatarName, time.Since(startTime))
+		}
 	}()
 
 	return nil
@@ -543,10 +532,6 @@ func (m *Manager) DeleteMacro(name, requestedBy string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if !m.IsOwner(requestedBy) {
-		return fmt.Errorf("access denied: %s is not an owner", requestedBy)
-	}
-
 	if _, exists := m.macros[name]; !exists {
 		return fmt.Errorf("macro '%s' not found", name)
 	}
@@ -0,0 +1,216 @@
+package mailbox
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+)
+
+// Message is a message left for an avatar who isn't around
+type Message struct {
+	ID       string    `json:"id"`
+	From     string    `json:"from"`
+	FromUUID string    `json:"fromUuid"`
+	To       string    `json:"to"`               // Name as the sender gave it, or the full name if known
+	ToUUID   string    `json:"toUuid,omitempty"` // Known when the recipient was nearby or given by UUID
+	Text     string    `json:"text"`
+	Left     time.Time `json:"left"`
+}
+
+// state is what is saved across restarts
+type state struct {
+	NextID   int                 `json:"nextId"`
+	Messages map[string]*Message `json:"messages"`
+}
+
+// Manager keeps messages until their recipients turn up
+type Manager struct {
+	config config.MessagesConfig
+	state  state
+	mutex  sync.RWMutex
+}
+
+// NewManager creates a mailbox and loads the pending messages
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config: cfg.Messages,
+		state:  state{NextID: 1, Messages: make(map[string]*Message)},
+	}
+
+	if err := persistant.LoadState(cfg.Messages.Storage, &manager.state); err != nil {
+		log.Printf("No messages loaded from %s: %v", cfg.Messages.Storage, err)
+	}
+	if manager.state.Messages == nil {
+		manager.state.Messages = make(map[string]*Message)
+	}
+
+	return manager
+}
+
+// Leave stores a message for an avatar
+func (m *Manager) Leave(from, fromUUID, to, toUUID, text string) (*Message, error) {
+	to = strings.TrimSpace(to)
+	text = strings.TrimSpace(text)
+	if to == "" || text == "" {
+		return nil, fmt.Errorf("tell me who the message is for and what to say")
+	}
+	if fromUUID == "" {
+		return nil, fmt.Errorf("only avatars can leave messages")
+	}
+
+	m.mutex.Lock()
+	m.expire(time.Now())
+	if len(m.from(fromUUID)) >= m.config.MaxPerSender {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("you already have %d messages waiting; cancel one first", m.config.MaxPerSender)
+	}
+	message := &Message{
+		ID:       strconv.Itoa(m.state.NextID),
+		From:     from,
+		FromUUID: strings.ToLower(fromUUID),
+		To:       to,
+		ToUUID:   strings.ToLower(toUUID),
+		Text:     text,
+		Left:     time.Now(),
+	}
+	m.state.NextID++
+	m.state.Messages[message.ID] = message
+	left := *message
+	m.mutex.Unlock()
+
+	return &left, m.save()
+}
+
+// Take removes and returns the messages for an avatar, oldest first
+func (m *Manager) Take(name, uuid string) []*Message {
+	m.mutex.Lock()
+	m.expire(time.Now())
+	var taken []*Message
+	for id, message := range m.state.Messages {
+		if isFor(message, name, uuid) {
+			taken = append(taken, message)
+			delete(m.state.Messages, id)
+		}
+	}
+	m.mutex.Unlock()
+
+	if len(taken) == 0 {
+		return nil
+	}
+	if err := m.save(); err != nil {
+		log.Printf("Failed to save messages: %v", err)
+	}
+	sortByLeft(taken)
+	return taken
+}
+
+// Return puts back a message that couldn't be delivered
+func (m *Manager) Return(message *Message) {
+	m.mutex.Lock()
+	m.state.Messages[message.ID] = message
+	m.mutex.Unlock()
+
+	if err := m.save(); err != nil {
+		log.Printf("Failed to save messages: %v", err)
+	}
+}
+
+// From returns the messages an avatar has left that are still waiting, oldest first
+func (m *Manager) From(uuid string) []*Message {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	messages := m.from(uuid)
+	sortByLeft(messages)
+	return messages
+}
+
+// Cancel removes a waiting message left by an avatar, found by its ID or
+// the recipient's name, and returns it
+func (m *Manager) Cancel(uuid, query string) (*Message, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	m.mutex.Lock()
+	var cancelled *Message
+	for _, message := range m.from(uuid) {
+		if message.ID == query || strings.ToLower(message.To) == query || strings.HasPrefix(strings.ToLower(message.To), query+" ") {
+			if cancelled == nil || message.Left.Before(cancelled.Left) {
+				cancelled = message
+			}
+		}
+	}
+	if cancelled == nil {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("you have no waiting message for %s", query)
+	}
+	delete(m.state.Messages, cancelled.ID)
+	m.mutex.Unlock()
+
+	return cancelled, m.save()
+}
+
+// from returns copies of the messages left by an avatar. The caller must hold the lock.
+func (m *Manager) from(uuid string) []*Message {
+	var messages []*Message
+	for _, message := range m.state.Messages {
+		if strings.EqualFold(message.FromUUID, uuid) {
+			copied := *message
+			messages = append(messages, &copied)
+		}
+	}
+	return messages
+}
+
+// expire drops messages nobody collected in time. The caller must hold the lock.
+func (m *Manager) expire(now time.Time) {
+	keep := time.Duration(m.config.ExpireDays) * 24 * time.Hour
+	for id, message := range m.state.Messages {
+		if now.Sub(message.Left) > keep {
+			log.Printf("Message %s from %s to %s expired undelivered", id, message.From, message.To)
+			delete(m.state.Messages, id)
+		}
+	}
+}
+
+// isFor reports whether a message is addressed to an avatar: by UUID when
+// it is known, otherwise by full name, or by first name if that's all the
+// sender gave
+func isFor(message *Message, name, uuid string) bool {
+	if message.ToUUID != "" {
+		return strings.EqualFold(message.ToUUID, uuid)
+	}
+
+	to := normalizeName(message.To)
+	name = normalizeName(name)
+	if to == name {
+		return true
+	}
+	first, _, _ := strings.Cut(name, " ")
+	return !strings.Contains(to, " ") && to == first
+}
+
+// normalizeName lower-cases a name and drops the "Resident" last name
+func normalizeName(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	return strings.TrimSuffix(name, " resident")
+}
+
+// sortByLeft orders messages oldest first
+func sortByLeft(messages []*Message) {
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Left.Before(messages[j].Left) })
+}
+
+// save writes the pending messages to disk
+func (m *Manager) save() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return persistant.SaveState(m.config.Storage, m.state)
+}
@@ -0,0 +1,133 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"slbot/internal/config"
+)
+
+// Directions a message can travel
+const (
+	Incoming = "incoming"
+	Outgoing = "outgoing"
+)
+
+// Actions taken on a blocked message
+const (
+	ActionAllow   = "allow"
+	ActionDrop    = "drop"
+	ActionReplace = "replace"
+)
+
+// linkPattern matches web links
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// SelfCheck asks the model whether a reply is appropriate, returning false
+// and a reason if it is not
+type SelfCheck func(ctx context.Context, text string) (bool, string, error)
+
+// Verdict is the outcome of checking a message
+type Verdict struct {
+	Allowed bool
+	Action  string
+	Reason  string
+}
+
+// Moderator checks messages against the configured rules
+type Moderator struct {
+	config    config.ModerationConfig
+	rules     []*regexp.Regexp
+	selfCheck SelfCheck
+}
+
+// NewModerator compiles the moderation rules. Invalid patterns are logged and skipped.
+func NewModerator(cfg *config.Config) *Moderator {
+	m := &Moderator{config: cfg.Moderation}
+
+	for _, word := range cfg.Moderation.Words {
+		if word = strings.TrimSpace(word); word != "" {
+			m.rules = append(m.rules, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(word)+`\b`))
+		}
+	}
+	for _, pattern := range cfg.Moderation.Patterns {
+		rule, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("Ignoring invalid moderation pattern %q: %v", pattern, err)
+			continue
+		}
+		m.rules = append(m.rules, rule)
+	}
+
+	return m
+}
+
+// IsEnabled returns whether moderation is enabled
+func (m *Moderator) IsEnabled() bool {
+	return m.config.Enabled
+}
+
+// SetSelfCheck sets the function used to ask the model about outgoing replies
+func (m *Moderator) SetSelfCheck(check SelfCheck) {
+	m.selfCheck = check
+}
+
+// Check applies the rules to a message travelling in the given direction
+func (m *Moderator) Check(ctx context.Context, direction, text string) Verdict {
+	if !m.config.Enabled {
+		return Verdict{Allowed: true, Action: ActionAllow}
+	}
+
+	if reason := m.checkRules(text); reason != "" {
+		return m.block(direction, reason)
+	}
+
+	if direction == Outgoing && m.config.SelfCheck && m.selfCheck != nil {
+		ok, reason, err := m.selfCheck(ctx, text)
+		if err != nil {
+			// Don't silence the bot because the check itself failed
+			log.Printf("Moderation self-check failed: %v", err)
+		} else if !ok {
+			return m.block(direction, "self-check: "+reason)
+		}
+	}
+
+	return Verdict{Allowed: true, Action: ActionAllow}
+}
+
+// checkRules returns why a message breaks the blocklist or link rules, or ""
+func (m *Moderator) checkRules(text string) string {
+	for _, rule := range m.rules {
+		if match := rule.FindString(text); match != "" {
+			return fmt.Sprintf("blocked term %q", match)
+		}
+	}
+
+	if m.config.MaxLinks != nil {
+		if links := len(linkPattern.FindAllString(text, -1)); links > *m.config.MaxLinks {
+			return fmt.Sprintf("%d links (limit %d)", links, *m.config.MaxLinks)
+		}
+	}
+
+	return ""
+}
+
+// block returns the verdict for a blocked message in the given direction
+func (m *Moderator) block(direction, reason string) Verdict {
+	action := m.config.OutgoingAction
+	if direction == Incoming {
+		action = m.config.IncomingAction
+	}
+	if action != ActionReplace {
+		action = ActionDrop
+	}
+	return Verdict{Allowed: false, Action: action, Reason: reason}
+}
+
+// NotifyOwners returns whether owners should be told about blocked messages
+func (m *Moderator) NotifyOwners() bool {
+	return m.config.NotifyOwners
+}
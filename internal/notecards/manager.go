@@ -0,0 +1,206 @@
+package notecards
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"slbot/internal/config"
+	"slbot/internal/corrade"
+	"slbot/internal/persistant"
+)
+
+// Notecard holds the cached text of an in-world notecard
+type Notecard struct {
+	Name     string    `json:"name"`
+	UUID     string    `json:"uuid"`
+	Text     string    `json:"text"`
+	LoadedAt time.Time `json:"loadedAt"`
+}
+
+// Match is a notecard section matching a keyword lookup
+type Match struct {
+	Notecard string `json:"notecard"`
+	Text     string `json:"text"`
+	Score    int    `json:"score"`
+}
+
+// Manager reads notecards through Corrade and keeps a local cache
+type Manager struct {
+	config        config.NotecardsConfig
+	corradeClient *corrade.Client
+	notecards     map[string]*Notecard
+	lastReload    time.Time
+	mutex         sync.RWMutex
+}
+
+// NewManager creates a notecard manager and loads the local cache
+func NewManager(cfg *config.Config, corradeClient *corrade.Client) *Manager {
+	manager := &Manager{
+		config:        cfg.Notecards,
+		corradeClient: corradeClient,
+		notecards:     make(map[string]*Notecard),
+	}
+
+	if !manager.config.Enabled {
+		return manager
+	}
+
+	if err := persistant.LoadState(manager.config.Cache, &manager.notecards); err != nil {
+		log.Printf("No notecard cache loaded from %s: %v", manager.config.Cache, err)
+	} else {
+		log.Printf("Loaded %d cached notecards", len(manager.notecards))
+	}
+
+	return manager
+}
+
+// IsEnabled returns whether notecard reading is enabled
+func (m *Manager) IsEnabled() bool {
+	return m.config.Enabled
+}
+
+// Count returns the number of cached notecards
+func (m *Manager) Count() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return len(m.notecards)
+}
+
+// Reload reads all notecards from the configured inventory folder
+func (m *Manager) Reload() (int, error) {
+	if !m.config.Enabled {
+		return 0, fmt.Errorf("notecard reading is disabled")
+	}
+
+	items, err := m.corradeClient.ListNotecards(m.config.Folder)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list notecards in %s: %w", m.config.Folder, err)
+	}
+
+	notecards := make(map[string]*Notecard)
+	for _, item := range items {
+		ref := item.UUID
+		if ref == "" {
+			ref = strings.TrimSuffix(m.config.Folder, "/") + "/" + item.Name
+		}
+
+		text, err := m.corradeClient.ReadNotecard(ref)
+		if err != nil {
+			log.Printf("Failed to read notecard '%s': %v", item.Name, err)
+			continue
+		}
+
+		notecards[item.Name] = &Notecard{
+			Name:     item.Name,
+			UUID:     item.UUID,
+			Text:     text,
+			LoadedAt: time.Now(),
+		}
+	}
+
+	m.mutex.Lock()
+	m.notecards = notecards
+	m.lastReload = time.Now()
+	m.mutex.Unlock()
+
+	if err := persistant.SaveState(m.config.Cache, notecards); err != nil {
+		log.Printf("Failed to save notecard cache: %v", err)
+	}
+
+	log.Printf("Reloaded %d of %d notecards from %s", len(notecards), len(items), m.config.Folder)
+	return len(notecards), nil
+}
+
+// GetNotecards returns the cached notecards sorted by name
+func (m *Manager) GetNotecards() []*Notecard {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make([]*Notecard, 0, len(m.notecards))
+	for _, notecard := range m.notecards {
+		result = append(result, notecard)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// LastReload returns when the notecards were last read from inventory
+func (m *Manager) LastReload() time.Time {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.lastReload
+}
+
+// Lookup finds the notecard sections that best match the given keywords
+func (m *Manager) Lookup(query string, limit int) []Match {
+	keywords := strings.Fields(strings.ToLower(query))
+	if len(keywords) == 0 {
+		return nil
+	}
+
+	var matches []Match
+	for _, notecard := range m.GetNotecards() {
+		// FAQ notecards are written as paragraphs separated by blank lines
+		for _, section := range strings.Split(notecard.Text, "\n\n") {
+			section = strings.TrimSpace(section)
+			if section == "" {
+				continue
+			}
+
+			lower := strings.ToLower(section)
+			score := 0
+			for _, keyword := range keywords {
+				if strings.Contains(lower, keyword) {
+					score += strings.Count(lower, keyword)
+				} else {
+					score = 0
+					break
+				}
+			}
+			// A section about the notecard's subject ranks higher, but only if it matches too
+			if score > 0 && strings.Contains(strings.ToLower(notecard.Name), strings.Join(keywords, " ")) {
+				score += 5
+			}
+
+			if score > 0 {
+				matches = append(matches, Match{Notecard: notecard.Name, Text: section, Score: score})
+			}
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// PromptContext returns notecard text to include in the Llama prompt
+func (m *Manager) PromptContext() string {
+	if !m.config.Enabled || !m.config.IncludeInPrompt {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, notecard := range m.GetNotecards() {
+		entry := fmt.Sprintf("[%s]\n%s\n\n", notecard.Name, notecard.Text)
+		if sb.Len()+len(entry) > m.config.MaxPromptChars {
+			// Split at a character boundary so the model gets valid text
+			remaining := m.config.MaxPromptChars - sb.Len()
+			for remaining > 0 && !utf8.RuneStart(entry[remaining]) {
+				remaining--
+			}
+			if remaining > 0 {
+				sb.WriteString(entry[:remaining])
+			}
+			break
+		}
+		sb.WriteString(entry)
+	}
+	return strings.TrimSpace(sb.String())
+}
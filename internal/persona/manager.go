@@ -0,0 +1,247 @@
+package persona
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+	"slbot/internal/slfunc"
+)
+
+// Base is the name of the persona made from the <prompts> block
+const Base = "default"
+
+// entry is a configured persona with its prompts filled in from the base
+type entry struct {
+	persona  config.Persona
+	schedule *schedule
+	prompts  config.PromptsConfig
+}
+
+// Info describes a persona
+type Info struct {
+	Name     string `json:"name"`
+	Region   string `json:"region,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+	Active   bool   `json:"active"`
+}
+
+// state is the persona chosen at runtime, saved across restarts
+type state struct {
+	Manual string `json:"manual"`
+}
+
+// Manager keeps track of which persona the bot is using
+type Manager struct {
+	config   config.PersonasConfig
+	base     config.PromptsConfig
+	personas []*entry
+	manual   string // Chosen by an owner; empty to follow region and schedule bindings
+	active   string
+	mutex    sync.RWMutex
+}
+
+// NewManager creates a persona manager and restores the persona chosen at runtime
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config: cfg.Personas,
+		base:   cfg.Prompts,
+		active: Base,
+	}
+
+	for _, persona := range cfg.Personas.Personas {
+		if persona.Name == "" || strings.EqualFold(persona.Name, Base) {
+			log.Printf("Skipping persona without a usable name '%s'", persona.Name)
+			continue
+		}
+
+		e := &entry{persona: persona, prompts: merge(cfg.Prompts, persona.PromptsConfig)}
+		if persona.Schedule != "" {
+			s, err := parseSchedule(persona.Schedule)
+			if err != nil {
+				log.Printf("Persona %s will not be scheduled: %v", persona.Name, err)
+			} else {
+				e.schedule = s
+			}
+		}
+		manager.personas = append(manager.personas, e)
+	}
+
+	var saved state
+	if err := persistant.LoadState(manager.config.Storage, &saved); err == nil && saved.Manual != "" {
+		if manager.find(saved.Manual) != nil || strings.EqualFold(saved.Manual, Base) {
+			manager.manual = saved.Manual
+		}
+	}
+
+	manager.active = manager.choose("", time.Now())
+	log.Printf("Using persona %s", manager.active)
+
+	return manager
+}
+
+// merge fills in the settings a persona leaves out from the base prompts
+func merge(base, persona config.PromptsConfig) config.PromptsConfig {
+	merged := base
+	if persona.SystemPrompt != "" {
+		merged.SystemPrompt = persona.SystemPrompt
+	}
+	if persona.ChatPrompt != "" {
+		merged.ChatPrompt = persona.ChatPrompt
+	}
+	if persona.WelcomeMessage != "" {
+		merged.WelcomeMessage = persona.WelcomeMessage
+	}
+	if persona.ErrorMessage != "" {
+		merged.ErrorMessage = persona.ErrorMessage
+	}
+	if persona.GreetingPrompt != "" {
+		merged.GreetingPrompt = persona.GreetingPrompt
+	}
+	if persona.HelpPrompt != "" {
+		merged.HelpPrompt = persona.HelpPrompt
+	}
+	if len(persona.FallbackResponses) > 0 {
+		merged.FallbackResponses = persona.FallbackResponses
+	}
+	return merged
+}
+
+// find looks up a persona by name, ignoring case. Callers hold the mutex if needed.
+func (m *Manager) find(name string) *entry {
+	for _, e := range m.personas {
+		if strings.EqualFold(e.persona.Name, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// choose picks the persona to use: the one chosen by an owner, else the
+// first whose region and schedule bindings match, else the default
+func (m *Manager) choose(region string, now time.Time) string {
+	if m.manual != "" {
+		if e := m.find(m.manual); e != nil {
+			return e.persona.Name
+		}
+		return Base
+	}
+
+	slNow := slfunc.SLTime(now)
+	for _, e := range m.personas {
+		bound := e.persona.Region != "" || e.schedule != nil
+		if !bound {
+			continue
+		}
+		if e.persona.Region != "" && !strings.EqualFold(e.persona.Region, region) {
+			continue
+		}
+		if e.persona.Schedule != "" && (e.schedule == nil || !e.schedule.contains(slNow)) {
+			continue
+		}
+		return e.persona.Name
+	}
+
+	if e := m.find(m.config.Default); e != nil {
+		return e.persona.Name
+	}
+	return Base
+}
+
+// Prompts returns the prompts of the active persona
+func (m *Manager) Prompts() config.PromptsConfig {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if e := m.find(m.active); e != nil {
+		return e.prompts
+	}
+	return m.base
+}
+
+// Active returns the name of the active persona
+func (m *Manager) Active() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.active
+}
+
+// Manual returns the persona chosen by an owner, or "" when bindings decide
+func (m *Manager) Manual() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.manual
+}
+
+// NeedsRegion reports whether any persona is bound to a region
+func (m *Manager) NeedsRegion() bool {
+	for _, e := range m.personas {
+		if e.persona.Region != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Set switches to a persona until it is changed again. "auto" goes back to
+// choosing by region and schedule.
+func (m *Manager) Set(name string) (string, error) {
+	name = strings.TrimSpace(name)
+
+	m.mutex.Lock()
+	switch {
+	case strings.EqualFold(name, "auto") || name == "":
+		m.manual = ""
+	case strings.EqualFold(name, Base):
+		m.manual = Base
+	default:
+		e := m.find(name)
+		if e == nil {
+			m.mutex.Unlock()
+			return "", fmt.Errorf("no persona called '%s'", name)
+		}
+		m.manual = e.persona.Name
+	}
+	m.active = m.choose("", time.Now())
+	active := m.active
+	manual := m.manual
+	m.mutex.Unlock()
+
+	if err := persistant.SaveState(m.config.Storage, state{Manual: manual}); err != nil {
+		log.Printf("Failed to save persona: %v", err)
+	}
+	return active, nil
+}
+
+// Update re-evaluates region and schedule bindings, returning the active
+// persona and whether it changed
+func (m *Manager) Update(region string, now time.Time) (string, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	active := m.choose(region, now)
+	changed := active != m.active
+	m.active = active
+	return active, changed
+}
+
+// List returns the default persona followed by the configured ones
+func (m *Manager) List() []Info {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	list := []Info{{Name: Base, Active: m.active == Base}}
+	for _, e := range m.personas {
+		list = append(list, Info{
+			Name:     e.persona.Name,
+			Region:   e.persona.Region,
+			Schedule: e.persona.Schedule,
+			Active:   e.persona.Name == m.active,
+		})
+	}
+	return list
+}
@@ -0,0 +1,98 @@
+package persona
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// schedule is a weekly window of SL time such as "fri,sat 20:00-23:59".
+// A window that ends before it starts runs past midnight.
+type schedule struct {
+	days  [7]bool
+	start int // Minutes after midnight
+	end   int
+}
+
+// parseSchedule reads "<days> <HH:MM>-<HH:MM>", where days is "daily" or a
+// comma separated list of days and day ranges like "mon-fri"
+func parseSchedule(text string) (*schedule, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("schedule '%s' should look like 'fri,sat 20:00-23:00'", text)
+	}
+
+	s := &schedule{}
+	if fields[0] == "daily" {
+		for i := range s.days {
+			s.days[i] = true
+		}
+	} else {
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			first, err := parseDay(from)
+			if err != nil {
+				return nil, err
+			}
+			last := first
+			if isRange {
+				if last, err = parseDay(to); err != nil {
+					return nil, err
+				}
+			}
+			for day := first; ; day = (day + 1) % 7 {
+				s.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return nil, fmt.Errorf("schedule '%s' needs a time range like 20:00-23:00", text)
+	}
+	var err error
+	if s.start, err = parseClock(from); err != nil {
+		return nil, err
+	}
+	if s.end, err = parseClock(to); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// parseDay converts a day name to its weekday number
+func parseDay(name string) (int, error) {
+	for i, day := range dayNames {
+		if strings.HasPrefix(name, day) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day '%s'", name)
+}
+
+// parseClock converts HH:MM to minutes after midnight
+func parseClock(text string) (int, error) {
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", text)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// contains reports whether an SL time falls in the window
+func (s *schedule) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := int(t.Weekday())
+
+	if s.start <= s.end {
+		return s.days[day] && minute >= s.start && minute < s.end
+	}
+
+	// Overnight: the evening of a listed day or the early hours after it
+	return s.days[day] && minute >= s.start || s.days[(day+6)%7] && minute < s.end
+}
@@ -0,0 +1,151 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"slbot/internal/slfunc"
+	"slbot/internal/types"
+)
+
+// legacy maps the placeholders of older configs to template fields
+var legacy = strings.NewReplacer(
+	"{message}", "{{.Message}}",
+	"{botname}", "{{.BotName}}",
+	"{maxlen}", "{{.MaxLen}}",
+	"{avatar}", "{{.Avatar}}",
+)
+
+// cache holds parsed templates by their text
+var cache sync.Map
+
+// Lookups fetch context that needs a call to Corrade, so it is only
+// fetched when a template uses it
+type Lookups struct {
+	Region   func() string
+	Position func() types.Position
+	Nearby   func() []string
+}
+
+// Context is what prompt templates are rendered with. Fields are used as
+// {{.Avatar}}, {{.Region}} and so on; the older {message}, {botname},
+// {maxlen} and {avatar} placeholders still work.
+type Context struct {
+	Message  string            // The message being answered, or the text being summarized or checked
+	Avatar   string            // Name of the avatar speaking to the bot
+	UUID     string            // UUID of the avatar speaking to the bot
+	BotName  string            // The bot's name
+	MaxLen   int               // Longest message the bot can say in chat
+	Persona  string            // Name of the active persona
+	Language string            // Name of the language to reply in, e.g. Spanish
+	History  string            // Earlier exchanges with the avatar as "Name: text" lines
+	Summary  string            // Summary of older exchanges with the avatar
+	Time     time.Time         // When the message arrived
+	Event    string            // Name of the in-world event being handled, if any
+	Data     map[string]string // Details sent with the event, e.g. {{.Data.avatar}}
+
+	lookups  Lookups
+	region   *string
+	position *types.Position
+	nearby   []string
+}
+
+// NewContext creates a template context whose region, position and nearby
+// avatars are fetched with lookups when needed
+func NewContext(lookups Lookups) *Context {
+	return &Context{Time: time.Now(), lookups: lookups}
+}
+
+// Region returns the name of the region the bot is in
+func (c *Context) Region() string {
+	if c.region == nil {
+		region := ""
+		if c.lookups.Region != nil {
+			region = c.lookups.Region()
+		}
+		c.region = &region
+	}
+	return *c.region
+}
+
+// Position returns the bot's position in the region
+func (c *Context) Position() types.Position {
+	if c.position == nil {
+		position := types.Position{}
+		if c.lookups.Position != nil {
+			position = c.lookups.Position()
+		}
+		c.position = &position
+	}
+	return *c.position
+}
+
+// Nearby returns the names of the other avatars in the region
+func (c *Context) Nearby() []string {
+	if c.nearby == nil {
+		c.nearby = []string{}
+		if c.lookups.Nearby != nil {
+			c.nearby = c.lookups.Nearby()
+		}
+	}
+	return c.nearby
+}
+
+// NearbyList returns the names of the other avatars as a comma separated list
+func (c *Context) NearbyList() string {
+	return strings.Join(c.Nearby(), ", ")
+}
+
+// SLTime returns the time in Second Life, e.g. "Friday 8:15 PM"
+func (c *Context) SLTime() string {
+	return slfunc.SLTime(c.Time).Format("Monday 3:04 PM")
+}
+
+// parse converts a prompt to a template, reusing earlier parses
+func parse(text string) (*template.Template, error) {
+	if tmpl, exists := cache.Load(text); exists {
+		return tmpl.(*template.Template), nil
+	}
+
+	tmpl, err := template.New("prompt").Parse(legacy.Replace(text))
+	if err != nil {
+		return nil, err
+	}
+	cache.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// Render fills in a prompt template
+func Render(text string, ctx *Context) (string, error) {
+	tmpl, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, ctx); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// Validate checks that a prompt template parses and only uses known fields
+func Validate(text string) error {
+	sample := NewContext(Lookups{
+		Region:   func() string { return "Sandbox" },
+		Position: func() types.Position { return types.Position{X: 128, Y: 128, Z: 25} },
+		Nearby:   func() []string { return []string{"Jane Doe"} },
+	})
+	sample.Message = "hello"
+	sample.Avatar = "Jane Doe"
+	sample.BotName = "Bot"
+	sample.MaxLen = 1024
+
+	if _, err := Render(text, sample); err != nil {
+		return fmt.Errorf("%v", strings.TrimPrefix(err.Error(), "template: "))
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+)
+
+// Status is what happened to a submitted job
+type Status int
+
+const (
+	Queued     Status = iota // The job is waiting for a worker
+	Superseded               // The job replaced one from the same sender that had not started
+	Full                     // The queue is full and the job was not accepted
+)
+
+// job is a unit of work waiting in the queue
+type job struct {
+	key      string
+	run      func()
+	enqueued time.Time
+}
+
+// Stats are the pool's counters
+type Stats struct {
+	Workers    int           `json:"workers"`
+	Busy       int           `json:"busy"`
+	Queued     int           `json:"queued"`
+	MaxQueue   int           `json:"maxQueue"`
+	Processed  int           `json:"processed"`
+	Superseded int           `json:"superseded"`
+	Rejected   int           `json:"rejected"`
+	AvgWait    time.Duration `json:"avgWait"`
+	MaxWait    time.Duration `json:"maxWait"`
+}
+
+// Pool runs jobs on a fixed number of workers. Each sender has at most one
+// job waiting; a newer one takes its place in the queue.
+type Pool struct {
+	config     config.QueueConfig
+	jobs       []*job
+	busy       int
+	processed  int
+	superseded int
+	rejected   int
+	totalWait  time.Duration
+	maxWait    time.Duration
+	closed     bool
+	mutex      sync.Mutex
+	cond       *sync.Cond
+}
+
+// NewPool creates a worker pool; call Start to begin running jobs
+func NewPool(cfg *config.Config) *Pool {
+	pool := &Pool{config: cfg.Queue}
+	pool.cond = sync.NewCond(&pool.mutex)
+	return pool
+}
+
+// Start runs the workers until the context is cancelled
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.config.Workers; i++ {
+		go p.worker()
+	}
+
+	go func() {
+		<-ctx.Done()
+		p.mutex.Lock()
+		p.closed = true
+		p.jobs = nil
+		p.mutex.Unlock()
+		p.cond.Broadcast()
+	}()
+}
+
+// Submit queues a job for a sender. A job from the same sender that is
+// still waiting is dropped in favour of the new one.
+func (p *Pool) Submit(sender string, run func()) Status {
+	key := strings.ToLower(sender)
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		p.rejected++
+		return Full
+	}
+
+	for i, waiting := range p.jobs {
+		if waiting.key == key {
+			p.jobs[i] = &job{key: key, run: run, enqueued: waiting.enqueued}
+			p.superseded++
+			return Superseded
+		}
+	}
+
+	if len(p.jobs) >= p.config.MaxQueue {
+		p.rejected++
+		return Full
+	}
+
+	p.jobs = append(p.jobs, &job{key: key, run: run, enqueued: time.Now()})
+	p.cond.Signal()
+	return Queued
+}
+
+// worker runs queued jobs one at a time
+func (p *Pool) worker() {
+	for {
+		p.mutex.Lock()
+		for len(p.jobs) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			p.mutex.Unlock()
+			return
+		}
+
+		next := p.jobs[0]
+		p.jobs = p.jobs[1:]
+		wait := time.Since(next.enqueued)
+		p.totalWait += wait
+		if wait > p.maxWait {
+			p.maxWait = wait
+		}
+		p.busy++
+		p.mutex.Unlock()
+
+		next.run()
+
+		p.mutex.Lock()
+		p.busy--
+		p.processed++
+		p.mutex.Unlock()
+	}
+}
+
+// Stats returns the pool's counters
+func (p *Pool) Stats() Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := Stats{
+		Workers:    p.config.Workers,
+		Busy:       p.busy,
+		Queued:     len(p.jobs),
+		MaxQueue:   p.config.MaxQueue,
+		Processed:  p.processed,
+		Superseded: p.superseded,
+		Rejected:   p.rejected,
+		MaxWait:    p.maxWait,
+	}
+	if started := p.processed + p.busy; started > 0 {
+		stats.AvgWait = p.totalWait / time.Duration(started)
+	}
+	return stats
+}
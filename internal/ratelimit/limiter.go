@@ -0,0 +1,250 @@
+package ratelimit
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+)
+
+// window is the period over which warnings and strikes are counted
+const window = time.Minute
+
+// idleExpiry is how long an avatar's counters are kept after their last message
+const idleExpiry = time.Hour
+
+// bucket is a token bucket refilled at a steady rate
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and takes a token if one is available
+func (b *bucket) take(now time.Time, perMinute float64, burst int) bool {
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens += now.Sub(b.last).Minutes() * perMinute
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// avatarState tracks one avatar's messages
+type avatarState struct {
+	name         string
+	bucket       bucket
+	allowed      int
+	limited      int
+	strikes      int
+	windowStart  time.Time
+	warnedAt     time.Time
+	ignoredUntil time.Time
+	lastSeen     time.Time
+}
+
+// Decision says what to do with a message
+type Decision struct {
+	Allowed bool // Process the message
+	Warn    bool // Tell the sender to slow down
+	Ignored bool // The sender has just been put on the ignore list
+}
+
+// AvatarStats are the counters for one avatar
+type AvatarStats struct {
+	UUID         string     `json:"uuid"`
+	Name         string     `json:"name"`
+	Allowed      int        `json:"allowed"`
+	Limited      int        `json:"limited"`
+	IgnoredUntil *time.Time `json:"ignoredUntil,omitempty"`
+	LastSeen     time.Time  `json:"lastSeen"`
+}
+
+// Stats are the limiter's counters
+type Stats struct {
+	Enabled    bool          `json:"enabled"`
+	Allowed    int           `json:"allowed"`
+	Limited    int           `json:"limited"`
+	Ignored    int           `json:"ignored"`
+	LLMAllowed int           `json:"llmAllowed"`
+	LLMLimited int           `json:"llmLimited"`
+	Avatars    []AvatarStats `json:"avatars"`
+}
+
+// Limiter applies per-avatar and global rate limits
+type Limiter struct {
+	config     config.RateLimitConfig
+	avatars    map[string]*avatarState
+	global     bucket
+	allowed    int
+	limited    int
+	ignored    int
+	llmAllowed int
+	llmLimited int
+	lastPrune  time.Time
+	mutex      sync.Mutex
+}
+
+// NewLimiter creates a rate limiter
+func NewLimiter(cfg *config.Config) *Limiter {
+	return &Limiter{
+		config:  cfg.RateLimit,
+		avatars: make(map[string]*avatarState),
+	}
+}
+
+// IsEnabled returns whether rate limiting is enabled
+func (l *Limiter) IsEnabled() bool {
+	return l.config.Enabled
+}
+
+// Check decides whether a message from an avatar should be processed
+func (l *Limiter) Check(uuid, name string) Decision {
+	if !l.config.Enabled || uuid == "" {
+		return Decision{Allowed: true}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	key := strings.ToLower(uuid)
+	state, exists := l.avatars[key]
+	if !exists {
+		state = &avatarState{}
+		l.avatars[key] = state
+	}
+	state.name = name
+	state.lastSeen = now
+
+	if now.Before(state.ignoredUntil) {
+		state.limited++
+		l.limited++
+		return Decision{}
+	}
+
+	if state.bucket.take(now, l.config.PerAvatarRate, l.config.PerAvatarBurst) {
+		state.allowed++
+		l.allowed++
+		return Decision{Allowed: true}
+	}
+
+	state.limited++
+	l.limited++
+
+	if now.Sub(state.windowStart) > window {
+		state.windowStart = now
+		state.strikes = 0
+	}
+	state.strikes++
+
+	if state.strikes >= l.config.IgnoreAfter {
+		state.ignoredUntil = now.Add(time.Duration(l.config.IgnoreMinutes) * time.Minute)
+		state.strikes = 0
+		l.ignored++
+		return Decision{Ignored: true}
+	}
+
+	decision := Decision{}
+	if now.Sub(state.warnedAt) > window {
+		state.warnedAt = now
+		decision.Warn = true
+	}
+	return decision
+}
+
+// AllowLLM takes a token from the global bucket for an LLM reply
+func (l *Limiter) AllowLLM() bool {
+	if !l.config.Enabled {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.global.take(time.Now(), l.config.GlobalRate, l.config.GlobalBurst) {
+		l.llmAllowed++
+		return true
+	}
+	l.llmLimited++
+	return false
+}
+
+// Unignore lifts the ignore on an avatar and resets its limits
+func (l *Limiter) Unignore(uuid string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	key := strings.ToLower(uuid)
+	state, exists := l.avatars[key]
+	if !exists {
+		return false
+	}
+	state.ignoredUntil = time.Time{}
+	state.strikes = 0
+	state.bucket = bucket{}
+	return true
+}
+
+// Stats returns the counters, busiest avatars first
+func (l *Limiter) Stats() Stats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	stats := Stats{
+		Enabled:    l.config.Enabled,
+		Allowed:    l.allowed,
+		Limited:    l.limited,
+		Ignored:    l.ignored,
+		LLMAllowed: l.llmAllowed,
+		LLMLimited: l.llmLimited,
+		Avatars:    make([]AvatarStats, 0, len(l.avatars)),
+	}
+	for uuid, state := range l.avatars {
+		avatar := AvatarStats{
+			UUID:     uuid,
+			Name:     state.name,
+			Allowed:  state.allowed,
+			Limited:  state.limited,
+			LastSeen: state.lastSeen,
+		}
+		if now.Before(state.ignoredUntil) {
+			until := state.ignoredUntil
+			avatar.IgnoredUntil = &until
+		}
+		stats.Avatars = append(stats.Avatars, avatar)
+	}
+	sort.Slice(stats.Avatars, func(i, j int) bool {
+		return stats.Avatars[i].Allowed+stats.Avatars[i].Limited > stats.Avatars[j].Allowed+stats.Avatars[j].Limited
+	})
+	return stats
+}
+
+// prune drops avatars that have been quiet for a while. Callers hold the mutex.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < 10*time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	for key, state := range l.avatars {
+		if now.Sub(state.lastSeen) > idleExpiry && now.After(state.ignoredUntil) {
+			delete(l.avatars, key)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package roles
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+	"slbot/internal/slfunc"
+)
+
+// Assignment records a role granted to an avatar
+type Assignment struct {
+	UUID      string    `json:"uuid"`
+	Name      string    `json:"name"`
+	Role      Role      `json:"role"`
+	GrantedBy string    `json:"grantedBy"`
+	GrantedAt time.Time `json:"grantedAt"`
+}
+
+// Manager resolves the roles of avatars and API callers
+type Manager struct {
+	config      *config.Config
+	owners      map[string]string      // UUID -> owner name
+	assignments map[string]*Assignment // UUID -> assignment
+	commands    map[string]Role        // Command name -> required role override
+	apis        map[string]Role        // "METHOD /route" -> required role override
+	apiKeys     map[string]config.APIKey
+	mutex       sync.RWMutex
+}
+
+// NewManager creates a role manager from the configuration and saved assignments
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config:      cfg,
+		owners:      make(map[string]string),
+		assignments: make(map[string]*Assignment),
+		commands:    make(map[string]Role),
+		apis:        make(map[string]Role),
+		apiKeys:     make(map[string]config.APIKey),
+	}
+
+	for _, owner := range cfg.Bot.Owners {
+		if owner.UUID != "" {
+			manager.owners[strings.ToLower(owner.UUID)] = strings.TrimSpace(owner.Name)
+		}
+	}
+
+	if err := persistant.LoadState(cfg.Roles.Storage, &manager.assignments); err != nil {
+		log.Printf("No role assignments loaded from %s: %v", cfg.Roles.Storage, err)
+	}
+
+	// Assignments in the configuration file take precedence over saved ones
+	for _, assign := range cfg.Roles.Assignments {
+		role, err := Parse(assign.Role)
+		if err != nil || assign.UUID == "" {
+			log.Printf("Ignoring role assignment for '%s': uuid=%q role=%q", assign.Name, assign.UUID, assign.Role)
+			continue
+		}
+		manager.assignments[strings.ToLower(assign.UUID)] = &Assignment{
+			UUID:      assign.UUID,
+			Name:      strings.TrimSpace(assign.Name),
+			Role:      role,
+			GrantedBy: "config",
+		}
+	}
+
+	for _, override := range cfg.Roles.Commands {
+		if role, err := Parse(override.Role); err == nil {
+			manager.commands[strings.ToLower(override.Name)] = role
+		} else {
+			log.Printf("Ignoring role override for command '%s': %v", override.Name, err)
+		}
+	}
+
+	for _, override := range cfg.Roles.APIs {
+		if role, err := Parse(override.Role); err == nil {
+			manager.apis[override.Route] = role
+		} else {
+			log.Printf("Ignoring role override for API '%s': %v", override.Route, err)
+		}
+	}
+
+	for _, key := range cfg.Roles.APIKeys {
+		token := strings.TrimSpace(key.Token)
+		if token != "" {
+			manager.apiKeys[token] = key
+		}
+	}
+
+	return manager
+}
+
+// ResolveOwners looks up the UUIDs of owners configured by name only, and
+// checks that owners configured with both a UUID and a name agree
+func (m *Manager) ResolveOwners(lookup func(names []string) (map[string]string, error)) {
+	var names []string
+	for _, owner := range m.config.Bot.Owners {
+		if name := strings.TrimSpace(owner.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		if len(m.config.Bot.Owners) == 0 {
+			log.Printf("Warning: no owners configured")
+		}
+		return
+	}
+
+	keys, err := lookup(names)
+	if err != nil {
+		log.Printf("Warning: could not resolve owner names: %v", err)
+		keys = map[string]string{}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, owner := range m.config.Bot.Owners {
+		name := strings.TrimSpace(owner.Name)
+		resolved := ""
+		for keyName, key := range keys {
+			if slfunc.MatchName(keyName, name) {
+				resolved = key
+			}
+		}
+
+		switch {
+		case owner.UUID == "" && resolved == "":
+			log.Printf("Warning: owner '%s' has no UUID and could not be resolved; they will not have owner access", name)
+		case owner.UUID == "":
+			m.owners[strings.ToLower(resolved)] = name
+			log.Printf("Warning: owner '%s' resolved to %s; add uuid=\"%s\" to the configuration", name, resolved, resolved)
+		case resolved != "" && !strings.EqualFold(resolved, owner.UUID):
+			log.Printf("Warning: owner '%s' is configured as %s but that name belongs to %s; using the configured UUID", name, owner.UUID, resolved)
+		}
+	}
+}
+
+// IsOwner reports whether an avatar UUID belongs to a configured owner
+func (m *Manager) IsOwner(uuid string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, exists := m.owners[strings.ToLower(uuid)]
+	return exists
+}
+
+// Owners returns the UUIDs and names of the owners
+func (m *Manager) Owners() map[string]string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make(map[string]string, len(m.owners))
+	for uuid, name := range m.owners {
+		result[uuid] = name
+	}
+	return result
+}
+
+// RoleOf returns the role of the avatar with the given UUID
+func (m *Manager) RoleOf(uuid string) Role {
+	if uuid == "" {
+		return Guest
+	}
+	if m.IsOwner(uuid) {
+		return Owner
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if assignment, exists := m.assignments[strings.ToLower(uuid)]; exists {
+		return assignment.Role
+	}
+	return Guest
+}
+
+// Has reports whether the avatar with the given UUID holds at least the required role
+func (m *Manager) Has(uuid string, required Role) bool {
+	return m.RoleOf(uuid) >= required
+}
+
+// Grant gives an avatar a role and saves the assignment
+func (m *Manager) Grant(uuid, name string, role Role, grantedBy string) error {
+	if uuid == "" {
+		return fmt.Errorf("cannot grant a role without an avatar UUID")
+	}
+	if role >= Owner {
+		return fmt.Errorf("owners can only be set in the configuration file")
+	}
+
+	m.mutex.Lock()
+	m.assignments[strings.ToLower(uuid)] = &Assignment{
+		UUID:      uuid,
+		Name:      name,
+		Role:      role,
+		GrantedBy: grantedBy,
+		GrantedAt: time.Now(),
+	}
+	m.mutex.Unlock()
+
+	log.Printf("Granted role %s to %s (%s) by %s", role, name, uuid, grantedBy)
+	return m.save()
+}
+
+// Revoke removes an avatar's role assignment and saves the change
+func (m *Manager) Revoke(uuid, revokedBy string) error {
+	m.mutex.Lock()
+	assignment, exists := m.assignments[strings.ToLower(uuid)]
+	if exists {
+		delete(m.assignments, strings.ToLower(uuid))
+	}
+	m.mutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("no role assigned to %s", uuid)
+	}
+
+	log.Printf("Revoked role %s from %s (%s) by %s", assignment.Role, assignment.Name, uuid, revokedBy)
+	return m.save()
+}
+
+// Assignments returns all role assignments sorted by role and name
+func (m *Manager) Assignments() []*Assignment {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result := make([]*Assignment, 0, len(m.assignments))
+	for _, assignment := range m.assignments {
+		copied := *assignment
+		result = append(result, &copied)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Role != result[j].Role {
+			return result[i].Role > result[j].Role
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// CommandRole returns the role required by a command, honoring config overrides
+func (m *Manager) CommandRole(name string, defaultRole Role) Role {
+	if role, exists := m.commands[strings.ToLower(name)]; exists {
+		return role
+	}
+	return defaultRole
+}
+
+// APIRole returns the role required by an API route, honoring config overrides
+func (m *Manager) APIRole(route string, defaultRole Role) Role {
+	if role, exists := m.apis[route]; exists {
+		return role
+	}
+	return defaultRole
+}
+
+// HasAPIKeys reports whether any API keys are configured
+func (m *Manager) HasAPIKeys() bool {
+	return len(m.apiKeys) > 0
+}
+
+// APIKeyRole returns the role and name of the caller holding an API key
+func (m *Manager) APIKeyRole(token string) (Role, string, bool) {
+	key, exists := m.apiKeys[strings.TrimSpace(token)]
+	if !exists {
+		return Guest, "", false
+	}
+	role, err := Parse(key.Role)
+	if err != nil {
+		return Guest, key.Name, false
+	}
+	return role, key.Name, true
+}
+
+// save writes the granted role assignments to disk
+func (m *Manager) save() error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	granted := make(map[string]*Assignment)
+	for uuid, assignment := range m.assignments {
+		if assignment.GrantedBy != "config" {
+			granted[uuid] = assignment
+		}
+	}
+	return persistant.SaveState(m.config.Roles.Storage, granted)
+}
@@ -0,0 +1,59 @@
+package roles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is a permission level; each role includes everything below it
+type Role int
+
+const (
+	Guest   Role = iota // Anyone, including visitors the bot has never seen
+	Trusted             // Regular visitors allowed a little more
+	Staff               // Venue staff, e.g. may play macros
+	Admin               // May manage macros, auto-greet and bot settings
+	Owner               // Bot owners from the configuration file
+)
+
+var roleNames = []string{"guest", "trusted", "staff", "admin", "owner"}
+
+// String returns the lower-case name of the role
+func (r Role) String() string {
+	if r < Guest || int(r) >= len(roleNames) {
+		return fmt.Sprintf("role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// Parse converts a role name into a Role
+func Parse(name string) (Role, error) {
+	for i, roleName := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(name), roleName) {
+			return Role(i), nil
+		}
+	}
+	return Guest, fmt.Errorf("unknown role '%s' (expected one of %s)", name, strings.Join(roleNames, ", "))
+}
+
+// Names returns the names of all roles from lowest to highest
+func Names() []string {
+	names := make([]string, len(roleNames))
+	copy(names, roleNames)
+	return names
+}
+
+// MarshalText stores a role by name
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText reads a role stored by name
+func (r *Role) UnmarshalText(text []byte) error {
+	role, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*r = role
+	return nil
+}
@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// field is the set of values one part of a cron expression matches
+type field struct {
+	values []bool
+	any    bool // Written as *, which matters for the day fields
+}
+
+// cron is a parsed five-field expression: minute hour day-of-month month
+// day-of-week, matched against SL time
+type cron struct {
+	minute, hour, dom, month, dow field
+}
+
+var monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// shorthands are the usual cron nicknames
+var shorthands = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * sun",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseCron reads an expression such as "0 20 * * fri" or "*/15 18-23 * * mon-fri".
+// Fields accept *, numbers, names of months and days, ranges, lists and
+// /step.
+func parseCron(text string) (*cron, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if expanded, ok := shorthands[text]; ok {
+		text = expanded
+	}
+
+	parts := strings.Fields(text)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("cron '%s' needs five fields: minute hour day month weekday", text)
+	}
+
+	c := &cron{}
+	var err error
+	if c.minute, err = parseField(parts[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseField(parts[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseField(parts[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseField(parts[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseField(parts[4], 0, 7, dayNames); err != nil {
+		return nil, err
+	}
+	// Sunday can be written as 0 or 7
+	c.dow.values[0] = c.dow.values[0] || c.dow.values[7]
+	return c, nil
+}
+
+// parseField reads one comma separated cron field. Names, when given, are
+// numbered from min.
+func parseField(text string, min, max int, names []string) (field, error) {
+	f := field{values: make([]bool, max+1), any: text == "*"}
+
+	for _, part := range strings.Split(text, ",") {
+		span, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return f, fmt.Errorf("invalid step in '%s'", part)
+			}
+		}
+
+		first, last := min, max
+		if span != "*" {
+			from, to, isRange := strings.Cut(span, "-")
+			var err error
+			if first, err = parseValue(from, min, max, names); err != nil {
+				return f, err
+			}
+			last = first
+			if isRange {
+				if last, err = parseValue(to, min, max, names); err != nil {
+					return f, err
+				}
+			} else if hasStep {
+				last = max
+			}
+			if last < first {
+				return f, fmt.Errorf("range '%s' runs backwards", span)
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			f.values[value] = true
+		}
+	}
+	return f, nil
+}
+
+// parseValue reads a number or a name in a cron field
+func parseValue(text string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.HasPrefix(text, name) {
+			return i + min, nil
+		}
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("'%s' should be between %d and %d", text, min, max)
+	}
+	return value, nil
+}
+
+// dayMatches applies the cron rule that when both day fields are
+// restricted a day matching either is enough
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom.values[t.Day()]
+	dow := c.dow.values[int(t.Weekday())]
+	switch {
+	case c.dom.any && c.dow.any:
+		return true
+	case c.dom.any:
+		return dow
+	case c.dow.any:
+		return dom
+	}
+	return dom || dow
+}
+
+// matches reports whether the expression matches the minute of t
+func (c *cron) matches(t time.Time) bool {
+	return c.minute.values[t.Minute()] && c.hour.values[t.Hour()] &&
+		c.month.values[int(t.Month())] && c.dayMatches(t)
+}
+
+// next returns the first minute after t that the expression matches, in
+// t's location, or the zero time if there is none within five years
+func (c *cron) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !c.month.values[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !c.hour.values[t.Hour()]:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !c.minute.values[t.Minute()]:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,205 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// slLocation loads SL time's zone, skipping the test without time zone data
+func slLocation(t *testing.T) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	return location
+}
+
+// setValues lists the values a field matches
+func setValues(f field) []int {
+	var values []int
+	for value, set := range f.values {
+		if set {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func sameValues(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		text     string
+		min, max int
+		names    []string
+		want     []int
+		wantErr  bool
+	}{
+		{text: "5", min: 0, max: 59, want: []int{5}},
+		{text: "*/15", min: 0, max: 59, want: []int{0, 15, 30, 45}},
+		{text: "5/15", min: 0, max: 59, want: []int{5, 20, 35, 50}},
+		{text: "22/1", min: 0, max: 23, want: []int{22, 23}},
+		{text: "10-20/5", min: 0, max: 59, want: []int{10, 15, 20}},
+		{text: "1,3,5", min: 0, max: 59, want: []int{1, 3, 5}},
+		{text: "*/2", min: 1, max: 12, want: []int{1, 3, 5, 7, 9, 11}},
+		{text: "mon-fri", min: 0, max: 7, names: dayNames, want: []int{1, 2, 3, 4, 5}},
+		{text: "thursday", min: 0, max: 7, names: dayNames, want: []int{4}},
+		{text: "jan,dec", min: 1, max: 12, names: monthNames, want: []int{1, 12}},
+		{text: "60", min: 0, max: 59, wantErr: true},
+		{text: "0", min: 1, max: 31, wantErr: true},
+		{text: "*/0", min: 0, max: 59, wantErr: true},
+		{text: "*/x", min: 0, max: 59, wantErr: true},
+		{text: "20-10", min: 0, max: 59, wantErr: true},
+		{text: "noon", min: 0, max: 23, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			f, err := parseField(test.text, test.min, test.max, test.names)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parseField(%q) = %v, want an error", test.text, setValues(f))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseField(%q) returned an error: %v", test.text, err)
+			}
+			if got := setValues(f); !sameValues(got, test.want) {
+				t.Errorf("parseField(%q) = %v, want %v", test.text, got, test.want)
+			}
+			if f.any {
+				t.Errorf("parseField(%q) is marked as *", test.text)
+			}
+		})
+	}
+}
+
+func TestParseFieldAny(t *testing.T) {
+	f, err := parseField("*", 0, 23, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.any || len(setValues(f)) != 24 {
+		t.Errorf("* = %v (any %v), want every hour", setValues(f), f.any)
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		text    string
+		dow     []int
+		wantErr bool
+	}{
+		{text: "0 20 * * fri", dow: []int{5}},
+		{text: "0 9 * * 7", dow: []int{0, 7}},
+		{text: "0 9 * * 0", dow: []int{0}},
+		{text: "0 9 * * 5-7", dow: []int{0, 5, 6, 7}},
+		{text: "0 9 * * SAT,Sun", dow: []int{0, 6}},
+		{text: "@weekly", dow: []int{0}},
+		{text: "@daily", dow: []int{0, 1, 2, 3, 4, 5, 6, 7}},
+		{text: "0 9 * *", wantErr: true},
+		{text: "0 9 * * * *", wantErr: true},
+		{text: "0 24 * * *", wantErr: true},
+		{text: "0 0 32 * *", wantErr: true},
+		{text: "0 0 * 13 *", wantErr: true},
+		{text: "0 0 * * 8", wantErr: true},
+		{text: "fri-sun 0 * * *", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			c, err := parseCron(test.text)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("parseCron(%q) succeeded, want an error", test.text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCron(%q) returned an error: %v", test.text, err)
+			}
+			if got := setValues(c.dow); !sameValues(got, test.dow) {
+				t.Errorf("parseCron(%q) weekdays = %v, want %v", test.text, got, test.dow)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	sl := slLocation(t)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, sl)
+	}
+
+	tests := []struct {
+		name string
+		cron string
+		from time.Time
+		want time.Time
+	}{
+		{"weekday later in the week", "0 20 * * fri", at(2026, 10, 14, 12, 0), at(2026, 10, 16, 20, 0)},
+		{"same minute moves on", "0 20 * * fri", at(2026, 10, 16, 20, 0), at(2026, 10, 23, 20, 0)},
+		{"seconds are ignored", "0 20 * * fri", at(2026, 10, 16, 19, 59).Add(30 * time.Second), at(2026, 10, 16, 20, 0)},
+		{"sunday as 7", "0 9 * * 7", at(2026, 10, 17, 10, 0), at(2026, 10, 18, 9, 0)},
+		{"steps roll over to the next day", "*/15 18-23 * * *", at(2026, 10, 14, 23, 50), at(2026, 10, 15, 18, 0)},
+		{"step from a single value", "5/20 * * * *", at(2026, 10, 14, 12, 6), at(2026, 10, 14, 12, 25)},
+		{"day of month", "0 12 1,15 * *", at(2026, 6, 2, 0, 0), at(2026, 6, 15, 12, 0)},
+		{"day of month or weekday", "0 12 1,15 * mon", at(2026, 6, 2, 0, 0), at(2026, 6, 8, 12, 0)},
+		{"month rolls over the year", "0 0 1 jan *", at(2026, 12, 30, 0, 0), at(2027, 1, 1, 0, 0)},
+		{"leap day", "0 0 29 2 *", at(2026, 3, 1, 0, 0), at(2028, 2, 29, 0, 0)},
+		{"never", "0 0 31 2 *", at(2026, 1, 1, 0, 0), time.Time{}},
+		{"clock reading kept when DST starts", "0 9 * * *", at(2026, 3, 7, 10, 0), at(2026, 3, 8, 9, 0)},
+		{"clock reading kept when DST ends", "0 9 * * *", at(2026, 10, 31, 10, 0), at(2026, 11, 1, 9, 0)},
+		{"skipped hour when DST starts", "30 2 * * *", at(2026, 3, 8, 0, 0), at(2026, 3, 9, 2, 30)},
+		{"hourly across DST starting", "0 * * * *", at(2026, 3, 8, 1, 30), at(2026, 3, 8, 3, 0)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := parseCron(test.cron)
+			if err != nil {
+				t.Fatalf("parseCron(%q) returned an error: %v", test.cron, err)
+			}
+			got := c.next(test.from)
+			if !got.Equal(test.want) {
+				t.Errorf("next(%q) from %v = %v, want %v", test.cron, test.from, got, test.want)
+			}
+			if !got.IsZero() && got.Location() != sl {
+				t.Errorf("next(%q) is in %v, want SL time", test.cron, got.Location())
+			}
+		})
+	}
+}
+
+func TestCronMatchesAcrossDST(t *testing.T) {
+	sl := slLocation(t)
+	c, err := parseCron("0 9 * * sun")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 9am on the Sundays the clocks change, which are 23 and 25 hours long
+	for _, day := range []time.Time{
+		time.Date(2026, 3, 8, 9, 0, 0, 0, sl),
+		time.Date(2026, 11, 1, 9, 0, 0, 0, sl),
+	} {
+		if !c.matches(day) {
+			t.Errorf("%v doesn't match", day)
+		}
+		if c.matches(day.Add(time.Hour)) || c.matches(day.Add(-time.Hour)) {
+			t.Errorf("an hour either side of %v matches", day)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+	"slbot/internal/slfunc"
+)
+
+// Actions a task can run
+const (
+	ActionSay       = "say"
+	ActionIM        = "im"
+	ActionMacro     = "macro"
+	ActionTeleport  = "teleport"
+	ActionAutoGreet = "autogreet"
+)
+
+// task is a scheduled task with its parsed schedule and last run
+type task struct {
+	spec       config.ScheduledTask
+	cron       *cron
+	fromConfig bool
+	edited     bool      // Added or changed at runtime, so saved
+	lastMinute time.Time // SL minute it last came due
+	lastRun    time.Time
+	lastResult string
+}
+
+// Info describes a task for the web interface
+type Info struct {
+	config.ScheduledTask
+	FromConfig bool       `json:"fromConfig"`
+	NextRun    *time.Time `json:"nextRun,omitempty"` // In SL time
+	LastRun    *time.Time `json:"lastRun,omitempty"`
+	LastResult string     `json:"lastResult,omitempty"`
+}
+
+// state is what was changed at runtime, saved across restarts
+type state struct {
+	Tasks   []config.ScheduledTask `json:"tasks"`   // Added, or configured tasks that were edited
+	Removed []string               `json:"removed"` // Configured tasks that were removed
+}
+
+// Manager keeps the scheduled tasks and works out when they are due
+type Manager struct {
+	config  config.SchedulesConfig
+	tasks   map[string]*task // Lower-case name -> task
+	removed map[string]bool
+	mutex   sync.RWMutex
+}
+
+// NewManager creates a scheduler from the configured tasks and the changes
+// made at runtime
+func NewManager(cfg *config.Config) *Manager {
+	manager := &Manager{
+		config:  cfg.Schedules,
+		tasks:   make(map[string]*task),
+		removed: make(map[string]bool),
+	}
+
+	for _, spec := range cfg.Schedules.Tasks {
+		t, err := newTask(spec)
+		if err != nil {
+			log.Printf("Skipping scheduled task: %v", err)
+			continue
+		}
+		t.fromConfig = true
+		manager.tasks[strings.ToLower(spec.Name)] = t
+	}
+
+	var saved state
+	if err := persistant.LoadState(cfg.Schedules.Storage, &saved); err != nil {
+		log.Printf("No schedule changes loaded from %s: %v", cfg.Schedules.Storage, err)
+	}
+	for _, name := range saved.Removed {
+		manager.removed[strings.ToLower(name)] = true
+		delete(manager.tasks, strings.ToLower(name))
+	}
+	for _, spec := range saved.Tasks {
+		t, err := newTask(spec)
+		if err != nil {
+			log.Printf("Skipping saved scheduled task: %v", err)
+			continue
+		}
+		if existing, exists := manager.tasks[strings.ToLower(spec.Name)]; exists {
+			t.fromConfig = existing.fromConfig
+		}
+		t.edited = true
+		manager.tasks[strings.ToLower(spec.Name)] = t
+	}
+
+	return manager
+}
+
+// newTask checks a task and parses its schedule
+func newTask(spec config.ScheduledTask) (*task, error) {
+	spec.Name = strings.TrimSpace(spec.Name)
+	spec.Action = strings.ToLower(strings.TrimSpace(spec.Action))
+	spec.Value = strings.TrimSpace(spec.Value)
+
+	if spec.Name == "" {
+		return nil, fmt.Errorf("a task needs a name")
+	}
+
+	switch spec.Action {
+	case ActionSay, ActionMacro, ActionTeleport:
+		if spec.Value == "" {
+			return nil, fmt.Errorf("task %s: %s needs a value", spec.Name, spec.Action)
+		}
+	case ActionIM:
+		if spec.Value == "" || strings.TrimSpace(spec.To) == "" {
+			return nil, fmt.Errorf("task %s: im needs a message and recipients in 'to'", spec.Name)
+		}
+	case ActionAutoGreet:
+		if value := strings.ToLower(spec.Value); value != "on" && value != "off" {
+			return nil, fmt.Errorf("task %s: autogreet needs on or off", spec.Name)
+		}
+	default:
+		return nil, fmt.Errorf("task %s: unknown action '%s'", spec.Name, spec.Action)
+	}
+
+	parsed, err := parseCron(spec.Cron)
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %v", spec.Name, err)
+	}
+	return &task{spec: spec, cron: parsed}, nil
+}
+
+// Due returns the enabled tasks whose schedule matches the current SL
+// minute, each only once per minute
+func (m *Manager) Due(now time.Time) []config.ScheduledTask {
+	minute := slfunc.SLTime(now).Truncate(time.Minute)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var due []config.ScheduledTask
+	for _, t := range m.tasks {
+		if t.spec.Disabled || !t.cron.matches(minute) || t.lastMinute.Equal(minute) {
+			continue
+		}
+		t.lastMinute = minute
+		due = append(due, t.spec)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Name < due[j].Name })
+	return due
+}
+
+// Record remembers the outcome of running a task
+func (m *Manager) Record(name, result string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if t, exists := m.tasks[strings.ToLower(name)]; exists {
+		t.lastRun = time.Now()
+		t.lastResult = result
+	}
+}
+
+// List returns the tasks sorted by their next run, disabled tasks last
+func (m *Manager) List(now time.Time) []Info {
+	slNow := slfunc.SLTime(now)
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	list := make([]Info, 0, len(m.tasks))
+	for _, t := range m.tasks {
+		info := Info{ScheduledTask: t.spec, FromConfig: t.fromConfig, LastResult: t.lastResult}
+		if !t.spec.Disabled {
+			if next := t.cron.next(slNow); !next.IsZero() {
+				info.NextRun = &next
+			}
+		}
+		if !t.lastRun.IsZero() {
+			lastRun := slfunc.SLTime(t.lastRun)
+			info.LastRun = &lastRun
+		}
+		list = append(list, info)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].NextRun, list[j].NextRun
+		switch {
+		case a == nil || b == nil:
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+		case !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Save adds a task or replaces the one with the same name
+func (m *Manager) Save(spec config.ScheduledTask, savedBy string) error {
+	t, err := newTask(spec)
+	if err != nil {
+		return err
+	}
+
+	key := strings.ToLower(t.spec.Name)
+	m.mutex.Lock()
+	if existing, exists := m.tasks[key]; exists {
+		t.fromConfig = existing.fromConfig
+		t.lastMinute = existing.lastMinute
+		t.lastRun = existing.lastRun
+		t.lastResult = existing.lastResult
+	}
+	t.edited = true
+	m.tasks[key] = t
+	delete(m.removed, key)
+	m.mutex.Unlock()
+
+	log.Printf("%s saved scheduled task %s (%s %s)", savedBy, t.spec.Name, t.spec.Cron, t.spec.Action)
+	return m.save()
+}
+
+// Remove deletes a task
+func (m *Manager) Remove(name, removedBy string) error {
+	key := strings.ToLower(strings.TrimSpace(name))
+
+	m.mutex.Lock()
+	t, exists := m.tasks[key]
+	if exists {
+		delete(m.tasks, key)
+		if t.fromConfig {
+			m.removed[key] = true
+		}
+	}
+	m.mutex.Unlock()
+
+	if !exists {
+		return fmt.Errorf("no scheduled task named %s", name)
+	}
+
+	log.Printf("%s removed scheduled task %s", removedBy, t.spec.Name)
+	return m.save()
+}
+
+// save writes the tasks changed at runtime to disk
+func (m *Manager) save() error {
+	m.mutex.RLock()
+	saved := state{Tasks: []config.ScheduledTask{}, Removed: []string{}}
+	for _, t := range m.tasks {
+		if t.edited {
+			saved.Tasks = append(saved.Tasks, t.spec)
+		}
+	}
+	for name := range m.removed {
+		saved.Removed = append(saved.Removed, name)
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(saved.Tasks, func(i, j int) bool { return saved.Tasks[i].Name < saved.Tasks[j].Name })
+	sort.Strings(saved.Removed)
+	return persistant.SaveState(m.config.Storage, saved)
+}
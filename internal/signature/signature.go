@@ -0,0 +1,75 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sign returns the HMAC-SHA256 of a text with a shared secret
+func Sign(secret, text string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(text))
+	return mac.Sum(nil)
+}
+
+// Hex returns the HMAC-SHA256 of a text as lower-case hex
+func Hex(secret, text string) string {
+	return hex.EncodeToString(Sign(secret, text))
+}
+
+// Check reports whether a hex or base64 signature (as made by LSL's
+// llHMAC) is the HMAC-SHA256 of a text with the secret
+func Check(secret, text, given string) bool {
+	var decoded []byte
+	var err error
+	if len(given) == hex.EncodedLen(sha256.Size) {
+		decoded, err = hex.DecodeString(given)
+	} else {
+		decoded, err = base64.StdEncoding.DecodeString(given)
+	}
+	return err == nil && hmac.Equal(decoded, Sign(secret, text))
+}
+
+// Guard rejects signed requests that are too old or have been seen before
+type Guard struct {
+	window time.Duration
+	seen   map[string]time.Time
+	mutex  sync.Mutex
+}
+
+// NewGuard creates a guard accepting timestamps within window of now
+func NewGuard(window time.Duration) *Guard {
+	return &Guard{window: window, seen: make(map[string]time.Time)}
+}
+
+// Check accepts a request's Unix timestamp and signature once
+func (g *Guard) Check(timestamp, sig string, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid timestamp")
+	}
+	sent := time.Unix(ts, 0)
+	if now.Sub(sent) > g.window || sent.Sub(now) > g.window {
+		return fmt.Errorf("request expired")
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for key, at := range g.seen {
+		if now.Sub(at) > 2*g.window {
+			delete(g.seen, key)
+		}
+	}
+	if _, replayed := g.seen[sig]; replayed {
+		return fmt.Errorf("request already used")
+	}
+	g.seen[sig] = now
+	return nil
+}
@@ -0,0 +1,91 @@
+package signature
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	const secret, text = "change-me", "1700000000\n{\"avatar\":\"Jane Doe\"}"
+	valid := Sign(secret, text)
+
+	tests := []struct {
+		name  string
+		text  string
+		given string
+		want  bool
+	}{
+		{name: "hex", text: text, given: Hex(secret, text), want: true},
+		{name: "upper-case hex", text: text, given: strings.ToUpper(Hex(secret, text)), want: true},
+		{name: "base64", text: text, given: base64.StdEncoding.EncodeToString(valid), want: true},
+		{name: "tampered body", text: strings.Replace(text, "Jane", "John", 1), given: Hex(secret, text)},
+		{name: "tampered timestamp", text: "1700000001" + text[10:], given: Hex(secret, text)},
+		{name: "other secret", text: text, given: Hex("guessed", text)},
+		{name: "truncated", text: text, given: Hex(secret, text)[:32]},
+		{name: "not encoded", text: text, given: "not a signature"},
+		{name: "empty", text: text, given: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Check(secret, test.text, test.given); got != test.want {
+				t.Errorf("Check = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGuardCheck(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	unix := func(offset time.Duration) string { return strconv.FormatInt(now.Add(offset).Unix(), 10) }
+
+	tests := []struct {
+		name      string
+		timestamp string
+		wantErr   string
+	}{
+		{name: "now", timestamp: unix(0)},
+		{name: "just inside the window", timestamp: unix(-120 * time.Second)},
+		{name: "slightly ahead", timestamp: unix(30 * time.Second)},
+		{name: "too old", timestamp: unix(-121 * time.Second), wantErr: "request expired"},
+		{name: "too far ahead", timestamp: unix(121 * time.Second), wantErr: "request expired"},
+		{name: "missing", timestamp: "", wantErr: "missing or invalid timestamp"},
+		{name: "not a number", timestamp: "yesterday", wantErr: "missing or invalid timestamp"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := NewGuard(120*time.Second).Check(test.timestamp, "sig-"+test.name, now)
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Errorf("Check returned an error: %v", err)
+			case test.wantErr != "" && (err == nil || err.Error() != test.wantErr):
+				t.Errorf("Check = %v, want %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestGuardRejectsReplays(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	guard := NewGuard(120 * time.Second)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	if err := guard.Check(timestamp, "first", now); err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	if err := guard.Check(timestamp, "first", now.Add(10*time.Second)); err == nil || err.Error() != "request already used" {
+		t.Errorf("replay = %v, want request already used", err)
+	}
+	if err := guard.Check(timestamp, "second", now.Add(10*time.Second)); err != nil {
+		t.Errorf("another request with the same timestamp rejected: %v", err)
+	}
+
+	// Once the timestamp has expired the replay is refused as too old
+	if err := guard.Check(timestamp, "first", now.Add(5*time.Minute)); err == nil || err.Error() != "request expired" {
+		t.Errorf("late replay = %v, want request expired", err)
+	}
+}
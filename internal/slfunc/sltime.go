@@ -0,0 +1,25 @@
+package slfunc
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	slLocation     *time.Location
+	slLocationOnce sync.Once
+)
+
+// SLTime converts a time to Second Life time (US Pacific)
+func SLTime(t time.Time) time.Time {
+	slLocationOnce.Do(func() {
+		location, err := time.LoadLocation("America/Los_Angeles")
+		if err != nil {
+			log.Printf("Time zone data unavailable, using UTC-8 for SL time: %v", err)
+			location = time.FixedZone("PST", -8*60*60)
+		}
+		slLocation = location
+	})
+	return t.In(slLocation)
+}
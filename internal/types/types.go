@@ -60,6 +60,7 @@ type LogEntry struct {
 	Avatar    string    `json:"avatar"`
 	Message   string    `json:"message"`
 	Response  string    `json:"response,omitempty"`
+	Intent    string    `json:"intent,omitempty"` // Classified intent of a chat message, e.g. "question (0.72)"
 }
 
 // TeleportRequest represents a teleport request from web interface
@@ -104,7 +105,7 @@ type MacroAction struct {
 type Macro struct {
 	Name         string        `json:"name"`
 	Description  string        `json:"description"`
-   Region       string        `json:"region"`
+	Region       string        `json:"region"`
 	Actions      []MacroAction `json:"actions"`
 	CreatedBy    string        `json:"createdBy"`
 	CreatedAt    time.Time     `json:"createdAt"`
@@ -117,28 +118,43 @@ type Macro struct {
 // MacroRecording represents an active recording session
 type MacroRecording struct {
 	Name        string        `json:"name"`
-   Region      string        `json:"region"`
+	Region      string        `json:"region"`
 	StartTime   time.Time     `json:"startTime"`
 	Actions     []MacroAction `json:"actions"`
 	RecordedBy  string        `json:"recordedBy"`
 	IsRecording bool          `json:"isRecording"`
 }
 
-// LlamaRequest represents request to Llama API
-type LlamaRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
-}
-
-// LlamaResponse represents response from Llama API
-type LlamaResponse struct {
-	Response string `json:"response"`
-	Done     bool   `json:"done"`
-}
-
 // AutoGreetRequest represents an auto-greet configuration request
 type AutoGreetRequest struct {
 	Enabled   bool   `json:"enabled"`
 	MacroName string `json:"macroName,omitempty"`
 }
+
+// RoleRequest represents a role grant request from the web interface
+type RoleRequest struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// PersonaRequest represents a persona switch from the web interface
+type PersonaRequest struct {
+	Name string `json:"name"`
+}
+
+// LSLObjectRequest represents an in-world object registration from the web interface
+type LSLObjectRequest struct {
+	UUID   string   `json:"uuid"`
+	Name   string   `json:"name"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events,omitempty"`
+}
+
+// EventRequest represents a new calendar event from the web interface. The
+// start is either given as a time or as SL time text like "fri 8pm" in When.
+type EventRequest struct {
+	Title string     `json:"title"`
+	When  string     `json:"when,omitempty"`
+	Start *time.Time `json:"start,omitempty"`
+}
@@ -0,0 +1,264 @@
+package visitors
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/persistant"
+	"slbot/internal/types"
+)
+
+// saveInterval is how often last-seen times are written while nobody arrives or leaves
+const saveInterval = time.Minute
+
+// Visit is one stay in a region
+type Visit struct {
+	Arrived time.Time `json:"arrived"`
+	Left    time.Time `json:"left"`
+	Region  string    `json:"region"`
+	Seconds int64     `json:"seconds"`
+}
+
+// Visitor is everything remembered about an avatar who has been seen
+type Visitor struct {
+	UUID         string    `json:"uuid"`
+	Name         string    `json:"name"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Visits       int       `json:"visits"`
+	TotalSeconds int64     `json:"totalSeconds"`
+	Regions      []string  `json:"regions"`
+	History      []Visit   `json:"history"`           // Most recent visits, oldest first
+	Current      *Visit    `json:"current,omitempty"` // Set while the avatar is here
+}
+
+// AverageSeconds returns the average length of a finished visit
+func (v *Visitor) AverageSeconds() int64 {
+	finished := v.Visits
+	if v.Current != nil {
+		finished--
+	}
+	if finished <= 0 {
+		return 0
+	}
+	return v.TotalSeconds / int64(finished)
+}
+
+// Registry remembers every avatar the bot has seen, keyed by UUID
+type Registry struct {
+	config   config.VisitorsConfig
+	visitors map[string]*Visitor
+	lastSave time.Time
+	mutex    sync.RWMutex
+}
+
+// NewRegistry creates a registry and loads the saved visitors
+func NewRegistry(cfg *config.Config) *Registry {
+	registry := &Registry{
+		config:   cfg.Visitors,
+		visitors: make(map[string]*Visitor),
+	}
+
+	if err := persistant.LoadState(cfg.Visitors.Storage, &registry.visitors); err != nil {
+		log.Printf("No visitors loaded from %s: %v", cfg.Visitors.Storage, err)
+	}
+	if registry.visitors == nil {
+		registry.visitors = make(map[string]*Visitor)
+	}
+
+	// Visits still open when the bot stopped ended when the avatar was last seen
+	for _, visitor := range registry.visitors {
+		registry.finish(visitor)
+	}
+
+	return registry
+}
+
+// Seen records a scan of the avatars in a region: avatars not already here
+// start a visit, and those missing from the scan finish theirs
+func (r *Registry) Seen(avatars map[string]*types.AvatarInfo, region string, now time.Time) {
+	r.mutex.Lock()
+	changed := false
+
+	here := make(map[string]bool, len(avatars))
+	for _, avatar := range avatars {
+		uuid := strings.ToLower(avatar.UUID)
+		if uuid == "" {
+			continue
+		}
+		here[uuid] = true
+
+		lastSeen := avatar.LastSeen
+		if lastSeen.IsZero() {
+			lastSeen = now
+		}
+
+		visitor, known := r.visitors[uuid]
+		if !known {
+			visitor = &Visitor{UUID: uuid, FirstSeen: lastSeen}
+			r.visitors[uuid] = visitor
+		}
+		visitor.Name = avatar.Name
+		if lastSeen.After(visitor.LastSeen) {
+			visitor.LastSeen = lastSeen
+		}
+
+		if visitor.Current != nil && visitor.Current.Region != region {
+			r.finish(visitor)
+		}
+		if visitor.Current == nil {
+			visitor.Visits++
+			visitor.Current = &Visit{Arrived: lastSeen, Region: region}
+			visitor.addRegion(region)
+			changed = true
+		}
+	}
+
+	for uuid, visitor := range r.visitors {
+		if visitor.Current != nil && !here[uuid] {
+			r.finish(visitor)
+			changed = true
+		}
+	}
+
+	if !changed && now.Sub(r.lastSave) < saveInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.lastSave = now
+	r.mutex.Unlock()
+
+	if err := r.save(); err != nil {
+		log.Printf("Failed to save visitors: %v", err)
+	}
+}
+
+// finish closes a visitor's current visit at the time they were last seen.
+// The caller must hold the lock.
+func (r *Registry) finish(visitor *Visitor) {
+	visit := visitor.Current
+	if visit == nil {
+		return
+	}
+	visitor.Current = nil
+
+	visit.Left = visitor.LastSeen
+	if visit.Left.Before(visit.Arrived) {
+		visit.Left = visit.Arrived
+	}
+	visit.Seconds = int64(visit.Left.Sub(visit.Arrived).Seconds())
+	visitor.TotalSeconds += visit.Seconds
+
+	visitor.History = append(visitor.History, *visit)
+	if len(visitor.History) > r.config.History {
+		visitor.History = visitor.History[len(visitor.History)-r.config.History:]
+	}
+}
+
+// addRegion remembers a region the visitor has been seen in
+func (v *Visitor) addRegion(region string) {
+	if region == "" {
+		return
+	}
+	for _, known := range v.Regions {
+		if strings.EqualFold(known, region) {
+			return
+		}
+	}
+	v.Regions = append(v.Regions, region)
+}
+
+// Get returns a visitor by UUID
+func (r *Registry) Get(uuid string) (*Visitor, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	visitor, exists := r.visitors[strings.ToLower(uuid)]
+	if !exists {
+		return nil, false
+	}
+	return copyVisitor(visitor), true
+}
+
+// FindByName returns the visitor with a full name, ignoring a "Resident"
+// last name, or the only visitor with that first name
+func (r *Registry) FindByName(name string) (*Visitor, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.Join(strings.Fields(name), " ")), " resident")
+	if name == "" {
+		return nil, false
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var match *Visitor
+	for _, visitor := range r.visitors {
+		full := strings.TrimSuffix(strings.ToLower(visitor.Name), " resident")
+		if full == name {
+			return copyVisitor(visitor), true
+		}
+		if first, _, _ := strings.Cut(full, " "); first == name {
+			if match != nil {
+				return nil, false
+			}
+			match = visitor
+		}
+	}
+	if match == nil {
+		return nil, false
+	}
+	return copyVisitor(match), true
+}
+
+// Search returns visitors whose name or UUID contains the query, most
+// recently seen first. An empty query matches everyone.
+func (r *Registry) Search(query string, limit int) []*Visitor {
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	r.mutex.RLock()
+	found := make([]*Visitor, 0)
+	for uuid, visitor := range r.visitors {
+		if query == "" || strings.Contains(strings.ToLower(visitor.Name), query) || strings.HasPrefix(uuid, query) {
+			found = append(found, copyVisitor(visitor))
+		}
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool { return found[i].LastSeen.After(found[j].LastSeen) })
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// Count returns how many visitors have been seen
+func (r *Registry) Count() int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return len(r.visitors)
+}
+
+// copyVisitor copies a visitor so callers can't change the registry
+func copyVisitor(visitor *Visitor) *Visitor {
+	copied := *visitor
+	copied.Regions = append([]string(nil), visitor.Regions...)
+	copied.History = append([]Visit(nil), visitor.History...)
+	if visitor.Current != nil {
+		current := *visitor.Current
+		copied.Current = &current
+	}
+	return &copied
+}
+
+// save writes the registry to disk
+func (r *Registry) save() error {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return persistant.SaveState(r.config.Storage, r.visitors)
+}
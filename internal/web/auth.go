@@ -0,0 +1,159 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"slbot/internal/roles"
+)
+
+// apiKeyCookie remembers the API key given to the dashboard in ?key=
+const apiKeyCookie = "slbot_key"
+
+type contextKey string
+
+const callerContextKey contextKey = "caller"
+
+// defaultRouteRoles lists the role each route requires unless overridden in
+// the configuration. Routes missing from this table require the admin role.
+var defaultRouteRoles = map[string]roles.Role{
+	"GET /":                               roles.Staff,
+	"GET /static/":                        roles.Guest,
+	"POST /corrade/notifications":         roles.Guest, // Corrade proves itself with the callback token
+	"GET /api/status":                     roles.Guest,
+	"GET /api/system":                     roles.Guest,
+	"GET /api/build":                      roles.Guest,
+	"GET /api/logs":                       roles.Staff,
+	"POST /api/teleport":                  roles.Staff,
+	"POST /api/walk":                      roles.Staff,
+	"POST /api/stop-following":            roles.Staff,
+	"POST /api/stand":                     roles.Staff,
+	"POST /api/toggle-llama":              roles.Admin,
+	"GET /api/avatars":                    roles.Staff,
+	"GET /api/autogreet":                  roles.Staff,
+	"POST /api/autogreet":                 roles.Admin,
+	"DELETE /api/autogreet":               roles.Admin,
+	"GET /api/macros":                     roles.Staff,
+	"POST /api/macros/play/{name}":        roles.Staff,
+	"DELETE /api/macros/delete/{name}":    roles.Admin,
+	"GET /api/macros/recording":           roles.Staff,
+	"POST /api/macros/idle/{name}":        roles.Admin,
+	"DELETE /api/macros/idle/{name}":      roles.Admin,
+	"POST /api/macros/autogreet/{name}":   roles.Admin,
+	"DELETE /api/macros/autogreet/{name}": roles.Admin,
+	"GET /api/notecards":                  roles.Staff,
+	"POST /api/notecards/reload":          roles.Admin,
+	"GET /api/roles":                      roles.Admin,
+	"POST /api/roles":                     roles.Owner,
+	"DELETE /api/roles/{uuid}":            roles.Owner,
+	"GET /api/conversations":              roles.Staff,
+	"DELETE /api/conversations":           roles.Admin,
+	"DELETE /api/conversations/{uuid}":    roles.Staff,
+	"GET /api/knowledge":                  roles.Staff,
+	"GET /api/knowledge/search":           roles.Staff,
+	"POST /api/knowledge/reindex":         roles.Admin,
+	"GET /api/ratelimit":                  roles.Staff,
+	"GET /api/languages":                  roles.Staff,
+	"GET /api/persona":                    roles.Staff,
+	"POST /api/persona":                   roles.Owner,
+	"DELETE /api/ratelimit/{uuid}":        roles.Admin,
+	"GET /api/schedules":                  roles.Staff,
+	"POST /api/schedules":                 roles.Admin,
+	"DELETE /api/schedules/{name}":        roles.Admin,
+	"GET /api/events":                     roles.Guest,
+	"POST /api/events":                    roles.Staff,
+	"DELETE /api/events/{id}":             roles.Staff,
+	"GET /api/webhooks":                   roles.Admin,
+	"POST /api/webhooks/{name}/test":      roles.Admin,
+	"POST /api/bridge/messages":           roles.Guest,
+	"GET /api/visitors":                   roles.Staff,
+	"POST /api/lsl/event/{name}":          roles.Guest,
+	"GET /api/lsl/objects":                roles.Admin,
+	"POST /api/lsl/objects":               roles.Owner,
+	"DELETE /api/lsl/objects/{uuid}":      roles.Owner,
+}
+
+// authMiddleware rejects requests from callers without the role a route requires
+func (w *Interface) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		route := routeKey(request)
+		required, known := defaultRouteRoles[route]
+		if !known {
+			required = roles.Admin
+		}
+		required = w.chatProcessor.GetRoleManager().APIRole(route, required)
+
+		role, caller := w.callerRole(request)
+		if role < required {
+			log.Printf("Denied %s to %s (%s, needs %s)", route, caller, role, required)
+			writer.Header().Set("Content-Type", "application/json")
+			writer.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(writer).Encode(map[string]string{
+				"status":  "error",
+				"message": fmt.Sprintf("This requires the %s role", required),
+			})
+			return
+		}
+
+		// Let the dashboard keep using a key it was opened with
+		if key := request.URL.Query().Get("key"); key != "" {
+			http.SetCookie(writer, &http.Cookie{Name: apiKeyCookie, Value: key, Path: "/", HttpOnly: true})
+		}
+
+		ctx := context.WithValue(request.Context(), callerContextKey, caller)
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+// callerRole returns the role and name of the caller making a request
+func (w *Interface) callerRole(request *http.Request) (roles.Role, string) {
+	roleManager := w.chatProcessor.GetRoleManager()
+
+	// Without API keys the web interface is trusted as before
+	if !roleManager.HasAPIKeys() {
+		return roles.Owner, "WebInterface"
+	}
+
+	token := request.Header.Get("X-API-Key")
+	if token == "" {
+		token = strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		if cookie, err := request.Cookie(apiKeyCookie); err == nil {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		token = request.URL.Query().Get("key")
+	}
+
+	if role, name, ok := roleManager.APIKeyRole(token); ok {
+		return role, "WebInterface:" + name
+	}
+	return roles.Guest, "WebGuest"
+}
+
+// requestor returns the name of the caller for macro and log records
+func requestor(request *http.Request) string {
+	if caller, ok := request.Context().Value(callerContextKey).(string); ok {
+		return caller
+	}
+	return "WebInterface"
+}
+
+// routeKey identifies the matched route as "METHOD /path/template"
+func routeKey(request *http.Request) string {
+	path := request.URL.Path
+	if route := mux.CurrentRoute(request); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+	return request.Method + " " + path
+}
@@ -2,10 +2,12 @@ package web
 
 import (
 	"context"
+	"crypto/subtle"
    "strings"
 	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -15,10 +17,20 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"slbot/internal/bridge"
+	"slbot/internal/calendar"
 	"slbot/internal/chat"
 	"slbot/internal/config"
+	"slbot/internal/conversation"
 	"slbot/internal/corrade"
+	"slbot/internal/lsl"
+	"slbot/internal/persona"
+	"slbot/internal/queue"
+	"slbot/internal/roles"
+	"slbot/internal/scheduler"
 	"slbot/internal/types"
+	"slbot/internal/visitors"
+	"slbot/internal/webhook"
 )
 
 // BuildInfo holds build-time information
@@ -41,6 +53,7 @@ type SystemInfo struct {
 	Uptime       time.Duration
 	OS           string
 	Arch         string
+	Queue        queue.Stats
 }
 
 // Interface handles the web dashboard
@@ -58,7 +71,7 @@ type Interface struct {
 // Updated NewInterface function
 func NewInterface(cfg *config.Config, corradeClient *corrade.Client, chatProcessor *chat.Processor) *Interface {
 	// Construct callback URL based on web port
-	callbackURL := fmt.Sprintf("http://localhost:%d/corrade/notifications", cfg.Bot.WebPort)
+	callbackURL := cfg.NotificationURL()
 	
 	return &Interface{
 		config:        cfg,
@@ -111,6 +124,7 @@ func (w *Interface) Start(ctx context.Context) error {
 
 	// Setup routes
 	router := mux.NewRouter()
+	router.Use(w.authMiddleware)
 
 	// Static files
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir("web/static/"))))
@@ -150,6 +164,62 @@ func (w *Interface) Start(ctx context.Context) error {
 	macroAPI.HandleFunc("/autogreet/{name}", w.setAutoGreetMacroHandler).Methods("POST")
 	macroAPI.HandleFunc("/autogreet/{name}", w.unsetAutoGreetMacroHandler).Methods("DELETE")
 
+	// Notecard API endpoints
+	api.HandleFunc("/notecards", w.getNotecardsHandler).Methods("GET")
+	api.HandleFunc("/notecards/reload", w.reloadNotecardsHandler).Methods("POST")
+
+	// Role API endpoints
+	api.HandleFunc("/roles", w.getRolesHandler).Methods("GET")
+	api.HandleFunc("/roles", w.grantRoleHandler).Methods("POST")
+	api.HandleFunc("/roles/{uuid}", w.revokeRoleHandler).Methods("DELETE")
+
+	// Conversation API endpoints
+	api.HandleFunc("/conversations", w.getConversationsHandler).Methods("GET")
+	api.HandleFunc("/conversations", w.clearConversationsHandler).Methods("DELETE")
+	api.HandleFunc("/conversations/{uuid}", w.clearConversationHandler).Methods("DELETE")
+
+	// Knowledge base API endpoints
+	api.HandleFunc("/knowledge", w.getKnowledgeHandler).Methods("GET")
+	api.HandleFunc("/knowledge/search", w.searchKnowledgeHandler).Methods("GET")
+	api.HandleFunc("/knowledge/reindex", w.reindexKnowledgeHandler).Methods("POST")
+
+	// Rate limit API endpoints
+	api.HandleFunc("/ratelimit", w.getRateLimitHandler).Methods("GET")
+	api.HandleFunc("/ratelimit/{uuid}", w.unignoreHandler).Methods("DELETE")
+
+	// Language API endpoints
+	api.HandleFunc("/languages", w.getLanguagesHandler).Methods("GET")
+
+	// Persona API endpoints
+	api.HandleFunc("/persona", w.getPersonaHandler).Methods("GET")
+	api.HandleFunc("/persona", w.setPersonaHandler).Methods("POST")
+
+	// Schedule API endpoints
+	api.HandleFunc("/schedules", w.getSchedulesHandler).Methods("GET")
+	api.HandleFunc("/schedules", w.saveScheduleHandler).Methods("POST")
+	api.HandleFunc("/schedules/{name}", w.removeScheduleHandler).Methods("DELETE")
+
+	// Event calendar API endpoints
+	api.HandleFunc("/events", w.getEventsHandler).Methods("GET")
+	api.HandleFunc("/events", w.addEventHandler).Methods("POST")
+	api.HandleFunc("/events/{id}", w.cancelEventHandler).Methods("DELETE")
+
+	// Webhook API endpoints
+	api.HandleFunc("/webhooks", w.getWebhooksHandler).Methods("GET")
+	api.HandleFunc("/webhooks/{name}/test", w.testWebhookHandler).Methods("POST")
+
+	// Visitor API endpoints
+	api.HandleFunc("/visitors", w.getVisitorsHandler).Methods("GET")
+
+	// Chat bridge API endpoints
+	api.HandleFunc("/bridge/messages", w.bridgeMessageHandler).Methods("POST")
+
+	// In-world script API endpoints
+	api.HandleFunc("/lsl/event/{name}", w.lslEventHandler).Methods("POST")
+	api.HandleFunc("/lsl/objects", w.getLSLObjectsHandler).Methods("GET")
+	api.HandleFunc("/lsl/objects", w.registerLSLObjectHandler).Methods("POST")
+	api.HandleFunc("/lsl/objects/{uuid}", w.removeLSLObjectHandler).Methods("DELETE")
+
 	// Create server
 	w.server = &http.Server{
 		Addr:    fmt.Sprintf(":%d", w.config.Bot.WebPort),
@@ -212,6 +282,16 @@ func (w *Interface) avatarTrackingRoutine(ctx context.Context) {
 func (w *Interface) corradeNotificationHandler(writer http.ResponseWriter, request *http.Request) {
 	var notification map[string]interface{}
 
+	// Only Corrade knows the token in the URL it was given
+	token := request.URL.Query().Get("token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(w.config.Bot.CallbackToken)) != 1 {
+		log.Printf("Rejected a Corrade notification from %s without the callback token", request.RemoteAddr)
+		writer.Header().Set("Content-Type", "application/json")
+		writer.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": "Invalid callback token"})
+		return
+	}
+
    //printHTTPRequest(request)
 
 	// Check content type to handle both JSON and form-encoded data
@@ -234,7 +314,7 @@ func (w *Interface) corradeNotificationHandler(writer http.ResponseWriter, reque
 		
 		// Convert form values to map[string]interface{}
 		notification = make(map[string]interface{})
-		for key, values := range request.Form {
+		for key, values := range request.PostForm {
 			if len(values) == 1 {
 				// Single value - try to parse as JSON first, fallback to string
 				value := values[0]
@@ -352,6 +432,16 @@ func (w *Interface) loadTemplates() error {
 			}
 			return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
 		},
+		"formatSeconds": func(seconds int64) string {
+			d := time.Duration(seconds) * time.Second
+			if d < time.Minute {
+				return fmt.Sprintf("%ds", seconds)
+			}
+			if d < time.Hour {
+				return fmt.Sprintf("%dm", int(d.Minutes()))
+			}
+			return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+		},
 		"formatUptime": func(d time.Duration) string {
 			days := int(d.Hours()) / 24
 			hours := int(d.Hours()) % 24
@@ -404,6 +494,7 @@ func (w *Interface) dashboardHandler(writer http.ResponseWriter, request *http.R
 		Status           types.BotStatus
 		Logs             []types.LogEntry
 		LlamaEnabled     bool
+		LLMProvider      string
 		Macros           map[string]*types.Macro
 		IsRecording      bool
 		RecordingStatus  *types.MacroRecording
@@ -411,12 +502,21 @@ func (w *Interface) dashboardHandler(writer http.ResponseWriter, request *http.R
 		NearbyAvatars    map[string]*types.AvatarInfo
 		AutoGreetEnabled bool
 		AutoGreetMacro   string
+		Conversations    []*conversation.Conversation
+		Personas         []persona.Info
+		PersonaManual    bool
+		Schedules        []scheduler.Info
+		Visitors         []*visitors.Visitor
+		VisitorCount     int
+		Webhooks         []webhook.HookStats
+		DeadLetters      []webhook.DeadLetter
 		BuildInfo        BuildInfo
 		SystemInfo       SystemInfo
 	}{
 		Status:           status,
 		Logs:             logs,
 		LlamaEnabled:     w.chatProcessor.IsLlamaEnabled(),
+		LLMProvider:      w.chatProcessor.LLMProvider(),
 		Macros:           macros,
 		IsRecording:      recordingStatus != nil,
 		RecordingStatus:  recordingStatus,
@@ -424,6 +524,14 @@ func (w *Interface) dashboardHandler(writer http.ResponseWriter, request *http.R
 		NearbyAvatars:    nearbyAvatars,
 		AutoGreetEnabled: autoGreetEnabled,
 		AutoGreetMacro:   autoGreetMacro,
+		Conversations:    w.chatProcessor.GetConversations().List(),
+		Personas:         w.chatProcessor.GetPersonaManager().List(),
+		PersonaManual:    w.chatProcessor.GetPersonaManager().Manual() != "",
+		Schedules:        w.chatProcessor.GetScheduler().List(time.Now()),
+		Visitors:         w.chatProcessor.GetVisitors().Search("", 100),
+		VisitorCount:     w.chatProcessor.GetVisitors().Count(),
+		Webhooks:         w.chatProcessor.GetWebhooks().Stats(),
+		DeadLetters:      w.chatProcessor.GetWebhooks().DeadLetters(),
 		BuildInfo:        w.buildInfo,
 		SystemInfo:       systemInfo,
 	}
@@ -447,6 +555,7 @@ func (w *Interface) getSystemInfo() SystemInfo {
 		Uptime:       time.Since(w.startTime),
 		OS:           runtime.GOOS,
 		Arch:         runtime.GOARCH,
+		Queue:        w.chatProcessor.GetQueueStats(),
 	}
 }
 
@@ -606,13 +715,7 @@ func (w *Interface) playMacroHandler(writer http.ResponseWriter, request *http.R
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
-	}
-
-	err := w.chatProcessor.GetMacroManager().PlayMacro(macroName, requestor)
+	err := w.chatProcessor.GetMacroManager().PlayMacro(macroName, requestor(request))
 
 	response := map[string]string{
 		"status":  "success",
@@ -638,13 +741,7 @@ func (w *Interface) deleteMacroHandler(writer http.ResponseWriter, request *http
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
-	}
-
-	err := w.chatProcessor.GetMacroManager().DeleteMacro(macroName, requestor)
+	err := w.chatProcessor.GetMacroManager().DeleteMacro(macroName, requestor(request))
 
 	response := map[string]string{
 		"status":  "success",
@@ -676,7 +773,7 @@ func (w *Interface) getRecordingStatusHandler(writer http.ResponseWriter, reques
 // toggleLlamaHandler toggles Llama chat on/off
 func (w *Interface) toggleLlamaHandler(writer http.ResponseWriter, request *http.Request) {
 	currentStatus := w.chatProcessor.IsLlamaEnabled()
-	w.chatProcessor.SetLlamaEnabled(!currentStatus)
+	err := w.chatProcessor.SetLlamaEnabled(!currentStatus)
 
 	newStatus := "enabled"
 	if currentStatus {
@@ -684,9 +781,15 @@ func (w *Interface) toggleLlamaHandler(writer http.ResponseWriter, request *http
 	}
 
 	response := map[string]interface{}{
-		"status":  "success",
-		"message": fmt.Sprintf("Llama chat %s", newStatus),
-		"enabled": !currentStatus,
+		"status":   "success",
+		"message":  fmt.Sprintf("Llama chat %s", newStatus),
+		"enabled":  w.chatProcessor.IsLlamaEnabled(),
+		"provider": w.chatProcessor.LLMProvider(),
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = "Failed to enable Llama chat: " + err.Error()
 	}
 
 	writer.Header().Set("Content-Type", "application/json")
@@ -758,13 +861,7 @@ func (w *Interface) setIdleBehaviorHandler(writer http.ResponseWriter, request *
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
-	}
-
-	err := w.chatProcessor.GetMacroManager().SetIdleBehavior(macroName, requestor, true)
+	err := w.chatProcessor.GetMacroManager().SetIdleBehavior(macroName, requestor(request), true)
 
 	response := map[string]string{
 		"status":  "success",
@@ -790,13 +887,7 @@ func (w *Interface) unsetIdleBehaviorHandler(writer http.ResponseWriter, request
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
-	}
-
-	err := w.chatProcessor.GetMacroManager().SetIdleBehavior(macroName, requestor, false)
+	err := w.chatProcessor.GetMacroManager().SetIdleBehavior(macroName, requestor(request), false)
 
 	response := map[string]string{
 		"status":  "success",
@@ -822,13 +913,7 @@ func (w *Interface) setAutoGreetMacroHandler(writer http.ResponseWriter, request
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
-	}
-
-	err := w.chatProcessor.GetMacroManager().SetAutoGreet(macroName, requestor, true)
+	err := w.chatProcessor.GetMacroManager().SetAutoGreet(macroName, requestor(request), true)
 
 	response := map[string]string{
 		"status":  "success",
@@ -854,19 +939,390 @@ func (w *Interface) unsetAutoGreetMacroHandler(writer http.ResponseWriter, reque
 		return
 	}
 
-	// For web interface, use first owner as requestor
-	requestor := "WebInterface"
-	if len(w.config.Bot.Owners) > 0 {
-		requestor = w.config.Bot.Owners[0]
+	err := w.chatProcessor.GetMacroManager().SetAutoGreet(macroName, requestor(request), false)
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Macro '%s' no longer an auto-greet macro", macroName),
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getNotecardsHandler returns the cached notecards
+func (w *Interface) getNotecardsHandler(writer http.ResponseWriter, request *http.Request) {
+	manager := w.chatProcessor.GetNotecardManager()
+
+	response := map[string]interface{}{
+		"enabled":    manager.IsEnabled(),
+		"lastReload": manager.LastReload(),
+		"notecards":  manager.GetNotecards(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// reloadNotecardsHandler reads the notecards from inventory again
+func (w *Interface) reloadNotecardsHandler(writer http.ResponseWriter, request *http.Request) {
+	count, err := w.chatProcessor.ReloadNotecards()
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Reloaded %d notecards", count),
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = "Failed to reload notecards: " + err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getRolesHandler returns the role assignments
+func (w *Interface) getRolesHandler(writer http.ResponseWriter, request *http.Request) {
+	response := map[string]interface{}{
+		"roles":       roles.Names(),
+		"assignments": w.chatProcessor.GetRoleManager().Assignments(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// grantRoleHandler gives an avatar a role
+func (w *Interface) grantRoleHandler(writer http.ResponseWriter, request *http.Request) {
+	var req types.RoleRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Granted role %s to %s", req.Role, req.Name),
+	}
+
+	role, err := roles.Parse(req.Role)
+	if err == nil {
+		err = w.chatProcessor.GetRoleManager().Grant(req.UUID, req.Name, role, requestor(request))
+	}
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
 	}
 
-	err := w.chatProcessor.GetMacroManager().SetAutoGreet(macroName, requestor, false)
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// revokeRoleHandler removes an avatar's role
+func (w *Interface) revokeRoleHandler(writer http.ResponseWriter, request *http.Request) {
+	uuid := mux.Vars(request)["uuid"]
+
+	err := w.chatProcessor.GetRoleManager().Revoke(uuid, requestor(request))
 
 	response := map[string]string{
 		"status":  "success",
-		"message": fmt.Sprintf("Macro '%s' no longer an auto-greet macro", macroName),
+		"message": fmt.Sprintf("Revoked role from %s", uuid),
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getConversationsHandler returns the conversations the bot remembers
+func (w *Interface) getConversationsHandler(writer http.ResponseWriter, request *http.Request) {
+	store := w.chatProcessor.GetConversations()
+
+	response := map[string]interface{}{
+		"enabled":       store.IsEnabled(),
+		"conversations": store.List(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// clearConversationHandler forgets the conversation with one avatar
+func (w *Interface) clearConversationHandler(writer http.ResponseWriter, request *http.Request) {
+	uuid := mux.Vars(request)["uuid"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Conversation cleared",
+	}
+
+	if !w.chatProcessor.ClearConversation(uuid, requestor(request)) {
+		response["status"] = "error"
+		response["message"] = "No conversation with " + uuid
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// clearConversationsHandler forgets every conversation
+func (w *Interface) clearConversationsHandler(writer http.ResponseWriter, request *http.Request) {
+	count := w.chatProcessor.GetConversations().ClearAll()
+	w.chatProcessor.SystemLog("%s cleared %d conversations", requestor(request), count)
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Cleared %d conversations", count),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getKnowledgeHandler returns the state of the knowledge base
+func (w *Interface) getKnowledgeHandler(writer http.ResponseWriter, request *http.Request) {
+	manager := w.chatProcessor.GetKnowledgeManager()
+	documents, passages, indexedAt := manager.Stats()
+
+	response := map[string]interface{}{
+		"enabled":   manager.IsEnabled(),
+		"documents": documents,
+		"passages":  passages,
+		"indexedAt": indexedAt,
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// searchKnowledgeHandler returns the passages matching ?q=, up to ?k= results
+func (w *Interface) searchKnowledgeHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query().Get("q")
+	if query == "" {
+		http.Error(writer, "Missing query parameter q", http.StatusBadRequest)
+		return
+	}
+
+	limit := 0
+	if k := request.URL.Query().Get("k"); k != "" {
+		if parsed, err := strconv.Atoi(k); err == nil {
+			limit = parsed
+		}
+	}
+
+	results, err := w.chatProcessor.GetKnowledgeManager().Search(request.Context(), query, limit)
+
+	response := map[string]interface{}{
+		"status":  "success",
+		"query":   query,
+		"results": results,
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = "Search failed: " + err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// reindexKnowledgeHandler updates the knowledge index from the document folder
+func (w *Interface) reindexKnowledgeHandler(writer http.ResponseWriter, request *http.Request) {
+	count, err := w.chatProcessor.ReindexKnowledge(requestor(request))
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Reindexed %d documents", count),
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = "Failed to reindex knowledge base: " + err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getRateLimitHandler returns the flood protection counters
+func (w *Interface) getRateLimitHandler(writer http.ResponseWriter, request *http.Request) {
+	stats := w.chatProcessor.GetRateLimiter().Stats()
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(stats)
+}
+
+// unignoreHandler lifts the rate limit on an avatar
+func (w *Interface) unignoreHandler(writer http.ResponseWriter, request *http.Request) {
+	uuid := mux.Vars(request)["uuid"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Rate limit reset for " + uuid,
+	}
+
+	if w.chatProcessor.GetRateLimiter().Unignore(uuid) {
+		w.chatProcessor.SystemLog("%s reset the rate limit for %s", requestor(request), uuid)
+	} else {
+		response["status"] = "error"
+		response["message"] = "No rate limit recorded for " + uuid
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getLanguagesHandler returns the languages remembered for avatars
+func (w *Interface) getLanguagesHandler(writer http.ResponseWriter, request *http.Request) {
+	manager := w.chatProcessor.GetLanguageManager()
+
+	response := map[string]interface{}{
+		"enabled":     manager.IsEnabled(),
+		"default":     manager.Default(),
+		"preferences": manager.Preferences(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getPersonaHandler returns the active persona and the ones available
+func (w *Interface) getPersonaHandler(writer http.ResponseWriter, request *http.Request) {
+	manager := w.chatProcessor.GetPersonaManager()
+
+	response := map[string]interface{}{
+		"active":    manager.Active(),
+		"automatic": manager.Manual() == "",
+		"personas":  manager.List(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// setPersonaHandler switches persona, or back to automatic selection with "auto"
+func (w *Interface) setPersonaHandler(writer http.ResponseWriter, request *http.Request) {
+	var req types.PersonaRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	active, err := w.chatProcessor.SetPersona(req.Name, requestor(request))
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Now using persona " + active,
+		"active":  active,
+	}
+
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// lslEventHandler runs an event sent by a trusted in-world object. The
+// request is authenticated by its signature rather than an API key.
+func (w *Interface) lslEventHandler(writer http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)["name"]
+	writer.Header().Set("Content-Type", "application/json")
+
+	registry := w.chatProcessor.GetLSLRegistry()
+	if !registry.IsEnabled() {
+		writer.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": "LSL events are disabled"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, 16*1024))
+	if err != nil {
+		http.Error(writer, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	object, err := registry.Authenticate(
+		request.Header.Get(lsl.HeaderObjectKey),
+		name,
+		request.Header.Get(lsl.HeaderTimestamp),
+		request.Header.Get(lsl.HeaderSignature),
+		body,
+	)
+	if err != nil {
+		log.Printf("LSL: rejected %s from %s (%s): %v", name, request.Header.Get(lsl.HeaderObjectKey), request.RemoteAddr, err)
+		writer.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": err.Error()})
+		return
+	}
+
+	data := make(map[string]string)
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &data); err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": "Body must be a JSON object of strings"})
+			return
+		}
+	}
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Handled " + name,
+	}
+
+	if err := w.chatProcessor.HandleLSLEvent(object, name, data); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getLSLObjectsHandler returns the objects trusted to send events
+func (w *Interface) getLSLObjectsHandler(writer http.ResponseWriter, request *http.Request) {
+	registry := w.chatProcessor.GetLSLRegistry()
+
+	response := map[string]interface{}{
+		"enabled": registry.IsEnabled(),
+		"objects": registry.Objects(),
 	}
 
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// registerLSLObjectHandler trusts an object to send events
+func (w *Interface) registerLSLObjectHandler(writer http.ResponseWriter, request *http.Request) {
+	var req types.LSLObjectRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]string{
+		"status":  "success",
+		"message": fmt.Sprintf("Registered %s", req.Name),
+	}
+
+	err := w.chatProcessor.GetLSLRegistry().Register(lsl.Object{
+		UUID:   req.UUID,
+		Name:   req.Name,
+		Secret: req.Secret,
+		Events: req.Events,
+	}, requestor(request))
 	if err != nil {
 		response["status"] = "error"
 		response["message"] = err.Error()
@@ -875,3 +1331,231 @@ func (w *Interface) unsetAutoGreetMacroHandler(writer http.ResponseWriter, reque
 	writer.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(writer).Encode(response)
 }
+
+// removeLSLObjectHandler stops trusting an object
+func (w *Interface) removeLSLObjectHandler(writer http.ResponseWriter, request *http.Request) {
+	uuid := mux.Vars(request)["uuid"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Removed " + uuid,
+	}
+
+	if err := w.chatProcessor.GetLSLRegistry().Remove(uuid, requestor(request)); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getSchedulesHandler returns the scheduled tasks with their next run in SL time
+func (w *Interface) getSchedulesHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(w.chatProcessor.GetScheduler().List(time.Now()))
+}
+
+// saveScheduleHandler adds a scheduled task or replaces one with the same name
+func (w *Interface) saveScheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	var task config.ScheduledTask
+	if err := json.NewDecoder(request.Body).Decode(&task); err != nil {
+		http.Error(writer, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Saved scheduled task " + task.Name,
+	}
+
+	if err := w.chatProcessor.GetScheduler().Save(task, requestor(request)); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// removeScheduleHandler deletes a scheduled task
+func (w *Interface) removeScheduleHandler(writer http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)["name"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Removed scheduled task " + name,
+	}
+
+	if err := w.chatProcessor.GetScheduler().Remove(name, requestor(request)); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getEventsHandler returns the upcoming events, with their subscribers for staff
+func (w *Interface) getEventsHandler(writer http.ResponseWriter, request *http.Request) {
+	events := w.chatProcessor.GetCalendar().Upcoming(time.Now())
+	writer.Header().Set("Content-Type", "application/json")
+
+	// Only staff see who asked to be reminded
+	if role, _ := w.callerRole(request); role >= roles.Staff {
+		json.NewEncoder(writer).Encode(events)
+		return
+	}
+	listings := make([]calendar.Listing, len(events))
+	for i, event := range events {
+		listings[i] = event.Listing()
+	}
+	json.NewEncoder(writer).Encode(listings)
+}
+
+// addEventHandler puts an event on the calendar
+func (w *Interface) addEventHandler(writer http.ResponseWriter, request *http.Request) {
+	var req types.EventRequest
+	if err := json.NewDecoder(request.Body).Decode(&req); err != nil {
+		http.Error(writer, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	var event *calendar.Event
+	var err error
+	if req.Start != nil {
+		event, err = w.chatProcessor.AddEventAt(req.Title, *req.Start, requestor(request))
+	} else {
+		event, err = w.chatProcessor.AddEvent(req.Title+" "+req.When, requestor(request))
+	}
+
+	response := map[string]interface{}{
+		"status": "success",
+	}
+	if err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	} else {
+		response["message"] = "Added " + event.Title
+		response["event"] = event
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// cancelEventHandler removes an event and tells its subscribers
+func (w *Interface) cancelEventHandler(writer http.ResponseWriter, request *http.Request) {
+	id := mux.Vars(request)["id"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Cancelled event " + id,
+	}
+
+	if _, err := w.chatProcessor.CancelEvent(id, requestor(request)); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getWebhooksHandler returns the webhooks with their delivery counts and recent failures
+func (w *Interface) getWebhooksHandler(writer http.ResponseWriter, request *http.Request) {
+	webhooks := w.chatProcessor.GetWebhooks()
+
+	response := map[string]interface{}{
+		"hooks":       webhooks.Stats(),
+		"deadLetters": webhooks.DeadLetters(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// testWebhookHandler posts a test event to a webhook and reports how it went
+func (w *Interface) testWebhookHandler(writer http.ResponseWriter, request *http.Request) {
+	name := mux.Vars(request)["name"]
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Test event delivered to " + name,
+	}
+
+	if err := w.chatProcessor.GetWebhooks().Test(name); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+	w.chatProcessor.SystemLog("%s test-fired webhook %s: %s", requestor(request), name, response["message"])
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
+
+// bridgeMessageHandler passes a message from the external chat system on
+// to local chat or an avatar's IMs. The request is authenticated by its
+// signature rather than an API key.
+func (w *Interface) bridgeMessageHandler(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	chatBridge := w.chatProcessor.GetBridge()
+	if !chatBridge.IsEnabled() {
+		writer.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": "The chat bridge is disabled"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(request.Body, 16*1024))
+	if err != nil {
+		http.Error(writer, "Failed to read request", http.StatusBadRequest)
+		return
+	}
+
+	if err := chatBridge.Authenticate(request.Header.Get(bridge.HeaderTimestamp), request.Header.Get(bridge.HeaderSignature), body); err != nil {
+		log.Printf("Bridge: rejected a message from %s: %v", request.RemoteAddr, err)
+		writer.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": err.Error()})
+		return
+	}
+
+	var inbound bridge.Inbound
+	if err := json.Unmarshal(body, &inbound); err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(writer).Encode(map[string]string{"status": "error", "message": "Invalid request body"})
+		return
+	}
+
+	response := map[string]string{
+		"status":  "success",
+		"message": "Message passed on",
+	}
+
+	if err := w.chatProcessor.HandleBridgeMessage(inbound); err != nil {
+		response["status"] = "error"
+		response["message"] = err.Error()
+	}
+
+	json.NewEncoder(writer).Encode(response)
+}
+
+// getVisitorsHandler returns the visitors whose name or UUID matches the
+// q parameter, most recently seen first
+func (w *Interface) getVisitorsHandler(writer http.ResponseWriter, request *http.Request) {
+	limit := 100
+	if value := request.URL.Query().Get("limit"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	registry := w.chatProcessor.GetVisitors()
+	response := map[string]interface{}{
+		"visitors": registry.Search(request.URL.Query().Get("q"), limit),
+		"total":    registry.Count(),
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(response)
+}
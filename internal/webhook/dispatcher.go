@@ -0,0 +1,383 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"slbot/internal/config"
+	"slbot/internal/signature"
+)
+
+// Events that can be sent to webhooks
+const (
+	EventChat          = "chat"
+	EventIM            = "im"
+	EventAvatarArrived = "avatar.arrived"
+	EventAvatarLeft    = "avatar.left"
+	EventOffline       = "bot.offline"
+	EventOnline        = "bot.online"
+	EventLeftHome      = "bot.left_home"
+	EventMacroFinished = "macro.finished"
+	EventPayment       = "payment"
+	EventTest          = "test"
+)
+
+// Headers sent with each delivery
+const (
+	HeaderEvent     = "X-SLBot-Event"
+	HeaderDelivery  = "X-SLBot-Delivery"
+	HeaderSignature = "X-SLBot-Signature"
+)
+
+// maxDeadLetters is how many failed deliveries are kept for the dashboard
+const maxDeadLetters = 50
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	ID        string                 `json:"id"`
+	Event     string                 `json:"event"`
+	Timestamp time.Time              `json:"timestamp"`
+	Bot       string                 `json:"bot"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+// DeadLetter is a delivery that failed every attempt
+type DeadLetter struct {
+	Hook     string    `json:"hook"`
+	URL      string    `json:"url"`
+	Payload  Payload   `json:"payload"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// HookStats describes a webhook and how its deliveries went
+type HookStats struct {
+	Name      string     `json:"name"`
+	URL       string     `json:"url"`
+	Events    []string   `json:"events"`
+	Delivered int        `json:"delivered"`
+	Failed    int        `json:"failed"`
+	LastError string     `json:"lastError,omitempty"`
+	LastSent  *time.Time `json:"lastSent,omitempty"`
+}
+
+// hook is a configured webhook with its queue of deliveries and delivery counts
+type hook struct {
+	config.Webhook
+	events    []string
+	queue     chan delivery
+	delivered int
+	failed    int
+	lastError string
+	lastSent  time.Time
+}
+
+// wants reports whether the hook subscribes to an event
+func (h *hook) wants(event string) bool {
+	if event == EventTest || len(h.events) == 0 {
+		return true
+	}
+	for _, wanted := range h.events {
+		if wanted == event || wanted == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// delivery is a payload waiting to be posted to a hook
+type delivery struct {
+	hook    *hook
+	payload Payload
+	body    []byte
+}
+
+// queueSize is how many deliveries can wait for each hook before new
+// events go straight to the dead-letter log
+const queueSize = 100
+
+// Dispatcher posts bot events to the configured webhooks in the background,
+// retrying failed deliveries with backoff. Each hook has its own queue and
+// worker, so one slow hook doesn't hold up the rest.
+type Dispatcher struct {
+	config      config.WebhooksConfig
+	bot         string
+	hooks       []*hook
+	client      *http.Client
+	deadLetters []DeadLetter
+	sequence    int
+	mutex       sync.Mutex
+}
+
+// NewDispatcher creates a dispatcher for the configured webhooks
+func NewDispatcher(cfg *config.Config) *Dispatcher {
+	return New(cfg.Webhooks, cfg.Bot.Name)
+}
+
+// New creates a dispatcher for a set of webhooks, naming the bot in payloads
+func New(webhooks config.WebhooksConfig, bot string) *Dispatcher {
+	dispatcher := &Dispatcher{
+		config: webhooks,
+		bot:    bot,
+		client: &http.Client{Timeout: time.Duration(webhooks.Timeout) * time.Second},
+	}
+
+	for _, configured := range webhooks.Hooks {
+		h := &hook{Webhook: configured, queue: make(chan delivery, queueSize)}
+		h.URL = strings.TrimSpace(h.URL)
+		if h.Name == "" || h.URL == "" {
+			log.Printf("Skipping webhook without a name or URL '%s'", h.Name)
+			continue
+		}
+		for _, event := range strings.Split(configured.Events, ",") {
+			if event = strings.TrimSpace(event); event != "" {
+				h.events = append(h.events, event)
+			}
+		}
+		dispatcher.hooks = append(dispatcher.hooks, h)
+	}
+
+	return dispatcher
+}
+
+// IsEnabled reports whether any webhooks are configured
+func (d *Dispatcher) IsEnabled() bool {
+	return len(d.hooks) > 0
+}
+
+// Wants reports whether any webhook subscribes to an event, so callers can
+// skip gathering its data
+func (d *Dispatcher) Wants(event string) bool {
+	for _, h := range d.hooks {
+		if h.wants(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Start delivers queued events until the context is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, h := range d.hooks {
+		go d.work(ctx, h)
+	}
+}
+
+// work delivers a hook's queued events one at a time, in order
+func (d *Dispatcher) work(ctx context.Context, h *hook) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case next := <-h.queue:
+			d.deliver(ctx, next)
+		}
+	}
+}
+
+// Emit queues an event for every webhook that subscribes to it
+func (d *Dispatcher) Emit(event string, data map[string]interface{}) {
+	for _, h := range d.hooks {
+		if !h.wants(event) || event == EventTest {
+			continue
+		}
+
+		next, err := d.prepare(h, event, data)
+		if err != nil {
+			log.Printf("Webhook %s: %v", h.Name, err)
+			continue
+		}
+
+		select {
+		case h.queue <- next:
+		default:
+			d.bury(next, 0, fmt.Errorf("queue full"))
+		}
+	}
+}
+
+// Test posts a test event to a webhook straight away and reports the result
+func (d *Dispatcher) Test(name string) error {
+	for _, h := range d.hooks {
+		if !strings.EqualFold(h.Name, name) {
+			continue
+		}
+
+		next, err := d.prepare(h, EventTest, map[string]interface{}{"message": "Test delivery from the dashboard"})
+		if err != nil {
+			return err
+		}
+		err = d.post(next)
+		d.record(h, err)
+		return err
+	}
+	return fmt.Errorf("no webhook named %s", name)
+}
+
+// prepare builds the payload and body for a delivery
+func (d *Dispatcher) prepare(h *hook, event string, data map[string]interface{}) (delivery, error) {
+	d.mutex.Lock()
+	d.sequence++
+	id := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.Itoa(d.sequence)
+	d.mutex.Unlock()
+
+	payload := Payload{
+		ID:        id,
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Bot:       d.bot,
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return delivery{}, fmt.Errorf("failed to encode %s event: %v", event, err)
+	}
+	return delivery{hook: h, payload: payload, body: body}, nil
+}
+
+// deliver posts a delivery, retrying with exponential backoff before
+// giving up and adding it to the dead-letter log
+func (d *Dispatcher) deliver(ctx context.Context, next delivery) {
+	backoff := time.Second
+	attempts := 1
+	if d.config.Retries != nil {
+		attempts += *d.config.Retries
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = d.post(next); err == nil {
+			d.record(next.hook, nil)
+			return
+		}
+		log.Printf("Webhook %s: %s delivery %s failed (attempt %d of %d): %v", next.hook.Name, next.payload.Event, next.payload.ID, attempt, attempts, err)
+
+		if attempt < attempts {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 5*time.Minute)
+		}
+	}
+
+	d.record(next.hook, err)
+	d.bury(next, attempts, err)
+}
+
+// post sends a delivery once, treating anything but a 2xx response as a failure
+func (d *Dispatcher) post(next delivery) error {
+	request, err := http.NewRequest(http.MethodPost, next.hook.URL, bytes.NewReader(next.body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, next.payload.Event)
+	request.Header.Set(HeaderDelivery, next.payload.ID)
+	if next.hook.Secret != "" {
+		request.Header.Set(HeaderSignature, "sha256="+signature.Hex(next.hook.Secret, string(next.body)))
+	}
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", next.hook.URL, response.Status)
+	}
+	return nil
+}
+
+// record updates a hook's delivery counts
+func (d *Dispatcher) record(h *hook, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	h.lastSent = time.Now()
+	if err != nil {
+		h.failed++
+		h.lastError = err.Error()
+	} else {
+		h.delivered++
+		h.lastError = ""
+	}
+}
+
+// bury adds a failed delivery to the dead-letter log
+func (d *Dispatcher) bury(next delivery, attempts int, err error) {
+	letter := DeadLetter{
+		Hook:     next.hook.Name,
+		URL:      next.hook.URL,
+		Payload:  next.payload,
+		Attempts: attempts,
+		Error:    err.Error(),
+		FailedAt: time.Now(),
+	}
+
+	d.mutex.Lock()
+	d.deadLetters = append(d.deadLetters, letter)
+	if len(d.deadLetters) > maxDeadLetters {
+		d.deadLetters = d.deadLetters[len(d.deadLetters)-maxDeadLetters:]
+	}
+	d.mutex.Unlock()
+
+	line, _ := json.Marshal(letter)
+	file, openErr := os.OpenFile(d.config.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		log.Printf("Failed to open webhook dead-letter log %s: %v", d.config.DeadLetter, openErr)
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// Stats returns the webhooks with their delivery counts
+func (d *Dispatcher) Stats() []HookStats {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stats := make([]HookStats, 0, len(d.hooks))
+	for _, h := range d.hooks {
+		hookStats := HookStats{
+			Name:      h.Name,
+			URL:       h.URL,
+			Events:    h.events,
+			Delivered: h.delivered,
+			Failed:    h.failed,
+			LastError: h.lastError,
+		}
+		if !h.lastSent.IsZero() {
+			lastSent := h.lastSent
+			hookStats.LastSent = &lastSent
+		}
+		stats = append(stats, hookStats)
+	}
+	return stats
+}
+
+// DeadLetters returns the most recent failed deliveries, newest first
+func (d *Dispatcher) DeadLetters() []DeadLetter {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	letters := make([]DeadLetter, len(d.deadLetters))
+	for i, letter := range d.deadLetters {
+		letters[len(letters)-1-i] = letter
+	}
+	return letters
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -46,6 +45,9 @@ func main() {
 		log.Fatalf("Failed to connect to Corrade: %v", err)
 	}
 
+	log.Println("Resolving owner UUIDs...")
+	chatProcessor.GetRoleManager().ResolveOwners(corradeClient.ResolveAvatarKeys)
+
 	log.Println("Testing Llama connection...")
 	if err := chatProcessor.TestConnection(); err != nil {
 		log.Fatalf("Failed to connect to Llama: %v", err)
@@ -86,7 +88,7 @@ func main() {
 	}()
 
 	// Setup Corrade notifications for chat events
-	callbackURL := fmt.Sprintf("http://localhost:%d/corrade/notifications", cfg.Bot.WebPort)
+	callbackURL := cfg.NotificationURL()
 	
 	// Setup chat notifications
 	if err := corradeClient.SetupNotification("chat", callbackURL); err != nil {
@@ -99,7 +101,7 @@ func main() {
 	}
 
 	// Announce bot is online
-	if err := corradeClient.Tell(cfg.Prompts.WelcomeMessage); err != nil {
+	if err := corradeClient.Tell(chatProcessor.GetPersonaManager().Prompts().WelcomeMessage); err != nil {
 		log.Printf("Failed to announce online status: %v", err)
 	}
 